@@ -0,0 +1,110 @@
+// Package auth identifies who is calling the API. A request carries either a Cognito JWT,
+// which signs in as the user with full access, or a personal access token, which acts as its
+// owner but only within the scopes it was granted.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"recipe-archive/db"
+	"recipe-archive/models"
+	"recipe-archive/utils"
+)
+
+// Principal is the authenticated caller
+type Principal struct {
+	UserID string
+	// TokenID is set when the caller used a personal access token
+	TokenID string
+	// Scopes limit a personal access token; Cognito sessions are not limited
+	Scopes []string
+}
+
+// IsAccessToken reports whether the caller used a personal access token
+func (p *Principal) IsAccessToken() bool {
+	return p.TokenID != ""
+}
+
+// Allows reports whether the caller may act within scope
+func (p *Principal) Allows(scope string) bool {
+	if !p.IsAccessToken() {
+		return true
+	}
+	for _, granted := range p.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenAuthenticator resolves personal access tokens, e.g. *db.AccessTokenStore. It returns
+// db.ErrInvalidToken for tokens that must be rejected.
+type TokenAuthenticator interface {
+	Authenticate(ctx context.Context, token string) (*models.AccessToken, error)
+}
+
+// Authenticate identifies the caller from the request's bearer token. Personal access tokens
+// are resolved with tokens, which may be nil where they are not accepted.
+func Authenticate(ctx context.Context, request events.APIGatewayProxyRequest, tokens TokenAuthenticator) (*Principal, error) {
+	token, err := utils.BearerToken(request)
+	if err != nil {
+		return nil, unauthorized(err)
+	}
+
+	if strings.HasPrefix(token, models.AccessTokenPrefix) {
+		if tokens == nil {
+			return nil, unauthorized(errors.New("personal access tokens are not accepted here"))
+		}
+		accessToken, err := tokens.Authenticate(ctx, token)
+		if errors.Is(err, db.ErrInvalidToken) {
+			return nil, unauthorized(err)
+		}
+		if err != nil {
+			return nil, utils.NewInternalError("Failed to verify access token", err)
+		}
+		return &Principal{UserID: accessToken.UserID, TokenID: accessToken.ID, Scopes: accessToken.Scopes}, nil
+	}
+
+	claims, err := utils.VerifyJWT(ctx, token)
+	if err != nil {
+		return nil, unauthorized(err)
+	}
+	return &Principal{UserID: claims.Sub}, nil
+}
+
+// RequireScope fails with 403 unless the caller may act within scope
+func RequireScope(principal *Principal, scope string) error {
+	if principal.Allows(scope) {
+		return nil
+	}
+	return utils.NewAppError(http.StatusForbidden, "INSUFFICIENT_SCOPE",
+		fmt.Sprintf("This access token does not have the %q scope", scope)).
+		WithDetails(map[string]interface{}{"requiredScope": scope})
+}
+
+// unauthorized logs why a request was rejected and returns the 401 sent to the client,
+// which never says why
+func unauthorized(reason error) error {
+	fmt.Printf("Authentication failed: %v\n", reason)
+	return utils.NewAppError(http.StatusUnauthorized, "UNAUTHORIZED", "Invalid or missing authentication token")
+}
+
+type principalKey struct{}
+
+// NewContext returns a context carrying principal
+func NewContext(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the principal stored by NewContext, or nil
+func FromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"recipe-archive/db"
+	"recipe-archive/models"
+	"recipe-archive/utils"
+)
+
+type fakeTokens map[string]*models.AccessToken
+
+func (f fakeTokens) Authenticate(ctx context.Context, token string) (*models.AccessToken, error) {
+	if accessToken, ok := f[token]; ok {
+		return accessToken, nil
+	}
+	return nil, db.ErrInvalidToken
+}
+
+func bearer(token string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{Headers: map[string]string{"Authorization": "Bearer " + token}}
+}
+
+func statusOf(err error) int {
+	var appErr *utils.AppError
+	if errors.As(err, &appErr) {
+		return appErr.Status
+	}
+	return 0
+}
+
+func TestAuthenticateAccessToken(t *testing.T) {
+	tokens := fakeTokens{"rat_good_secret": {ID: "good", UserID: "user-1", Scopes: []string{models.ScopeRead}}}
+
+	principal, err := Authenticate(context.Background(), bearer("rat_good_secret"), tokens)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if principal.UserID != "user-1" || !principal.IsAccessToken() {
+		t.Errorf("principal = %+v", principal)
+	}
+	if RequireScope(principal, models.ScopeRead) != nil {
+		t.Error("read scope denied")
+	}
+	if err := RequireScope(principal, models.ScopeWrite); statusOf(err) != http.StatusForbidden {
+		t.Errorf("write scope: %v, want 403", err)
+	}
+
+	for name, request := range map[string]events.APIGatewayProxyRequest{
+		"unknown token": bearer("rat_bad_secret"),
+		"no header":     {},
+	} {
+		if _, err := Authenticate(context.Background(), request, tokens); statusOf(err) != http.StatusUnauthorized {
+			t.Errorf("%s: %v, want 401", name, err)
+		}
+	}
+	if _, err := Authenticate(context.Background(), bearer("rat_good_secret"), nil); statusOf(err) != http.StatusUnauthorized {
+		t.Errorf("tokens not accepted: %v, want 401", err)
+	}
+}
+
+func TestSessionAllowsEveryScope(t *testing.T) {
+	session := &Principal{UserID: "user-1"}
+	for _, scope := range []string{models.ScopeRead, models.ScopeWrite, models.ScopeBackup} {
+		if !session.Allows(scope) {
+			t.Errorf("session denied %s", scope)
+		}
+	}
+}
@@ -2,9 +2,14 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -17,6 +22,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"github.com/klauspost/compress/zstd"
 )
 
 // SQS Message format for recipe normalization
@@ -70,7 +77,9 @@ func (f FlexInt) MarshalJSON() ([]byte, error) {
 	return json.Marshal(*f.Value)
 }
 
-// Simplified recipe structure for normalization
+// Simplified recipe structure for normalization. Field names are those of the canonical
+// schema version 1 shape (models.Recipe in the recipes module); the fields not listed here
+// are carried over from the stored document untouched (see normalizedFields).
 type Recipe struct {
 	ID                 string              `json:"id"`
 	UserID             string              `json:"userId"`
@@ -81,16 +90,36 @@ type Recipe struct {
 	MainPhotoURL       string              `json:"mainPhotoUrl,omitempty"`
 	Servings           FlexInt             `json:"servings,omitempty"`
 	TotalTime          FlexInt             `json:"totalTimeMinutes,omitempty"`
-	PrepTime           FlexInt             `json:"prepTime,omitempty"`
-	CookTime           FlexInt             `json:"cookTime,omitempty"`
-	Tags               []string            `json:"tags,omitempty"`
+	PrepTime           FlexInt             `json:"prepTimeMinutes,omitempty"`
+	CookTime           FlexInt             `json:"cookTimeMinutes,omitempty"`
+	Categories         []string            `json:"categories,omitempty"`
 	SearchMetadata     *SearchMetadata     `json:"searchMetadata,omitempty"`
 	CreatedAt          string              `json:"createdAt"`
 	UpdatedAt          string              `json:"updatedAt"`
 	IsDeleted          bool                `json:"isDeleted"`
 	Version            int                 `json:"version"`
+	SchemaVersion      int                 `json:"schemaVersion,omitempty"`
+
+	// doc is the stored document as read, so fields this struct does not know (description,
+	// reviews, nutrition, yield, webArchiveUrl, deletedAt, ...) survive the rewrite
+	doc map[string]json.RawMessage
+}
+
+// schemaVersion is the stored-recipe schema this normalizer reads and writes (keep in step
+// with models.CurrentSchemaVersion). Unversioned legacy objects are left to the
+// recipe-migrate command, which knows every legacy shape.
+const schemaVersion = 1
+
+// normalizedFields are the document fields the normalizer rewrites; a field that is null
+// after normalization is removed
+var normalizedFields = []string{
+	"title", "ingredients", "instructions", "servings", "totalTimeMinutes", "prepTimeMinutes",
+	"cookTimeMinutes", "categories", "searchMetadata", "updatedAt",
 }
 
+// sealedContentType marks recipes sealed by the recipes module's envelope encryption
+const sealedContentType = "application/vnd.recipe-archive.envelope"
+
 // SearchMetadata contains OpenAI-generated search optimization fields
 type SearchMetadata struct {
 	SemanticTags       []string `json:"semanticTags"`       // ["italian", "comfort-food", "weeknight"]
@@ -191,7 +220,7 @@ func handler(ctx context.Context, event events.SQSEvent) error {
 		fmt.Printf("📝 Normalizing recipe %s for user %s\n", message.RecipeID, message.UserID)
 
 		// Get the recipe from S3
-		recipe, err := getRecipeFromS3(ctx, s3Client, bucketName, message.UserID, message.RecipeID)
+		recipe, etag, err := getRecipeFromS3(ctx, s3Client, bucketName, message.UserID, message.RecipeID)
 		if err != nil {
 			log.Printf("❌ Failed to get recipe %s: %v", message.RecipeID, err)
 			continue
@@ -206,7 +235,11 @@ func handler(ctx context.Context, event events.SQSEvent) error {
 			originalTitle := recipe.Title
 			recipe.Title = normalizeTitle(recipe.Title)
 			if recipe.Title != originalTitle {
-				if err := saveRecipeToS3(ctx, s3Client, bucketName, recipe); err != nil {
+				if err := saveRecipeToS3(ctx, s3Client, bucketName, recipe, etag); err != nil {
+					if isPreconditionFailed(err) {
+						fmt.Printf("⏭️ Recipe %s was edited during normalization, keeping the user's copy\n", message.RecipeID)
+						continue
+					}
 					log.Printf("❌ Failed to update recipe %s: %v", message.RecipeID, err)
 					continue
 				}
@@ -220,8 +253,13 @@ func handler(ctx context.Context, event events.SQSEvent) error {
 		// Update with normalized data
 		*recipe = *normalizedRecipe
 
-		// Always save the recipe (even if only metadata was added)
-		if err := saveRecipeToS3(ctx, s3Client, bucketName, recipe); err != nil {
+		// Always save the recipe (even if only metadata was added), unless it was edited
+		// while OpenAI was running - the user's edit wins over the normalizer
+		if err := saveRecipeToS3(ctx, s3Client, bucketName, recipe, etag); err != nil {
+			if isPreconditionFailed(err) {
+				fmt.Printf("⏭️ Recipe %s was edited during normalization, keeping the user's copy\n", message.RecipeID)
+				continue
+			}
 			log.Printf("❌ Failed to update normalized recipe %s: %v", message.RecipeID, err)
 			continue
 		}
@@ -232,8 +270,8 @@ func handler(ctx context.Context, event events.SQSEvent) error {
 	return nil
 }
 
-// getRecipeFromS3 retrieves a recipe from S3
-func getRecipeFromS3(ctx context.Context, s3Client *s3.Client, bucketName, userID, recipeID string) (*Recipe, error) {
+// getRecipeFromS3 retrieves a recipe from S3 along with the object's ETag
+func getRecipeFromS3(ctx context.Context, s3Client *s3.Client, bucketName, userID, recipeID string) (*Recipe, string, error) {
 	key := fmt.Sprintf("recipes/%s/%s.json", userID, recipeID)
 
 	result, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
@@ -241,34 +279,84 @@ func getRecipeFromS3(ctx context.Context, s3Client *s3.Client, bucketName, userI
 		Key:    aws.String(key),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get recipe from S3: %w", err)
+		return nil, "", fmt.Errorf("failed to get recipe from S3: %w", err)
 	}
 	defer result.Body.Close()
 
+	// Client-side encrypted recipes can only be opened by the recipes module's key provider
+	if aws.ToString(result.ContentType) == sealedContentType {
+		return nil, "", fmt.Errorf("recipe is encrypted, skipping background normalization")
+	}
+
+	// Compressed recipes are marked with Content-Encoding by the recipes module
+	var body io.Reader = result.Body
+	switch encoding := aws.ToString(result.ContentEncoding); encoding {
+	case "gzip":
+		gz, err := gzip.NewReader(result.Body)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to decompress recipe: %w", err)
+		}
+		body = gz
+	case "zstd":
+		zr, err := zstd.NewReader(result.Body)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to decompress recipe: %w", err)
+		}
+		defer zr.Close()
+		body = zr
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read recipe: %w", err)
+	}
 	var recipe Recipe
-	if err := json.NewDecoder(result.Body).Decode(&recipe); err != nil {
-		return nil, fmt.Errorf("failed to decode recipe JSON: %w", err)
+	if err := json.Unmarshal(data, &recipe); err != nil {
+		return nil, "", fmt.Errorf("failed to decode recipe JSON: %w", err)
+	}
+	if err := json.Unmarshal(data, &recipe.doc); err != nil {
+		return nil, "", fmt.Errorf("failed to decode recipe JSON: %w", err)
+	}
+	if recipe.SchemaVersion > schemaVersion {
+		return nil, "", fmt.Errorf("recipe schema version %d is newer than supported (%d), refusing to rewrite it", recipe.SchemaVersion, schemaVersion)
+	}
+	if recipe.SchemaVersion < schemaVersion {
+		return nil, "", fmt.Errorf("recipe is unversioned, skipping background normalization until recipe-migrate upgrades it")
 	}
 
-	return &recipe, nil
+	return &recipe, aws.ToString(result.ETag), nil
 }
 
-// saveRecipeToS3 saves a recipe to S3
-func saveRecipeToS3(ctx context.Context, s3Client *s3.Client, bucketName string, recipe *Recipe) error {
+// saveRecipeToS3 saves a recipe to S3. When ifMatch is set the write only succeeds if the
+// stored object still has that ETag, so edits made while normalizing are not overwritten.
+func saveRecipeToS3(ctx context.Context, s3Client *s3.Client, bucketName string, recipe *Recipe, ifMatch string) error {
 	key := fmt.Sprintf("recipes/%s/%s.json", recipe.UserID, recipe.ID)
 
-	recipeJSON, err := json.Marshal(recipe)
+	// Clients revalidate cached copies by version and updatedAt (see utils.RecipeETag), so a
+	// normalized recipe must not keep the timestamp of the copy they already have
+	recipe.UpdatedAt = time.Now().UTC().Format(time.RFC3339Nano)
+
+	// Record the write in the user's change log first so synced clients refetch the recipe
+	if err := recordChange(ctx, s3Client, bucketName, recipe); err != nil {
+		return err
+	}
+
+	recipeJSON, err := encodeRecipe(recipe)
 	if err != nil {
-		return fmt.Errorf("failed to marshal recipe: %w", err)
+		return err
 	}
 
-	_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
+	input := &s3.PutObjectInput{
 		Bucket:      aws.String(bucketName),
 		Key:         aws.String(key),
 		Body:        strings.NewReader(string(recipeJSON)),
 		ContentType: aws.String("application/json"),
-	})
+	}
+	if ifMatch != "" {
+		input.IfMatch = aws.String(ifMatch)
+	}
 
+	_, err = s3Client.PutObject(ctx, input)
 	if err != nil {
 		return fmt.Errorf("failed to save recipe to S3: %w", err)
 	}
@@ -276,6 +364,71 @@ func saveRecipeToS3(ctx context.Context, s3Client *s3.Client, bucketName string,
 	return nil
 }
 
+// encodeRecipe writes the normalized fields over the stored document and stamps it with
+// schemaVersion, keeping every other field as it was read
+func encodeRecipe(recipe *Recipe) ([]byte, error) {
+	normalized, err := json.Marshal(recipe)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal recipe: %w", err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(normalized, &fields); err != nil {
+		return nil, fmt.Errorf("failed to marshal recipe: %w", err)
+	}
+
+	doc := make(map[string]json.RawMessage, len(recipe.doc)+len(normalizedFields))
+	for name, value := range recipe.doc {
+		doc[name] = value
+	}
+	for _, name := range normalizedFields {
+		if value, ok := fields[name]; ok && string(value) != "null" {
+			doc[name] = value
+		} else {
+			delete(doc, name)
+		}
+	}
+	doc["schemaVersion"] = json.RawMessage(strconv.Itoa(schemaVersion))
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal recipe: %w", err)
+	}
+	return data, nil
+}
+
+// recordChange appends an "update" entry to the user's change log. The key layout must match
+// the recipes module (db/changes.go): changes/{userID}/{unixNano}-{random}/{type}/{recipeID}/{version}
+func recordChange(ctx context.Context, s3Client *s3.Client, bucketName string, recipe *Recipe) error {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Errorf("failed to generate change ID: %w", err)
+	}
+	key := fmt.Sprintf("changes/%s/%019d-%s/update/%s/%d", recipe.UserID, time.Now().UnixNano(),
+		hex.EncodeToString(suffix), recipe.ID, recipe.Version)
+
+	_, err := s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+		Body:   strings.NewReader(""),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record recipe change: %w", err)
+	}
+	return nil
+}
+
+// isPreconditionFailed reports whether err is S3 rejecting a conditional write
+func isPreconditionFailed(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "PreconditionFailed", "ConditionalRequestConflict":
+			return true
+		}
+	}
+	return false
+}
+
 // normalizeRecipeWithOpenAI normalizes recipe using OpenAI API
 func normalizeRecipeWithOpenAI(ctx context.Context, recipe *Recipe) (*Recipe, error) {
 	openaiApiKey := os.Getenv("OPENAI_API_KEY")
@@ -391,18 +544,15 @@ func normalizeRecipeWithOpenAI(ctx context.Context, recipe *Recipe) (*Recipe, er
 		result.TotalTime.Value = &defaultTotal
 	}
 
-	// Add inferred metadata as tags if not already present
-	if normResponse.InferredMetadata.CuisineType != "" {
-		result.Tags = append(result.Tags, normResponse.InferredMetadata.CuisineType)
-	}
-	if normResponse.InferredMetadata.DifficultyLevel != "" {
-		result.Tags = append(result.Tags, normResponse.InferredMetadata.DifficultyLevel)
-	}
-	for _, method := range normResponse.InferredMetadata.CookingMethods {
-		result.Tags = append(result.Tags, method)
-	}
-	for _, diet := range normResponse.InferredMetadata.DietaryInfo {
-		result.Tags = append(result.Tags, diet)
+	// Add inferred metadata as categories if not already present
+	result.Categories = append([]string(nil), recipe.Categories...)
+	inferred := []string{normResponse.InferredMetadata.CuisineType, normResponse.InferredMetadata.DifficultyLevel}
+	inferred = append(inferred, normResponse.InferredMetadata.CookingMethods...)
+	inferred = append(inferred, normResponse.InferredMetadata.DietaryInfo...)
+	for _, category := range inferred {
+		if category != "" && !containsString(result.Categories, category) {
+			result.Categories = append(result.Categories, category)
+		}
 	}
 
 	// Apply search metadata for intelligent recipe discovery
@@ -414,6 +564,15 @@ func normalizeRecipeWithOpenAI(ctx context.Context, recipe *Recipe) (*Recipe, er
 	return &result, nil
 }
 
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 func buildNormalizationPrompt(recipe *Recipe) string {
 	ingredientsJson, _ := json.Marshal(recipe.Ingredients)
 	instructionsJson, _ := json.Marshal(recipe.Instructions)
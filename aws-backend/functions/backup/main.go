@@ -4,8 +4,12 @@ import (
 	"archive/zip"
 	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strings"
@@ -16,15 +20,26 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 
+	"recipe-archive/auth"
 	"recipe-archive/db"
+	"recipe-archive/envelope"
 	"recipe-archive/models"
+	"recipe-archive/quota"
+	"recipe-archive/ratelimit"
 	"recipe-archive/utils"
 )
 
 var (
-	s3Client   *s3.Client
+	s3Client   db.S3API
+	presigner  *s3.PresignClient
 	recipeDB   db.RecipeDB
+	tokenStore *db.AccessTokenStore
+	limiter    *ratelimit.Limiter
+	quotas     quota.Limits
+	encryptor  *envelope.Encryptor
 	bucketName string
 )
 
@@ -34,7 +49,9 @@ func init() {
 		panic(fmt.Sprintf("Failed to load AWS config: %v", err))
 	}
 
-	s3Client = s3.NewFromConfig(cfg)
+	client := s3.NewFromConfig(cfg)
+	s3Client = client
+	presigner = s3.NewPresignClient(client)
 
 	// Get S3 bucket name from environment variable
 	bucketName = os.Getenv("S3_STORAGE_BUCKET")
@@ -42,15 +59,42 @@ func init() {
 		bucketName = "recipe-archive-dev" // fallback for testing
 	}
 
+	// Recipes and backup zips are sealed with the user's data key when encryption is on
+	encryptor, err = envelope.FromEnv(cfg)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to configure backup encryption: %v", err))
+	}
+
 	// Initialize S3-based recipe storage
-	recipeDB = db.NewS3RecipeDB(s3Client, bucketName)
+	s3DB := db.NewS3RecipeDB(s3Client, bucketName)
+	s3DB.SetEncryptor(encryptor)
+	recipeDB = s3DB
+	tokenStore = db.NewAccessTokenStore(s3Client, bucketName)
+	limiter = ratelimit.New(ratelimit.StoreFromEnv(cfg))
+	quotas = quota.FromEnv()
 }
 
+// Download links for plaintext backups last a day. Links to decrypted copies of sealed
+// backups are kept short; the copies themselves are removed after a day by the bucket's
+// lifecycle rule for downloadPrefix.
+const (
+	backupURLLifetime   = 24 * time.Hour
+	downloadURLLifetime = time.Hour
+	downloadPrefix      = "backup-downloads"
+)
+
+// backupLimit caps backup creation, which reads and zips the whole library, per user
+var backupLimit = ratelimit.Limit{Requests: 5, Per: time.Hour}
+
 func main() {
-	lambda.Start(handler)
+	lambda.Start(utils.WithErrorResponses(handler))
 }
 
 func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Stop storage calls shortly before the Lambda deadline
+	ctx, cancel := utils.WithDeadlineMargin(ctx, utils.LambdaDeadlineMargin)
+	defer cancel()
+
 	// Handle CORS preflight requests
 	if request.HTTPMethod == "OPTIONS" {
 		response, err := utils.NewAPIResponse(http.StatusOK, map[string]string{"message": "CORS preflight"})
@@ -60,59 +104,33 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		return response, nil
 	}
 
-	// Extract user ID from JWT claims
-	userID := getUserIDFromRequest(request)
-	if userID == "" {
-		response, err := utils.NewAPIResponse(http.StatusUnauthorized, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "UNAUTHORIZED",
-				"message":   "Invalid or missing authentication token",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if err != nil {
-			return events.APIGatewayProxyResponse{}, err
-		}
-		return response, nil
+	// Backups hold every recipe, so access tokens need the backup scope for any of these
+	principal, err := auth.Authenticate(ctx, request, tokenStore)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	if err := auth.RequireScope(principal, models.ScopeBackup); err != nil {
+		return events.APIGatewayProxyResponse{}, err
 	}
+	userID := principal.UserID
 
 	// Route based on HTTP method
 	switch request.HTTPMethod {
 	case "POST":
-		return handleCreateBackup(ctx, request, userID)
+		create := func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			return handleCreateBackup(ctx, request, userID)
+		}
+		return limiter.Middleware("backup", backupLimit, func(context.Context) string { return userID })(create)(ctx, request)
 	case "GET":
+		if backupID := backupIDFromRequest(request); backupID != "" {
+			return handleDownloadBackup(ctx, userID, backupID)
+		}
 		return handleListBackups(ctx, request, userID)
 	default:
-		response, err := utils.NewAPIResponse(http.StatusMethodNotAllowed, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "METHOD_NOT_ALLOWED",
-				"message":   fmt.Sprintf("Method %s not allowed", request.HTTPMethod),
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if err != nil {
-			return events.APIGatewayProxyResponse{}, err
-		}
-		return response, nil
+		return events.APIGatewayProxyResponse{}, utils.NewAppError(http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", fmt.Sprintf("Method %s not allowed", request.HTTPMethod))
 	}
 }
 
-// getUserIDFromRequest extracts user ID from JWT claims
-func getUserIDFromRequest(request events.APIGatewayProxyRequest) string {
-	claims, err := utils.ExtractUserFromJWT(request)
-	if err != nil {
-		fmt.Printf("JWT validation failed: %v\n", err)
-		return ""
-	}
-
-	if claims.Sub == "" {
-		fmt.Printf("JWT missing subject claim\n")
-		return ""
-	}
-
-	return claims.Sub
-}
-
 // BackupManifest contains metadata about the backup
 type BackupManifest struct {
 	BackupID      string    `json:"backupId"`
@@ -137,19 +155,15 @@ func handleCreateBackup(ctx context.Context, request events.APIGatewayProxyReque
 	fmt.Printf("Creating backup for user: %s\n", userID)
 
 	// Get all recipes for the user
-	recipes, err := recipeDB.ListRecipes(userID)
+	recipes, err := recipeDB.ListRecipes(ctx, userID)
+	var partial *db.PartialListError
+	if errors.As(err, &partial) {
+		// Back up everything that could be read rather than failing the whole backup
+		fmt.Printf("⚠️ Partial recipe listing for backup: %v\n", partial)
+		err = nil
+	}
 	if err != nil {
-		response, responseErr := utils.NewAPIResponse(http.StatusInternalServerError, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "BACKUP_FAILED",
-				"message":   "Failed to retrieve recipes for backup",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+		return events.APIGatewayProxyResponse{}, utils.NewAppError(http.StatusInternalServerError, "BACKUP_FAILED", "Failed to retrieve recipes for backup").WithCause(err)
 	}
 
 	// Filter out soft-deleted recipes (backup only active recipes)
@@ -189,32 +203,12 @@ func handleCreateBackup(ctx context.Context, request events.APIGatewayProxyReque
 	// Add manifest to zip
 	manifestData, err := json.MarshalIndent(manifest, "", "  ")
 	if err != nil {
-		response, responseErr := utils.NewAPIResponse(http.StatusInternalServerError, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "BACKUP_FAILED",
-				"message":   "Failed to create backup manifest",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+		return events.APIGatewayProxyResponse{}, utils.NewAppError(http.StatusInternalServerError, "BACKUP_FAILED", "Failed to create backup manifest").WithCause(err)
 	}
 
 	manifestFile, err := zipWriter.Create("backup-manifest.json")
 	if err != nil {
-		response, responseErr := utils.NewAPIResponse(http.StatusInternalServerError, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "BACKUP_FAILED",
-				"message":   "Failed to create manifest file in zip",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+		return events.APIGatewayProxyResponse{}, utils.NewAppError(http.StatusInternalServerError, "BACKUP_FAILED", "Failed to create manifest file in zip").WithCause(err)
 	}
 	manifestFile.Write(manifestData)
 
@@ -293,28 +287,31 @@ Generated by RecipeArchive Backup API v1.0
 	// Close zip writer
 	err = zipWriter.Close()
 	if err != nil {
-		response, responseErr := utils.NewAPIResponse(http.StatusInternalServerError, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "BACKUP_FAILED",
-				"message":   "Failed to finalize backup zip",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+		return events.APIGatewayProxyResponse{}, utils.NewAppError(http.StatusInternalServerError, "BACKUP_FAILED", "Failed to finalize backup zip").WithCause(err)
 	}
 
 	// Upload zip file to S3
 	backupKey := fmt.Sprintf("backups/%s/%s.zip", userID, backupID)
 	zipData := zipBuffer.Bytes()
 
+	body, contentType := zipData, "application/zip"
+	if encryptor != nil {
+		sealed, err := encryptor.Seal(ctx, userID, backupKey, zipData)
+		if err != nil {
+			return events.APIGatewayProxyResponse{}, utils.NewAppError(http.StatusInternalServerError, "BACKUP_FAILED", "Failed to encrypt backup file").WithCause(err)
+		}
+		body, contentType = sealed, envelope.ContentType
+	}
+
+	if err := quotas.CheckStorage(ctx, s3Client, bucketName, userID, int64(len(body))); err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
 	_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
 		Bucket:      aws.String(bucketName),
 		Key:         aws.String(backupKey),
-		Body:        bytes.NewReader(zipData),
-		ContentType: aws.String("application/zip"),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String(contentType),
 		Metadata: map[string]string{
 			"backup-id":      backupID,
 			"user-id":        userID,
@@ -323,48 +320,27 @@ Generated by RecipeArchive Backup API v1.0
 		},
 	})
 	if err != nil {
-		response, responseErr := utils.NewAPIResponse(http.StatusInternalServerError, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "BACKUP_FAILED",
-				"message":   "Failed to store backup file",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+		return events.APIGatewayProxyResponse{}, utils.NewAppError(http.StatusInternalServerError, "BACKUP_FAILED", "Failed to store backup file").WithCause(err)
 	}
 
-	// Generate pre-signed URL for download (valid for 24 hours)
-	presignClient := s3.NewPresignClient(s3Client)
-	presignRequest := &s3.GetObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(backupKey),
+	// Generate pre-signed URL for download (valid for 24 hours). A sealed backup cannot be
+	// read from S3 directly, so the link points at a short-lived decrypted copy instead.
+	var downloadURL string
+	expiresAt := time.Now().Add(backupURLLifetime)
+	if encryptor != nil {
+		downloadURL, expiresAt, err = stageDownload(ctx, userID, backupID, zipData)
+	} else {
+		downloadURL, err = presignBackup(ctx, backupKey, backupURLLifetime)
 	}
-
-	presignResult, err := presignClient.PresignGetObject(ctx, presignRequest, func(opts *s3.PresignOptions) {
-		opts.Expires = 24 * time.Hour // 24 hour expiration
-	})
 	if err != nil {
-		response, responseErr := utils.NewAPIResponse(http.StatusInternalServerError, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "BACKUP_FAILED",
-				"message":   "Failed to generate download URL",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+		return events.APIGatewayProxyResponse{}, utils.NewAppError(http.StatusInternalServerError, "BACKUP_FAILED", "Failed to generate download URL").WithCause(err)
 	}
 
 	// Create response
 	backupResponse := BackupResponse{
 		BackupID:    backupID,
-		DownloadURL: presignResult.URL,
-		ExpiresAt:   time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339),
+		DownloadURL: downloadURL,
+		ExpiresAt:   expiresAt.UTC().Format(time.RFC3339),
 		RecipeCount: len(activeRecipes),
 		SizeBytes:   int64(len(zipData)),
 	}
@@ -379,6 +355,137 @@ Generated by RecipeArchive Backup API v1.0
 	return response, nil
 }
 
+// presignBackup returns a pre-signed GET URL for a plaintext backup zip
+func presignBackup(ctx context.Context, key string, lifetime time.Duration) (string, error) {
+	presignRequest := &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	}
+
+	presignResult, err := presigner.PresignGetObject(ctx, presignRequest, func(opts *s3.PresignOptions) {
+		opts.Expires = lifetime
+	})
+	if err != nil {
+		return "", err
+	}
+	return presignResult.URL, nil
+}
+
+// stageDownload stores a decrypted copy of a sealed backup under downloadPrefix and returns a
+// pre-signed URL for it, with the time the URL expires. The copy's key is random, so each
+// download gets its own copy and link.
+func stageDownload(ctx context.Context, userID, backupID string, zipData []byte) (string, time.Time, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to name download copy: %w", err)
+	}
+	key := fmt.Sprintf("%s/%s/%s-%s.zip", downloadPrefix, userID, backupID, hex.EncodeToString(suffix))
+
+	_, err := s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:             aws.String(bucketName),
+		Key:                aws.String(key),
+		Body:               bytes.NewReader(zipData),
+		ContentType:        aws.String("application/zip"),
+		ContentDisposition: aws.String(fmt.Sprintf("attachment; filename=%q", backupID+".zip")),
+	})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to store download copy: %w", err)
+	}
+
+	expiresAt := time.Now().Add(downloadURLLifetime)
+	downloadURL, err := presignBackup(ctx, key, downloadURLLifetime)
+	return downloadURL, expiresAt, err
+}
+
+// backupIDFromRequest returns the backup ID of GET /backups/{backupId}, or "" for the listing
+func backupIDFromRequest(request events.APIGatewayProxyRequest) string {
+	backupID := utils.GetPathParameter(request, "backupId")
+	if backupID == "" {
+		if last := request.Path[strings.LastIndex(request.Path, "/")+1:]; strings.HasPrefix(last, "backup_") {
+			backupID = last
+		}
+	}
+	if strings.ContainsAny(backupID, "/.") {
+		return ""
+	}
+	return backupID
+}
+
+// BackupDownloadResponse is the answer to GET /backups/{backupId}
+type BackupDownloadResponse struct {
+	BackupID    string `json:"backupId"`
+	DownloadURL string `json:"downloadUrl"`
+	ExpiresAt   string `json:"expiresAt"`
+}
+
+// handleDownloadBackup returns a fresh download link for a backup. Sealed backups are
+// decrypted into a short-lived copy (see stageDownload); Lambda responses are too small to
+// carry a large library's zip.
+func handleDownloadBackup(ctx context.Context, userID, backupID string) (events.APIGatewayProxyResponse, error) {
+	backupKey := fmt.Sprintf("backups/%s/%s.zip", userID, backupID)
+
+	head, err := s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(backupKey),
+	})
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, downloadError(err)
+	}
+
+	var downloadURL string
+	expiresAt := time.Now().Add(backupURLLifetime)
+	if aws.ToString(head.ContentType) != envelope.ContentType {
+		downloadURL, err = presignBackup(ctx, backupKey, backupURLLifetime)
+	} else {
+		var zipData []byte
+		zipData, err = readSealedBackup(ctx, userID, backupKey)
+		if err != nil {
+			return events.APIGatewayProxyResponse{}, downloadError(err)
+		}
+		downloadURL, expiresAt, err = stageDownload(ctx, userID, backupID, zipData)
+	}
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, utils.NewAppError(http.StatusInternalServerError, "DOWNLOAD_FAILED", "Failed to generate download URL").WithCause(err)
+	}
+
+	response, responseErr := utils.NewAPIResponse(http.StatusOK, BackupDownloadResponse{
+		BackupID:    backupID,
+		DownloadURL: downloadURL,
+		ExpiresAt:   expiresAt.UTC().Format(time.RFC3339),
+	})
+	if responseErr != nil {
+		return events.APIGatewayProxyResponse{}, responseErr
+	}
+	return response, nil
+}
+
+// readSealedBackup downloads and decrypts a sealed backup zip
+func readSealedBackup(ctx context.Context, userID, backupKey string) ([]byte, error) {
+	result, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(backupKey),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer result.Body.Close()
+	sealed, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, err
+	}
+	return envelope.OpenIfSealed(ctx, encryptor, userID, backupKey, sealed)
+}
+
+// downloadError reports a backup that could not be read, as 404 when it does not exist
+func downloadError(err error) *utils.AppError {
+	var noSuchKey *types.NoSuchKey
+	var apiErr smithy.APIError
+	if errors.As(err, &noSuchKey) || (errors.As(err, &apiErr) && apiErr.ErrorCode() == "NotFound") {
+		return utils.NewAppError(http.StatusNotFound, "BACKUP_NOT_FOUND", "Backup not found").WithCause(err)
+	}
+	return utils.NewAppError(http.StatusInternalServerError, "DOWNLOAD_FAILED", "Failed to read backup file").WithCause(err)
+}
+
 // handleListBackups lists available backups for a user
 func handleListBackups(ctx context.Context, request events.APIGatewayProxyRequest, userID string) (events.APIGatewayProxyResponse, error) {
 	// List backup files in S3 for the user
@@ -391,17 +498,7 @@ func handleListBackups(ctx context.Context, request events.APIGatewayProxyReques
 
 	result, err := s3Client.ListObjectsV2(ctx, listInput)
 	if err != nil {
-		response, responseErr := utils.NewAPIResponse(http.StatusInternalServerError, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "LIST_FAILED",
-				"message":   "Failed to list backups",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+		return events.APIGatewayProxyResponse{}, utils.NewAppError(http.StatusInternalServerError, "LIST_FAILED", "Failed to list backups").WithCause(err)
 	}
 
 	type BackupInfo struct {
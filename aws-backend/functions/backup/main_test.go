@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+
+	"recipe-archive/db"
+	"recipe-archive/envelope"
+	"recipe-archive/models"
+	"recipe-archive/quota"
+	"recipe-archive/ratelimit"
+	"recipe-archive/utils"
+)
+
+// memS3 is an in-memory db.S3API without paging or conditional writes
+type memS3 struct {
+	mu           sync.Mutex
+	objects      map[string][]byte
+	contentTypes map[string]string
+}
+
+func (m *memS3) GetObject(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[aws.ToString(in.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data)), ETag: aws.String(`"1"`)}, nil
+}
+
+func (m *memS3) HeadObject(ctx context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.objects[aws.ToString(in.Key)]; !ok {
+		return nil, &smithy.GenericAPIError{Code: "NotFound", Message: "Not Found"}
+	}
+	return &s3.HeadObjectOutput{ETag: aws.String(`"1"`), ContentType: aws.String(m.contentTypes[aws.ToString(in.Key)])}, nil
+}
+
+func (m *memS3) PutObject(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[aws.ToString(in.Key)] = data
+	m.contentTypes[aws.ToString(in.Key)] = aws.ToString(in.ContentType)
+	return &s3.PutObjectOutput{ETag: aws.String(`"1"`)}, nil
+}
+
+func (m *memS3) DeleteObject(ctx context.Context, in *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, aws.ToString(in.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func (m *memS3) ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(false)}
+	for key, data := range m.objects {
+		if strings.HasPrefix(key, aws.ToString(in.Prefix)) {
+			out.Contents = append(out.Contents, types.Object{Key: aws.String(key), Size: aws.Int64(int64(len(data)))})
+		}
+	}
+	return out, nil
+}
+
+// keysUnder lists the stored keys that start with prefix
+func (m *memS3) keysUnder(prefix string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []string
+	for key := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// setupBackup points the handler at an in-memory bucket and returns it with a backup-scoped
+// access token for user-1
+func setupBackup(t *testing.T) (*memS3, string) {
+	t.Helper()
+	saved := struct {
+		client    db.S3API
+		presigner *s3.PresignClient
+		recipeDB  db.RecipeDB
+		tokens    *db.AccessTokenStore
+		limiter   *ratelimit.Limiter
+		quotas    quota.Limits
+		limit     ratelimit.Limit
+		bucket    string
+		encryptor *envelope.Encryptor
+	}{s3Client, presigner, recipeDB, tokenStore, limiter, quotas, backupLimit, bucketName, encryptor}
+	t.Cleanup(func() {
+		s3Client, presigner, recipeDB, tokenStore = saved.client, saved.presigner, saved.recipeDB, saved.tokens
+		limiter, quotas, backupLimit, bucketName, encryptor = saved.limiter, saved.quotas, saved.limit, saved.bucket, saved.encryptor
+	})
+
+	store := &memS3{objects: map[string][]byte{}, contentTypes: map[string]string{}}
+	bucketName = "bucket"
+	s3Client = store
+	// Presigning only needs credentials to sign with, not a reachable endpoint
+	staticCredentials := aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}, nil
+	})
+	presigner = s3.NewPresignClient(s3.New(s3.Options{Region: "us-west-2", Credentials: staticCredentials}))
+	recipeDB = db.NewS3RecipeDB(store, bucketName)
+	tokenStore = db.NewAccessTokenStore(store, bucketName)
+	limiter = ratelimit.New(ratelimit.NewMemoryStore())
+	quotas = quota.Limits{}
+	encryptor = nil
+
+	ctx := context.Background()
+	recipe := &models.Recipe{ID: "r1", UserID: "user-1", Title: "Soup", Version: 1, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := recipeDB.CreateRecipe(ctx, recipe); err != nil {
+		t.Fatalf("create recipe: %v", err)
+	}
+	_, token, err := tokenStore.Create(ctx, "user-1", "backups", []string{models.ScopeBackup}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+	return store, token
+}
+
+func createBackupRequest(token string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Path:       "/v1/backup/create",
+		Headers:    map[string]string{"Authorization": "Bearer " + token},
+	}
+}
+
+// errorCode runs the request through the error envelope and returns its status and code
+func errorCode(t *testing.T, request events.APIGatewayProxyRequest) (int, string) {
+	t.Helper()
+	response, err := utils.WithErrorResponses(handler)(context.Background(), request)
+	if err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	var body models.ErrorResponse
+	_ = json.Unmarshal([]byte(response.Body), &body)
+	return response.StatusCode, body.Error.Code
+}
+
+func TestCreateBackupRateLimit(t *testing.T) {
+	store, token := setupBackup(t)
+	backupLimit = ratelimit.Limit{Requests: 1, Per: time.Hour}
+
+	if status, code := errorCode(t, createBackupRequest(token)); status != http.StatusCreated {
+		t.Fatalf("first backup = %d %s", status, code)
+	}
+	if status, code := errorCode(t, createBackupRequest(token)); status != http.StatusTooManyRequests || code != "RATE_LIMITED" {
+		t.Errorf("second backup = %d %s, want 429 RATE_LIMITED", status, code)
+	}
+	if keys := store.keysUnder("backups/user-1/"); len(keys) != 1 {
+		t.Errorf("stored backups = %v, want one", keys)
+	}
+}
+
+func TestCreateBackupStorageQuota(t *testing.T) {
+	store, token := setupBackup(t)
+	quotas = quota.Limits{MaxStorageBytes: 1}
+
+	if status, code := errorCode(t, createBackupRequest(token)); status != http.StatusForbidden || code != "QUOTA_EXCEEDED" {
+		t.Errorf("backup over quota = %d %s, want 403 QUOTA_EXCEEDED", status, code)
+	}
+	if keys := store.keysUnder("backups/"); len(keys) != 0 {
+		t.Errorf("stored backups = %v, want none", keys)
+	}
+}
+
+func TestEncryptedBackupDownloadsFromPlaintextCopy(t *testing.T) {
+	store, token := setupBackup(t)
+	keyring, err := envelope.NewKeyring()
+	if err != nil {
+		t.Fatalf("keyring: %v", err)
+	}
+	encryptor = envelope.NewEncryptor(keyring)
+
+	response, err := handler(context.Background(), createBackupRequest(token))
+	if err != nil || response.StatusCode != http.StatusCreated {
+		t.Fatalf("create backup = %d %v", response.StatusCode, err)
+	}
+	var created BackupResponse
+	if err := json.Unmarshal([]byte(response.Body), &created); err != nil {
+		t.Fatalf("decode backup response: %v", err)
+	}
+	if !strings.Contains(created.DownloadURL, "/backup-downloads/user-1/") {
+		t.Errorf("create downloadUrl = %s, want a presigned backup-downloads copy", created.DownloadURL)
+	}
+
+	response, err = handler(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: "GET",
+		Path:       "/v1/backups/" + created.BackupID,
+		Headers:    map[string]string{"Authorization": "Bearer " + token},
+	})
+	if err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("download backup = %d %v", response.StatusCode, err)
+	}
+	var download BackupDownloadResponse
+	if err := json.Unmarshal([]byte(response.Body), &download); err != nil {
+		t.Fatalf("decode download response: %v", err)
+	}
+	if !strings.Contains(download.DownloadURL, "/backup-downloads/user-1/") || download.DownloadURL == created.DownloadURL {
+		t.Errorf("download downloadUrl = %s, want a fresh backup-downloads copy", download.DownloadURL)
+	}
+
+	sealed := store.objects["backups/user-1/"+created.BackupID+".zip"]
+	if bytes.HasPrefix(sealed, []byte("PK")) {
+		t.Error("stored backup is a plaintext zip, want it sealed")
+	}
+	copies := store.keysUnder("backup-downloads/user-1/")
+	if len(copies) != 2 {
+		t.Fatalf("download copies = %v, want two", copies)
+	}
+	for _, key := range copies {
+		if !bytes.HasPrefix(store.objects[key], []byte("PK")) {
+			t.Errorf("download copy %s is not a plaintext zip", key)
+		}
+	}
+}
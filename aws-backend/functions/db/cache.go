@@ -0,0 +1,350 @@
+package db
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"recipe-archive/models"
+)
+
+// Default CachingRecipeDB settings, sized for a warm Lambda container
+const (
+	DefaultCacheSize = 500
+	DefaultCacheTTL  = time.Minute
+)
+
+// summariesCacheKey is the per-user entry holding ListRecipeSummaries; "_" names never
+// collide with recipe IDs
+const summariesCacheKey = "_summaries"
+
+// CacheStats counts how CachingRecipeDB lookups were answered. Revalidations are the
+// hits that needed an ETag check because the entry was older than the TTL.
+type CacheStats struct {
+	Hits          int64 `json:"hits"`
+	Misses        int64 `json:"misses"`
+	Revalidations int64 `json:"revalidations"`
+	Evictions     int64 `json:"evictions"`
+}
+
+// CachingRecipeDB is a read-through RecipeDB decorator with a bounded LRU of recipes and
+// recipe summaries. Entries younger than the TTL are served without touching the backend.
+// Older entries are revalidated with a HEAD request when the backend implements ETagStore
+// and refetched otherwise. Writes made through the decorator invalidate the recipe and the
+// user's summaries; writes made elsewhere become visible once the TTL has passed.
+type CachingRecipeDB struct {
+	backend RecipeDB
+	size    int
+	ttl     time.Duration
+	now     func() time.Time
+
+	mu      sync.Mutex
+	lru     *list.List // of *cacheEntry, most recently used first
+	entries map[string]*list.Element
+	stats   CacheStats
+}
+
+// cacheEntry is a JSON-encoded value, so callers never share the cached copy
+type cacheEntry struct {
+	key      string
+	data     []byte
+	etag     string
+	storedAt time.Time
+}
+
+// NewCachingRecipeDB wraps backend with a cache of at most size entries
+func NewCachingRecipeDB(backend RecipeDB, size int, ttl time.Duration) *CachingRecipeDB {
+	if size < 1 {
+		size = 1
+	}
+	return &CachingRecipeDB{
+		backend: backend,
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		lru:     list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+// Unwrap returns the decorated backend
+func (c *CachingRecipeDB) Unwrap() RecipeDB {
+	return c.backend
+}
+
+// Stats returns the counters accumulated since the cache was created
+func (c *CachingRecipeDB) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Unwrap returns the backend beneath any decorators such as CachingRecipeDB, for checking
+// optional interfaces like RecipePager that decorators don't forward
+func Unwrap(store RecipeDB) RecipeDB {
+	for {
+		wrapper, ok := store.(interface{ Unwrap() RecipeDB })
+		if !ok {
+			return store
+		}
+		store = wrapper.Unwrap()
+	}
+}
+
+func recipeCacheKey(userID, recipeID string) string {
+	return userID + "/" + recipeID
+}
+
+// GetRecipe returns the cached recipe if it is fresh or its ETag still matches, and
+// otherwise loads it from the backend
+func (c *CachingRecipeDB) GetRecipe(ctx context.Context, userID, recipeID string) (*models.Recipe, error) {
+	key := recipeCacheKey(userID, recipeID)
+
+	if entry, fresh := c.lookup(key); entry != nil {
+		if fresh {
+			if recipe, ok := c.decodeHit(key, entry); ok {
+				return recipe, nil
+			}
+		} else if etags, ok := c.backend.(ETagStore); ok && entry.etag != "" {
+			etag, err := etags.HeadRecipe(ctx, userID, recipeID)
+			if err == nil && etag == entry.etag {
+				c.touch(key, true)
+				c.mu.Lock()
+				c.stats.Revalidations++
+				c.mu.Unlock()
+				if recipe, ok := c.decodeHit(key, entry); ok {
+					return recipe, nil
+				}
+			}
+		}
+	}
+
+	c.mu.Lock()
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	var recipe *models.Recipe
+	var etag string
+	var err error
+	if etags, ok := c.backend.(ETagStore); ok {
+		recipe, etag, err = etags.GetRecipeWithETag(ctx, userID, recipeID)
+	} else {
+		recipe, err = c.backend.GetRecipe(ctx, userID, recipeID)
+	}
+	if err != nil {
+		c.remove(key)
+		return nil, err
+	}
+	c.store(key, recipe, etag)
+	return recipe, nil
+}
+
+// ListRecipes revalidates every cached recipe against one object listing when the backend
+// implements ETagStore, downloading only new and changed recipes. Other backends are
+// listed directly and their results cached for later GetRecipe calls.
+func (c *CachingRecipeDB) ListRecipes(ctx context.Context, userID string) ([]models.Recipe, error) {
+	etags, ok := c.backend.(ETagStore)
+	if !ok {
+		recipes, err := c.backend.ListRecipes(ctx, userID)
+		for i := range recipes {
+			c.store(recipeCacheKey(userID, recipes[i].ID), &recipes[i], "")
+		}
+		return recipes, err
+	}
+
+	listed, err := etags.ListRecipeETags(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	cached := map[string]models.Recipe{}
+	var missing []string
+	for _, obj := range listed {
+		key := recipeCacheKey(userID, obj.RecipeID)
+		if entry, _ := c.lookup(key); entry != nil && entry.etag == obj.ETag {
+			// The listing just confirmed this copy, so it starts a new TTL
+			c.touch(key, true)
+			if recipe, ok := c.decodeHit(key, entry); ok {
+				cached[obj.RecipeID] = *recipe
+				continue
+			}
+		}
+		// Drop the stale copy so GetRecipe downloads without a pointless HEAD first
+		c.remove(key)
+		missing = append(missing, obj.RecipeID)
+	}
+
+	var listErr error
+	if len(missing) > 0 {
+		fetched, err := GetRecipes(ctx, c, userID, missing, DefaultListConcurrency)
+		if err != nil {
+			var partial *PartialListError
+			if !errors.As(err, &partial) {
+				return nil, err
+			}
+			listErr = err
+		}
+		for _, recipe := range fetched {
+			cached[recipe.ID] = recipe
+		}
+	}
+
+	recipes := make([]models.Recipe, 0, len(listed))
+	for _, obj := range listed {
+		if recipe, ok := cached[obj.RecipeID]; ok {
+			recipes = append(recipes, recipe)
+		}
+	}
+	return recipes, listErr
+}
+
+// ListRecipeSummaries caches each user's summaries for the TTL. Partial results are not cached.
+func (c *CachingRecipeDB) ListRecipeSummaries(ctx context.Context, userID string) ([]models.RecipeSummary, error) {
+	key := recipeCacheKey(userID, summariesCacheKey)
+	if entry, fresh := c.lookup(key); entry != nil && fresh {
+		var summaries []models.RecipeSummary
+		if err := json.Unmarshal(entry.data, &summaries); err == nil {
+			c.touch(key, false)
+			c.countHit()
+			return summaries, nil
+		}
+	}
+
+	c.mu.Lock()
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	summaries, err := c.backend.ListRecipeSummaries(ctx, userID)
+	if err != nil {
+		return summaries, err
+	}
+	if data, err := json.Marshal(summaries); err == nil {
+		c.put(&cacheEntry{key: key, data: data})
+	}
+	return summaries, nil
+}
+
+// CreateRecipe stores a new recipe and invalidates the user's cached summaries
+func (c *CachingRecipeDB) CreateRecipe(ctx context.Context, recipe *models.Recipe) error {
+	defer c.invalidate(recipe.UserID, recipe.ID)
+	return c.backend.CreateRecipe(ctx, recipe)
+}
+
+// UpdateRecipe replaces a recipe and invalidates its cache entries
+func (c *CachingRecipeDB) UpdateRecipe(ctx context.Context, recipe *models.Recipe) error {
+	defer c.invalidate(recipe.UserID, recipe.ID)
+	return c.backend.UpdateRecipe(ctx, recipe)
+}
+
+// UpdateRecipeIfVersion is the backend's compare-and-swap. The entries are invalidated
+// whether or not it succeeds, since a conflict means the cached copy is out of date.
+func (c *CachingRecipeDB) UpdateRecipeIfVersion(ctx context.Context, recipe *models.Recipe, expectedVersion int) error {
+	defer c.invalidate(recipe.UserID, recipe.ID)
+	return c.backend.UpdateRecipeIfVersion(ctx, recipe, expectedVersion)
+}
+
+// DeleteRecipe removes a recipe and invalidates its cache entries
+func (c *CachingRecipeDB) DeleteRecipe(ctx context.Context, userID, recipeID string) error {
+	defer c.invalidate(userID, recipeID)
+	return c.backend.DeleteRecipe(ctx, userID, recipeID)
+}
+
+// DeleteTrashedRecipe is the backend's conditional purge, or a plain delete for backends
+// that cannot make it conditional
+func (c *CachingRecipeDB) DeleteTrashedRecipe(ctx context.Context, userID, recipeID string, version int) error {
+	defer c.invalidate(userID, recipeID)
+	if purger, ok := c.backend.(TrashPurger); ok {
+		return purger.DeleteTrashedRecipe(ctx, userID, recipeID, version)
+	}
+	return c.backend.DeleteRecipe(ctx, userID, recipeID)
+}
+
+// invalidate drops a recipe and its user's summaries
+func (c *CachingRecipeDB) invalidate(userID, recipeID string) {
+	c.remove(recipeCacheKey(userID, recipeID))
+	c.remove(recipeCacheKey(userID, summariesCacheKey))
+}
+
+// lookup returns the entry for key, if any, and whether it is younger than the TTL
+func (c *CachingRecipeDB) lookup(key string) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	return entry, c.now().Sub(entry.storedAt) < c.ttl
+}
+
+// decodeHit decodes a cached recipe and counts the hit; an undecodable entry is dropped
+func (c *CachingRecipeDB) decodeHit(key string, entry *cacheEntry) (*models.Recipe, bool) {
+	var recipe models.Recipe
+	if err := json.Unmarshal(entry.data, &recipe); err != nil {
+		c.remove(key)
+		return nil, false
+	}
+	c.touch(key, false)
+	c.countHit()
+	return &recipe, true
+}
+
+// touch marks key as recently used; revalidated entries also get a fresh TTL
+func (c *CachingRecipeDB) touch(key string, revalidated bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	c.lru.MoveToFront(elem)
+	if revalidated {
+		elem.Value.(*cacheEntry).storedAt = c.now()
+	}
+}
+
+func (c *CachingRecipeDB) countHit() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Hits++
+}
+
+// store caches recipe under key together with the ETag it was read with
+func (c *CachingRecipeDB) store(key string, recipe *models.Recipe, etag string) {
+	data, err := json.Marshal(recipe)
+	if err != nil {
+		return
+	}
+	c.put(&cacheEntry{key: key, data: data, etag: etag})
+}
+
+// put inserts or replaces an entry, evicting the least recently used ones beyond size
+func (c *CachingRecipeDB) put(entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.storedAt = c.now()
+	if elem, ok := c.entries[entry.key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		c.stats.Evictions++
+	}
+}
+
+func (c *CachingRecipeDB) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+	}
+}
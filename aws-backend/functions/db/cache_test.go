@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCachingRecipeDBRevalidatesWithETags(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeS3()
+	for i := 0; i < 3; i++ {
+		fake.objects[fmt.Sprintf("recipes/u1/r%d.json", i)] = []byte(fmt.Sprintf(`{"id":"r%d","userId":"u1","title":"Recipe %d"}`, i, i))
+	}
+	backend := NewS3RecipeDB(fake, "bucket")
+	cache := NewCachingRecipeDB(backend, 10, time.Minute)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	if _, err := cache.ListRecipes(ctx, "u1"); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	gets := fake.gets
+
+	// Fresh entries are served without touching S3, and callers get their own copy
+	recipe, err := cache.GetRecipe(ctx, "u1", "r1")
+	if err != nil || recipe.Title != "Recipe 1" || fake.gets != gets || fake.heads != 0 {
+		t.Fatalf("fresh get: %v %+v (gets %d, heads %d)", err, recipe, fake.gets-gets, fake.heads)
+	}
+	recipe.Title = "Mutated"
+	if again, _ := cache.GetRecipe(ctx, "u1", "r1"); again.Title != "Recipe 1" {
+		t.Errorf("cached copy was shared with the caller")
+	}
+
+	// Expired but unchanged: one HEAD, no download
+	now = now.Add(2 * time.Minute)
+	if _, err := cache.GetRecipe(ctx, "u1", "r1"); err != nil || fake.gets != gets || fake.heads != 1 {
+		t.Fatalf("revalidated get: %v (gets %d, heads %d)", err, fake.gets-gets, fake.heads)
+	}
+
+	// Changed behind the cache's back: the listing spots the new ETag and only r2 is downloaded
+	fake.objects["recipes/u1/r2.json"] = []byte(`{"id":"r2","userId":"u1","title":"Changed"}`)
+	recipes, err := cache.ListRecipes(ctx, "u1")
+	if err != nil || len(recipes) != 3 || recipes[2].Title != "Changed" || fake.gets != gets+1 {
+		t.Fatalf("relist: %v %+v (gets %d)", err, recipes, fake.gets-gets)
+	}
+
+	// Writes through the cache invalidate
+	updated := recipes[0]
+	updated.Title = "Renamed"
+	if err := cache.UpdateRecipe(ctx, &updated); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got, _ := cache.GetRecipe(ctx, "u1", "r0"); got.Title != "Renamed" {
+		t.Errorf("update not visible through the cache: %q", got.Title)
+	}
+	if err := cache.DeleteRecipe(ctx, "u1", "r0"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := cache.GetRecipe(ctx, "u1", "r0"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted recipe still served: %v", err)
+	}
+
+	stats := cache.Stats()
+	if stats.Hits == 0 || stats.Misses == 0 || stats.Revalidations != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestCachingRecipeDBEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeS3()
+	cache := NewCachingRecipeDB(NewS3RecipeDB(fake, "bucket"), 2, time.Minute)
+	for _, id := range []string{"a", "b", "c"} {
+		fake.objects["recipes/u1/"+id+".json"] = []byte(`{"id":"` + id + `","userId":"u1"}`)
+	}
+
+	for _, id := range []string{"a", "b", "a", "c"} {
+		if _, err := cache.GetRecipe(ctx, "u1", id); err != nil {
+			t.Fatalf("get %s: %v", id, err)
+		}
+	}
+	gets := fake.gets
+	if _, err := cache.GetRecipe(ctx, "u1", "a"); err != nil || fake.gets != gets {
+		t.Errorf("recently used entry was evicted")
+	}
+	if _, err := cache.GetRecipe(ctx, "u1", "b"); err != nil || fake.gets != gets+1 {
+		t.Errorf("least recently used entry was kept")
+	}
+	if stats := cache.Stats(); stats.Evictions != 2 {
+		t.Errorf("evictions = %d, want 2", stats.Evictions)
+	}
+}
+
+func TestUnwrapReachesBackend(t *testing.T) {
+	backend := NewS3RecipeDB(newFakeS3(), "bucket")
+	var store RecipeDB = NewCachingRecipeDB(backend, 10, time.Minute)
+	if _, ok := Unwrap(store).(RecipeHistory); !ok {
+		t.Errorf("Unwrap did not reach the S3 backend")
+	}
+}
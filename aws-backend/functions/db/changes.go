@@ -0,0 +1,165 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"recipe-archive/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// The change log is one empty object per write at
+// changes/{userID}/{unixNano}-{random}/{type}/{recipeID}/{version}, so a single listing
+// returns a page of entries without reading any bodies. Entries sort by the time they were
+// recorded; the random part keeps simultaneous writes apart. The background normalizer
+// writes the same layout.
+//
+// An entry is recorded immediately before the write it describes and removed again if that
+// write fails, and ListChanges holds back entries younger than changeSettleWindow. Together
+// these guarantee that once a client's cursor has passed an entry, the write is complete and
+// no entry with an earlier timestamp can still appear (or disappear) from a slower writer.
+
+var _ ChangeLog = (*S3RecipeDB)(nil)
+
+// changeSettleWindow must exceed the longest a writer can take between recording an entry
+// and finishing its write (bounded by the Lambda timeouts)
+var changeSettleWindow = 15 * time.Second
+
+// changeSeqPattern matches the sortable first segment of a change key
+var changeSeqPattern = regexp.MustCompile(`^[0-9]{19}-[0-9a-f]{8}$`)
+
+func changesPrefix(userID string) string {
+	return fmt.Sprintf("changes/%s/", userID)
+}
+
+// newChangeSeq returns the sortable ID of a change log entry recorded now
+func newChangeSeq() (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate change ID: %w", err)
+	}
+	return fmt.Sprintf("%019d-%s", time.Now().UnixNano(), hex.EncodeToString(suffix)), nil
+}
+
+// changeSeqTime returns the time a change log entry was recorded
+func changeSeqTime(seq string) (time.Time, bool) {
+	if !changeSeqPattern.MatchString(seq) {
+		return time.Time{}, false
+	}
+	nanos, err := strconv.ParseInt(seq[:19], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, nanos).UTC(), true
+}
+
+// decodeChangeCursor returns the entry ID a ListChanges cursor continues after
+func decodeChangeCursor(cursor string) (string, error) {
+	after, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !changeSeqPattern.Match(after) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidCursor, cursor)
+	}
+	return string(after), nil
+}
+
+// recordChange appends an entry to the user's change log and returns its key
+func (db *S3RecipeDB) recordChange(ctx context.Context, userID, changeType, recipeID string, version int) (string, error) {
+	seq, err := newChangeSeq()
+	if err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("%s%s/%s/%s/%d", changesPrefix(userID), seq, changeType, recipeID, version)
+
+	_, err = db.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(db.bucketName),
+		Key:    aws.String(key),
+		Body:   strings.NewReader(""),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to record recipe change: %w", mapS3Error(err))
+	}
+	return key, nil
+}
+
+// removeChange deletes the entry of a write that failed. It runs without the request's
+// deadline so a cancelled request still cleans up; if it fails anyway, the stray entry only
+// makes clients re-read a recipe that did not change.
+func (db *S3RecipeDB) removeChange(ctx context.Context, key string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	_, err := db.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(db.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		fmt.Printf("⚠️ Failed to remove change log entry %s: %v\n", key, err)
+	}
+}
+
+// ListChanges returns the user's change log entries after cursor, oldest first
+func (db *S3RecipeDB) ListChanges(ctx context.Context, userID, cursor string, limit int) ([]models.RecipeChange, string, bool, error) {
+	prefix := changesPrefix(userID)
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(db.bucketName),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int32(int32(limit)),
+	}
+	if cursor != "" {
+		after, err := decodeChangeCursor(cursor)
+		if err != nil {
+			return nil, "", false, err
+		}
+		// Every key of that entry continues with "/", which sorts before "0"
+		input.StartAfter = aws.String(prefix + after + "0")
+	}
+
+	output, err := db.client.ListObjectsV2(ctx, input)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to list recipe changes: %w", err)
+	}
+
+	settled := time.Now().Add(-changeSettleWindow)
+	changes := []models.RecipeChange{}
+	next := cursor
+	for _, obj := range output.Contents {
+		seq, change, ok := parseChangeKey(strings.TrimPrefix(aws.ToString(obj.Key), prefix))
+		if !ok {
+			continue
+		}
+		if change.ChangedAt.After(settled) {
+			// Too recent to be final; the client picks it up on its next poll
+			return changes, next, false, nil
+		}
+		changes = append(changes, change)
+		next = base64.RawURLEncoding.EncodeToString([]byte(seq))
+	}
+	return changes, next, aws.ToBool(output.IsTruncated), nil
+}
+
+// parseChangeKey splits {seq}/{type}/{recipeID}/{version} into its entry
+func parseChangeKey(name string) (string, models.RecipeChange, bool) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 4 {
+		return "", models.RecipeChange{}, false
+	}
+	changedAt, ok := changeSeqTime(parts[0])
+	if !ok {
+		return "", models.RecipeChange{}, false
+	}
+	version, _ := strconv.Atoi(parts[3])
+	return parts[0], models.RecipeChange{
+		Type:      parts[1],
+		RecipeID:  parts[2],
+		Version:   version,
+		ChangedAt: changedAt,
+	}, true
+}
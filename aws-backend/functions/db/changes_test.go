@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"recipe-archive/models"
+)
+
+func TestS3ChangeLog(t *testing.T) {
+	ctx := context.Background()
+	store := NewS3RecipeDB(newFakeS3(), "bucket")
+	defer func(window time.Duration) { changeSettleWindow = window }(changeSettleWindow)
+	changeSettleWindow = 0
+
+	for _, id := range []string{"a", "b"} {
+		if err := store.CreateRecipe(ctx, &models.Recipe{ID: id, UserID: "u1", Title: id, Version: 1}); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+	if err := store.UpdateRecipeIfVersion(ctx, &models.Recipe{ID: "a", UserID: "u1", Title: "A", Version: 2, IsDeleted: true}, 1); err != nil {
+		t.Fatalf("trash: %v", err)
+	}
+	if err := store.DeleteRecipe(ctx, "u1", "b"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	var got []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		changes, next, hasMore, err := store.ListChanges(ctx, "u1", cursor, 3)
+		if err != nil {
+			t.Fatalf("list changes: %v", err)
+		}
+		for _, change := range changes {
+			got = append(got, change.Type+":"+change.RecipeID)
+		}
+		cursor = next
+		if !hasMore || pages > 5 {
+			break
+		}
+	}
+	want := []string{"create:a", "create:b", "delete:a", "delete:b"}
+	if len(got) != len(want) {
+		t.Fatalf("changes: got %v want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("changes: got %v want %v", got, want)
+		}
+	}
+
+	// Caught up: the cursor holds, and entries still settling are not handed out yet
+	changeSettleWindow = time.Hour
+	if err := store.CreateRecipe(ctx, &models.Recipe{ID: "c", UserID: "u1", Title: "c", Version: 1}); err != nil {
+		t.Fatalf("create c: %v", err)
+	}
+	changes, next, _, err := store.ListChanges(ctx, "u1", cursor, 10)
+	if err != nil || len(changes) != 0 || next != cursor {
+		t.Errorf("unsettled change returned: %v next %q err %v", changes, next, err)
+	}
+
+	if _, _, _, err := store.ListChanges(ctx, "u1", "bm90LWEtY3Vyc29y", 10); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("foreign cursor: got %v want ErrInvalidCursor", err)
+	}
+}
+
+func TestS3ChangeLogSkipsFailedWrites(t *testing.T) {
+	ctx := context.Background()
+	store := NewS3RecipeDB(newFakeS3(), "bucket")
+	defer func(window time.Duration) { changeSettleWindow = window }(changeSettleWindow)
+	changeSettleWindow = 0
+
+	if err := store.CreateRecipe(ctx, &models.Recipe{ID: "a", UserID: "u1", Title: "a", Version: 1}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	err := store.putRecipe(ctx, &models.Recipe{ID: "a", UserID: "u1", Title: "A", Version: 2}, `"stale"`, models.ChangeUpdate)
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("conditional put: got %v want ErrConflict", err)
+	}
+
+	changes, _, _, err := store.ListChanges(ctx, "u1", "", 10)
+	if err != nil {
+		t.Fatalf("list changes: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Type != models.ChangeCreate {
+		t.Errorf("changes: got %v want only the create", changes)
+	}
+}
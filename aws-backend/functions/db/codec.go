@@ -0,0 +1,175 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"recipe-archive/envelope"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is the content encoding of stored recipe bodies
+type Compression string
+
+const (
+	CompressionNone Compression = ""
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+// ParseCompression parses a RECIPE_COMPRESSION value ("", "none", "gzip" or "zstd")
+func ParseCompression(value string) (Compression, error) {
+	switch value {
+	case "", "none":
+		return CompressionNone, nil
+	case "gzip":
+		return CompressionGzip, nil
+	case "zstd":
+		return CompressionZstd, nil
+	default:
+		return "", fmt.Errorf("unknown compression %q", value)
+	}
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+	// EncodeAll and DecodeAll are safe for concurrent use, so one of each is shared
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// DetectCompression reports how an (unsealed) stored body is compressed. Recipe JSON never
+// starts with the gzip or zstd magic numbers, so legacy objects are recognized as plain.
+func DetectCompression(body []byte) Compression {
+	switch {
+	case bytes.HasPrefix(body, gzipMagic):
+		return CompressionGzip
+	case bytes.HasPrefix(body, zstdMagic):
+		return CompressionZstd
+	default:
+		return CompressionNone
+	}
+}
+
+// ObjectCodec turns recipe JSON into stored object bodies and back. Bodies are compressed
+// first and then sealed, since ciphertext does not compress.
+type ObjectCodec struct {
+	Compression Compression
+	Encryptor   *envelope.Encryptor
+}
+
+// StoredObject is an encoded body with the headers that describe it
+type StoredObject struct {
+	Body            []byte
+	ContentType     string
+	ContentEncoding string
+}
+
+// Encode compresses and seals data for storage at key
+func (c ObjectCodec) Encode(ctx context.Context, userID, key string, data []byte) (*StoredObject, error) {
+	obj := &StoredObject{Body: data, ContentType: "application/json"}
+
+	switch c.Compression {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(data); err != nil {
+			return nil, fmt.Errorf("failed to compress %s: %w", key, err)
+		}
+		if err := writer.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress %s: %w", key, err)
+		}
+		obj.Body, obj.ContentEncoding = buf.Bytes(), string(CompressionGzip)
+	case CompressionZstd:
+		obj.Body, obj.ContentEncoding = zstdEncoder.EncodeAll(data, nil), string(CompressionZstd)
+	}
+
+	if c.Encryptor != nil {
+		sealed, err := c.Encryptor.Seal(ctx, userID, key, obj.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt %s: %w", key, err)
+		}
+		// The compression marker travels inside the sealed body; S3 clients can't decode it
+		obj.Body, obj.ContentType, obj.ContentEncoding = sealed, envelope.ContentType, ""
+	}
+	return obj, nil
+}
+
+// Decode opens and decompresses a body read from key. Plaintext and uncompressed objects
+// written by older versions are returned as they are.
+func (c ObjectCodec) Decode(ctx context.Context, userID, key string, body []byte) ([]byte, error) {
+	data, err := envelope.OpenIfSealed(ctx, c.Encryptor, userID, key, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", key, err)
+	}
+
+	switch DetectCompression(data) {
+	case CompressionGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err == nil {
+			data, err = io.ReadAll(reader)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", key, err)
+		}
+	case CompressionZstd:
+		if data, err = zstdDecoder.DecodeAll(data, nil); err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", key, err)
+		}
+	}
+	return data, nil
+}
+
+// PutObjectInput builds the request that stores the object at key
+func (o *StoredObject) PutObjectInput(bucketName, key string) *s3.PutObjectInput {
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(bucketName),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(o.Body),
+		ContentType: aws.String(o.ContentType),
+	}
+	if o.ContentEncoding != "" {
+		input.ContentEncoding = aws.String(o.ContentEncoding)
+	}
+	return input
+}
+
+// SetEncryptor turns on client-side envelope encryption: every object written afterwards
+// (recipes, archived versions and the summary index) is sealed with the owner's data key.
+// Plaintext objects written earlier stay readable; recipe-encrypt seals them in place.
+func (db *S3RecipeDB) SetEncryptor(encryptor *envelope.Encryptor) {
+	db.codec.Encryptor = encryptor
+}
+
+// SetCompression sets how objects written afterwards are compressed. Objects are always
+// read whatever their compression; recipe-compress rewrites existing ones.
+func (db *S3RecipeDB) SetCompression(compression Compression) {
+	db.codec.Compression = compression
+}
+
+// SetCodec replaces both the compression and the encryption of objects written afterwards
+func (db *S3RecipeDB) SetCodec(codec ObjectCodec) {
+	db.codec = codec
+}
+
+// ObjectCodecFromEnv builds the codec selected by RECIPE_COMPRESSION and RECIPE_ENCRYPTION
+func ObjectCodecFromEnv(cfg aws.Config) (ObjectCodec, error) {
+	compression, err := ParseCompression(os.Getenv("RECIPE_COMPRESSION"))
+	if err != nil {
+		return ObjectCodec{}, fmt.Errorf("RECIPE_COMPRESSION: %w", err)
+	}
+	encryptor, err := envelope.FromEnv(cfg)
+	if err != nil {
+		return ObjectCodec{}, err
+	}
+	return ObjectCodec{Compression: compression, Encryptor: encryptor}, nil
+}
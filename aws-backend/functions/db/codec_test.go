@@ -0,0 +1,70 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"recipe-archive/envelope"
+	"recipe-archive/models"
+)
+
+func TestObjectCodecRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	keyring, err := envelope.NewKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(`{"id":"r1","title":"Soup","webArchiveHtml":"` + strings.Repeat("<p>stir</p>", 200) + `"}`)
+
+	for _, compression := range []Compression{CompressionNone, CompressionGzip, CompressionZstd} {
+		for _, encryptor := range []*envelope.Encryptor{nil, envelope.NewEncryptor(keyring)} {
+			codec := ObjectCodec{Compression: compression, Encryptor: encryptor}
+			obj, err := codec.Encode(ctx, "u1", "recipes/u1/r1.json", data)
+			if err != nil {
+				t.Fatalf("%q encrypted=%v: encode: %v", compression, encryptor != nil, err)
+			}
+			if compression != CompressionNone && len(obj.Body) >= len(data) {
+				t.Errorf("%q: body not compressed (%d >= %d bytes)", compression, len(obj.Body), len(data))
+			}
+			if encryptor == nil && obj.ContentEncoding != string(compression) {
+				t.Errorf("%q: Content-Encoding %q", compression, obj.ContentEncoding)
+			}
+			if encryptor != nil && (obj.ContentEncoding != "" || !envelope.IsSealed(obj.Body)) {
+				t.Errorf("%q: sealed object has Content-Encoding %q", compression, obj.ContentEncoding)
+			}
+
+			decoded, err := codec.Decode(ctx, "u1", "recipes/u1/r1.json", obj.Body)
+			if err != nil || !bytes.Equal(decoded, data) {
+				t.Errorf("%q encrypted=%v: decode: %v", compression, encryptor != nil, err)
+			}
+		}
+	}
+
+	// Legacy uncompressed objects are read by any codec
+	zstdCodec := ObjectCodec{Compression: CompressionZstd}
+	if decoded, err := zstdCodec.Decode(ctx, "u1", "recipes/u1/r1.json", data); err != nil || !bytes.Equal(decoded, data) {
+		t.Errorf("legacy object: %v", err)
+	}
+}
+
+func TestCompressedStorage(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeS3()
+	fake.objects["recipes/u1/legacy.json"] = []byte(`{"id":"legacy","userId":"u1","title":"Uncompressed"}`)
+	store := NewS3RecipeDB(fake, "bucket")
+	store.SetCompression(CompressionGzip)
+
+	if err := store.CreateRecipe(ctx, &models.Recipe{ID: "r1", UserID: "u1", Title: "Compressed"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if DetectCompression(fake.objects["recipes/u1/r1.json"]) != CompressionGzip {
+		t.Errorf("recipe stored uncompressed")
+	}
+
+	recipes, err := store.ListRecipes(ctx, "u1")
+	if err != nil || len(recipes) != 2 {
+		t.Fatalf("list: got %+v err %v", recipes, err)
+	}
+}
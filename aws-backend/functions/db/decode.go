@@ -0,0 +1,22 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	"recipe-archive/models"
+)
+
+// DecodeRecipe decodes a stored recipe object, upgrading older schema versions in memory.
+// Objects written by newer code fail with models.ErrSchemaTooNew; anything else that
+// cannot be decoded fails with ErrCorrupt. Shared by every JSON-backed RecipeDB.
+func DecodeRecipe(data []byte) (*models.Recipe, error) {
+	recipe, err := models.DecodeStoredRecipe(data)
+	if err != nil {
+		if errors.Is(err, models.ErrSchemaTooNew) {
+			return nil, fmt.Errorf("failed to load recipe: %w", err)
+		}
+		return nil, fmt.Errorf("failed to unmarshal recipe: %w: %w", ErrCorrupt, err)
+	}
+	return recipe, nil
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"time"
+
+	"recipe-archive/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// The DynamoDB change log is one item per write in the recipes table, under the partition
+// {userID}#changes with the same sortable {unixNano}-{random} IDs as the S3 log. Entries carry
+// none of the GSI key attributes, so they never show up in a user's recipe queries.
+//
+// Each entry is written in the same transaction as the write it describes, so it exists
+// exactly when that write succeeded. A transaction prepared earlier can still commit after a
+// later one, so ListChanges holds back entries younger than changeSettleWindow as well.
+
+func changesPartition(userID string) string {
+	return userID + "#changes"
+}
+
+// changeEntry returns the transaction item that records a write in the user's change log
+func (db *DynamoRecipeDB) changeEntry(userID, changeType, recipeID string, version int) (types.TransactWriteItem, error) {
+	seq, err := newChangeSeq()
+	if err != nil {
+		return types.TransactWriteItem{}, err
+	}
+	return types.TransactWriteItem{Put: &types.Put{
+		TableName: aws.String(db.tableName),
+		Item: map[string]types.AttributeValue{
+			"userId":     &types.AttributeValueMemberS{Value: changesPartition(userID)},
+			"id":         &types.AttributeValueMemberS{Value: seq},
+			"changeType": &types.AttributeValueMemberS{Value: changeType},
+			"recipeId":   &types.AttributeValueMemberS{Value: recipeID},
+			"version":    &types.AttributeValueMemberN{Value: strconv.Itoa(version)},
+		},
+	}}, nil
+}
+
+// ListChanges returns the user's change log entries after cursor, oldest first
+func (db *DynamoRecipeDB) ListChanges(ctx context.Context, userID, cursor string, limit int) ([]models.RecipeChange, string, bool, error) {
+	keyCondition := "userId = :partition"
+	values := map[string]types.AttributeValue{
+		":partition": &types.AttributeValueMemberS{Value: changesPartition(userID)},
+	}
+	if cursor != "" {
+		after, err := decodeChangeCursor(cursor)
+		if err != nil {
+			return nil, "", false, err
+		}
+		keyCondition += " AND id > :after"
+		values[":after"] = &types.AttributeValueMemberS{Value: after}
+	}
+
+	output, err := db.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(db.tableName),
+		KeyConditionExpression:    aws.String(keyCondition),
+		ExpressionAttributeValues: values,
+		Limit:                     aws.Int32(int32(limit)),
+		ConsistentRead:            aws.Bool(true),
+	})
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to list recipe changes: %w", err)
+	}
+
+	settled := time.Now().Add(-changeSettleWindow)
+	changes := []models.RecipeChange{}
+	next := cursor
+	for _, item := range output.Items {
+		seq := itemString(item, "id")
+		changedAt, ok := changeSeqTime(seq)
+		if !ok {
+			continue
+		}
+		if changedAt.After(settled) {
+			// Too recent to be final; the client picks it up on its next poll
+			return changes, next, false, nil
+		}
+		version, _ := strconv.Atoi(itemNumber(item, "version"))
+		changes = append(changes, models.RecipeChange{
+			Type:      itemString(item, "changeType"),
+			RecipeID:  itemString(item, "recipeId"),
+			Version:   version,
+			ChangedAt: changedAt,
+		})
+		next = base64.RawURLEncoding.EncodeToString([]byte(seq))
+	}
+	return changes, next, len(output.LastEvaluatedKey) > 0, nil
+}
+
+func itemNumber(item map[string]types.AttributeValue, name string) string {
+	if v, ok := item[name].(*types.AttributeValueMemberN); ok {
+		return v.Value
+	}
+	return ""
+}
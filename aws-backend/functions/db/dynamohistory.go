@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"recipe-archive/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Prior versions are items in the recipes table under the partition
+// {userID}#versions#{recipeID}, with the zero-padded version number as id. The recipe is kept
+// as JSON in the recipe attribute, so an archived copy carries none of the GSI key attributes.
+
+func versionsPartition(userID, recipeID string) string {
+	return fmt.Sprintf("%s#versions#%s", userID, recipeID)
+}
+
+func versionKeyAttributes(userID, recipeID string, version int) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"userId": &types.AttributeValueMemberS{Value: versionsPartition(userID, recipeID)},
+		"id":     &types.AttributeValueMemberS{Value: fmt.Sprintf("%010d", version)},
+	}
+}
+
+// versionItem returns the archive item of recipe under its own version number. Re-archiving
+// the same version overwrites it, so retrying a failed update is harmless.
+func versionItem(recipe *models.Recipe, savedAt time.Time) (map[string]types.AttributeValue, error) {
+	data, err := json.Marshal(recipe)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal recipe version: %w", err)
+	}
+	item := versionKeyAttributes(recipe.UserID, recipe.ID, recipe.Version)
+	item["recipe"] = &types.AttributeValueMemberS{Value: string(data)}
+	item["savedAt"] = &types.AttributeValueMemberS{Value: savedAt.UTC().Format(dynamoTimeFormat)}
+	return item, nil
+}
+
+// ListRecipeVersions returns the archived versions of a recipe, oldest first
+func (db *DynamoRecipeDB) ListRecipeVersions(ctx context.Context, userID, recipeID string) ([]models.RecipeVersionInfo, error) {
+	versions := []models.RecipeVersionInfo{}
+	paginator := dynamodb.NewQueryPaginator(db.client, &dynamodb.QueryInput{
+		TableName:                 aws.String(db.tableName),
+		KeyConditionExpression:    aws.String("userId = :partition"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":partition": &types.AttributeValueMemberS{Value: versionsPartition(userID, recipeID)}},
+		ProjectionExpression:      aws.String("id, savedAt"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list recipe versions: %w", err)
+		}
+		for _, item := range page.Items {
+			version, err := strconv.Atoi(itemString(item, "id"))
+			if err != nil {
+				continue
+			}
+			savedAt, _ := time.Parse(dynamoTimeFormat, itemString(item, "savedAt"))
+			versions = append(versions, models.RecipeVersionInfo{Version: version, SavedAt: savedAt})
+		}
+	}
+	// Zero-padded sort keys come back in version order
+	return versions, nil
+}
+
+// GetRecipeVersion retrieves one archived version of a recipe
+func (db *DynamoRecipeDB) GetRecipeVersion(ctx context.Context, userID, recipeID string, version int) (*models.Recipe, error) {
+	result, err := db.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(db.tableName),
+		Key:       versionKeyAttributes(userID, recipeID, version),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recipe version %d: %w", version, err)
+	}
+	if result.Item == nil {
+		return nil, fmt.Errorf("failed to get recipe version %d: %w", version, ErrNotFound)
+	}
+	return DecodeRecipe([]byte(itemString(result.Item, "recipe")))
+}
+
+// deleteVersions removes every archived version of a recipe
+func (db *DynamoRecipeDB) deleteVersions(ctx context.Context, userID, recipeID string) error {
+	versions, err := db.ListRecipeVersions(ctx, userID, recipeID)
+	if err != nil {
+		return err
+	}
+	for _, v := range versions {
+		_, err := db.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName: aws.String(db.tableName),
+			Key:       versionKeyAttributes(userID, recipeID, v.Version),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete recipe version %d: %w", v.Version, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,515 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"recipe-archive/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoDB index names used by DynamoRecipeDB
+const (
+	SourceURLIndex          = "userId-sourceUrl-index"
+	CanonicalSourceURLIndex = "userId-canonicalSourceUrl-index"
+	UpdatedAtIndex          = "userId-updatedAt-index"
+)
+
+// dynamoTimeFormat is a fixed-width RFC3339 layout so updatedAt sorts lexically in the GSI
+const dynamoTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// DynamoAPI is the subset of the DynamoDB client used by DynamoRecipeDB (satisfied by *dynamodb.Client)
+type DynamoAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+// DynamoRecipeDB implements RecipeDB on a DynamoDB table keyed by userId (partition) and id (sort).
+// Items use the `dynamodb` struct tags on models.Recipe. Two GSIs, both partitioned by userId,
+// support source URL lookup and updatedAt ordering without scanning a user's library. The
+// change log and archived versions share the table under partitions of their own (see
+// dynamochanges.go and dynamohistory.go).
+type DynamoRecipeDB struct {
+	client    DynamoAPI
+	tableName string
+}
+
+var (
+	_ RecipeDB      = (*DynamoRecipeDB)(nil)
+	_ TrashPurger   = (*DynamoRecipeDB)(nil)
+	_ ChangeLog     = (*DynamoRecipeDB)(nil)
+	_ RecipeHistory = (*DynamoRecipeDB)(nil)
+)
+
+// NewDynamoRecipeDB creates a new DynamoDB-based recipe database
+func NewDynamoRecipeDB(client DynamoAPI, tableName string) *DynamoRecipeDB {
+	return &DynamoRecipeDB{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+// EnsureTable creates the recipes table and its GSIs (on-demand billing) if it does not exist.
+// Intended for DynamoDB Local and tests; production tables are created by the CDK stack.
+func (db *DynamoRecipeDB) EnsureTable(ctx context.Context) error {
+	keySchema := func(sortKey string) []types.KeySchemaElement {
+		return []types.KeySchemaElement{
+			{AttributeName: aws.String("userId"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String(sortKey), KeyType: types.KeyTypeRange},
+		}
+	}
+
+	_, err := db.client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:   aws.String(db.tableName),
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("userId"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("id"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("sourceUrl"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("canonicalSourceUrl"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("updatedAt"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: keySchema("id"),
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+			{
+				IndexName:  aws.String(SourceURLIndex),
+				KeySchema:  keySchema("sourceUrl"),
+				Projection: &types.Projection{ProjectionType: types.ProjectionTypeKeysOnly},
+			},
+			{
+				IndexName:  aws.String(CanonicalSourceURLIndex),
+				KeySchema:  keySchema("canonicalSourceUrl"),
+				Projection: &types.Projection{ProjectionType: types.ProjectionTypeKeysOnly},
+			},
+			{
+				IndexName:  aws.String(UpdatedAtIndex),
+				KeySchema:  keySchema("updatedAt"),
+				Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+			},
+		},
+	})
+	var inUse *types.ResourceInUseException
+	if err != nil && !errors.As(err, &inUse) {
+		return fmt.Errorf("failed to create recipes table: %w", err)
+	}
+	return nil
+}
+
+// GetRecipe retrieves a recipe by ID and userID
+func (db *DynamoRecipeDB) GetRecipe(ctx context.Context, userID, recipeID string) (*models.Recipe, error) {
+	result, err := db.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(db.tableName),
+		Key:            recipeKeyAttributes(userID, recipeID),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recipe: %w", err)
+	}
+	if result.Item == nil {
+		return nil, fmt.Errorf("failed to get recipe %s: %w", recipeID, ErrNotFound)
+	}
+
+	return unmarshalRecipeItem(result.Item)
+}
+
+// ListRecipes returns every recipe for a user, following query pagination
+func (db *DynamoRecipeDB) ListRecipes(ctx context.Context, userID string) ([]models.Recipe, error) {
+	var recipes []models.Recipe
+	paginator := dynamodb.NewQueryPaginator(db.client, &dynamodb.QueryInput{
+		TableName:                 aws.String(db.tableName),
+		KeyConditionExpression:    aws.String("userId = :userId"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":userId": &types.AttributeValueMemberS{Value: userID}},
+	})
+
+	var failures []KeyFailure
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list recipes: %w", err)
+		}
+		for _, item := range page.Items {
+			recipe, err := unmarshalRecipeItem(item)
+			if err != nil {
+				failures = append(failures, KeyFailure{RecipeID: itemString(item, "id"), Err: err})
+				continue
+			}
+			recipes = append(recipes, *recipe)
+		}
+	}
+
+	if len(failures) > 0 {
+		return recipes, &PartialListError{Failures: failures}
+	}
+	return recipes, nil
+}
+
+// ListRecipeSummaries returns the summary projection of every recipe for a user
+func (db *DynamoRecipeDB) ListRecipeSummaries(ctx context.Context, userID string) ([]models.RecipeSummary, error) {
+	recipes, err := db.ListRecipes(ctx, userID)
+	var partial *PartialListError
+	if err != nil && !errors.As(err, &partial) {
+		return nil, err
+	}
+
+	summaries := make([]models.RecipeSummary, 0, len(recipes))
+	for i := range recipes {
+		summaries = append(summaries, recipes[i].Summary())
+	}
+	return summaries, err
+}
+
+// ListRecipesPage returns one page of a user's non-deleted recipes, most recently updated
+// first, using the updatedAt GSI. The cursor is the opaque NextCursor of the previous page.
+// DynamoDB applies Limit before the trash filter, so the GSI is queried until the page is
+// full or the user's items run out.
+func (db *DynamoRecipeDB) ListRecipesPage(ctx context.Context, userID string, limit int, cursor string) ([]models.Recipe, models.Pagination, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(db.tableName),
+		IndexName:              aws.String(UpdatedAtIndex),
+		KeyConditionExpression: aws.String("userId = :userId"),
+		FilterExpression:       aws.String("isDeleted <> :deleted"),
+		ScanIndexForward:       aws.Bool(false),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userId":  &types.AttributeValueMemberS{Value: userID},
+			":deleted": &types.AttributeValueMemberBOOL{Value: true},
+		},
+	}
+	if cursor != "" {
+		startKey, err := decodeDynamoCursor(cursor)
+		if err != nil {
+			return nil, models.Pagination{}, err
+		}
+		input.ExclusiveStartKey = startKey
+	}
+
+	recipes := make([]models.Recipe, 0, limit)
+	var lastItem map[string]types.AttributeValue
+	for {
+		input.Limit = aws.Int32(int32(limit - len(recipes)))
+		result, err := db.client.Query(ctx, input)
+		if err != nil {
+			return nil, models.Pagination{}, fmt.Errorf("failed to query recipes: %w", err)
+		}
+		for _, item := range result.Items {
+			recipe, err := unmarshalRecipeItem(item)
+			if err != nil {
+				return nil, models.Pagination{}, err
+			}
+			recipes = append(recipes, *recipe)
+			lastItem = item
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return recipes, models.Pagination{}, nil
+		}
+		if len(recipes) >= limit {
+			break
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	// Continue after the last recipe returned; the GSI key includes the table key
+	next, err := encodeDynamoCursor(map[string]types.AttributeValue{
+		"userId":    lastItem["userId"],
+		"id":        lastItem["id"],
+		"updatedAt": lastItem["updatedAt"],
+	})
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+	return recipes, models.Pagination{HasMore: true, NextCursor: &next}, nil
+}
+
+// FindBySourceURL returns the user's recipe saved from sourceURL or any URL with the same
+// canonical form (see models.CanonicalSourceURL). Items written before the canonicalSourceUrl
+// attribute existed are found through the exact sourceUrl GSI.
+func (db *DynamoRecipeDB) FindBySourceURL(ctx context.Context, userID, sourceURL string) (*models.Recipe, error) {
+	recipeID, err := db.querySourceURLIndex(ctx, CanonicalSourceURLIndex, "canonicalSourceUrl", userID, models.CanonicalSourceURL(sourceURL))
+	if err == nil && recipeID == "" {
+		recipeID, err = db.querySourceURLIndex(ctx, SourceURLIndex, "sourceUrl", userID, sourceURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if recipeID == "" {
+		return nil, fmt.Errorf("no recipe for source URL %s: %w", sourceURL, ErrNotFound)
+	}
+
+	// The GSIs are keys-only; read the full item from the table
+	return db.GetRecipe(ctx, userID, recipeID)
+}
+
+// querySourceURLIndex returns the ID of the first recipe whose attribute equals value, or ""
+func (db *DynamoRecipeDB) querySourceURLIndex(ctx context.Context, indexName, attribute, userID, value string) (string, error) {
+	result, err := db.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(db.tableName),
+		IndexName:              aws.String(indexName),
+		KeyConditionExpression: aws.String("userId = :userId AND #url = :url"),
+		ExpressionAttributeNames: map[string]string{
+			"#url": attribute,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userId": &types.AttributeValueMemberS{Value: userID},
+			":url":    &types.AttributeValueMemberS{Value: value},
+		},
+		Limit: aws.Int32(1),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to query recipes by source URL: %w", err)
+	}
+	if len(result.Items) == 0 {
+		return "", nil
+	}
+	return itemString(result.Items[0], "id"), nil
+}
+
+// CreateRecipe stores a recipe item
+func (db *DynamoRecipeDB) CreateRecipe(ctx context.Context, recipe *models.Recipe) error {
+	return db.putRecipe(ctx, recipe, models.ChangeCreate)
+}
+
+// UpdateRecipe updates an existing recipe (overwrites the item)
+func (db *DynamoRecipeDB) UpdateRecipe(ctx context.Context, recipe *models.Recipe) error {
+	return db.putRecipe(ctx, recipe, models.ChangeUpdate)
+}
+
+// putRecipe stores a recipe item and its change log entry (a delete if it moves the recipe
+// to the trash) in one transaction
+func (db *DynamoRecipeDB) putRecipe(ctx context.Context, recipe *models.Recipe, changeType string) error {
+	if recipe.IsDeleted {
+		changeType = models.ChangeDelete
+	}
+	item, err := marshalRecipeItem(recipe)
+	if err != nil {
+		return err
+	}
+	change, err := db.changeEntry(recipe.UserID, changeType, recipe.ID, recipe.Version)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Put: &types.Put{TableName: aws.String(db.tableName), Item: item}},
+			change,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store recipe: %w", err)
+	}
+	return nil
+}
+
+// UpdateRecipeIfVersion stores recipe only if the stored item is still at expectedVersion.
+// The replaced copy is archived and the change recorded in the same transaction, whose
+// condition expression makes the version check and the writes atomic.
+func (db *DynamoRecipeDB) UpdateRecipeIfVersion(ctx context.Context, recipe *models.Recipe, expectedVersion int) error {
+	current, err := db.GetRecipe(ctx, recipe.UserID, recipe.ID)
+	if err != nil {
+		return err
+	}
+	if current.Version != expectedVersion {
+		return &VersionConflictError{Current: current}
+	}
+
+	item, err := marshalRecipeItem(recipe)
+	if err != nil {
+		return err
+	}
+	archived, err := versionItem(current, time.Now())
+	if err != nil {
+		return err
+	}
+	changeType := models.ChangeUpdate
+	if recipe.IsDeleted {
+		changeType = models.ChangeDelete
+	}
+	change, err := db.changeEntry(recipe.UserID, changeType, recipe.ID, recipe.Version)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Put: &types.Put{
+				TableName:                           aws.String(db.tableName),
+				Item:                                item,
+				ConditionExpression:                 aws.String("attribute_exists(id) AND version = :expected"),
+				ExpressionAttributeValues:           map[string]types.AttributeValue{":expected": &types.AttributeValueMemberN{Value: strconv.Itoa(expectedVersion)}},
+				ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+			}},
+			{Put: &types.Put{TableName: aws.String(db.tableName), Item: archived}},
+			change,
+		},
+	})
+
+	if stored, failed := recipeConditionFailed(err); failed {
+		// Someone else wrote in between; report their copy
+		if len(stored) == 0 {
+			return fmt.Errorf("failed to update recipe %s: %w", recipe.ID, ErrNotFound)
+		}
+		latest, decodeErr := unmarshalRecipeItem(stored)
+		if decodeErr != nil {
+			return decodeErr
+		}
+		return &VersionConflictError{Current: latest}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store recipe: %w", err)
+	}
+	return nil
+}
+
+// DeleteRecipe removes a recipe item and its archived versions
+func (db *DynamoRecipeDB) DeleteRecipe(ctx context.Context, userID, recipeID string) error {
+	return db.deleteRecipe(ctx, &types.Delete{
+		TableName: aws.String(db.tableName),
+		Key:       recipeKeyAttributes(userID, recipeID),
+	}, userID, recipeID)
+}
+
+// DeleteTrashedRecipe removes a recipe item only if it is still in the trash at version,
+// using a condition expression so a concurrent restore is never deleted
+func (db *DynamoRecipeDB) DeleteTrashedRecipe(ctx context.Context, userID, recipeID string, version int) error {
+	err := db.deleteRecipe(ctx, &types.Delete{
+		TableName:           aws.String(db.tableName),
+		Key:                 recipeKeyAttributes(userID, recipeID),
+		ConditionExpression: aws.String("isDeleted = :deleted AND version = :expected"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":deleted":  &types.AttributeValueMemberBOOL{Value: true},
+			":expected": &types.AttributeValueMemberN{Value: strconv.Itoa(version)},
+		},
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}, userID, recipeID)
+
+	if stored, failed := recipeConditionFailed(err); failed {
+		if len(stored) == 0 {
+			return fmt.Errorf("failed to delete recipe %s: %w", recipeID, ErrNotFound)
+		}
+		return fmt.Errorf("recipe %s is no longer in the trash at version %d: %w", recipeID, version, ErrConflict)
+	}
+	return err
+}
+
+// deleteRecipe runs a recipe item delete together with its change log entry, then removes
+// the recipe's archived versions
+func (db *DynamoRecipeDB) deleteRecipe(ctx context.Context, remove *types.Delete, userID, recipeID string) error {
+	change, err := db.changeEntry(userID, models.ChangeDelete, recipeID, 0)
+	if err != nil {
+		return err
+	}
+	_, err = db.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{{Delete: remove}, change},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete recipe: %w", err)
+	}
+
+	if err := db.deleteVersions(ctx, userID, recipeID); err != nil {
+		// The recipe itself is gone; orphaned versions are unreachable and only cost storage
+		fmt.Printf("⚠️ Failed to delete versions of recipe %s: %v\n", recipeID, err)
+	}
+	return nil
+}
+
+// recipeConditionFailed reports whether a transaction was cancelled by the condition on its
+// first item, the recipe, and returns the stored item it was checked against (empty if none)
+func recipeConditionFailed(err error) (map[string]types.AttributeValue, bool) {
+	var canceled *types.TransactionCanceledException
+	if !errors.As(err, &canceled) || len(canceled.CancellationReasons) == 0 {
+		return nil, false
+	}
+	reason := canceled.CancellationReasons[0]
+	return reason.Item, aws.ToString(reason.Code) == "ConditionalCheckFailed"
+}
+
+func recipeKeyAttributes(userID, recipeID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"userId": &types.AttributeValueMemberS{Value: userID},
+		"id":     &types.AttributeValueMemberS{Value: recipeID},
+	}
+}
+
+func marshalRecipeItem(recipe *models.Recipe) (map[string]types.AttributeValue, error) {
+	stamped := *recipe
+	stamped.SchemaVersion = models.CurrentSchemaVersion
+	item, err := attributevalue.MarshalMapWithOptions(stamped, func(o *attributevalue.EncoderOptions) {
+		o.TagKey = "dynamodb"
+		o.EncodeTime = func(t time.Time) (types.AttributeValue, error) {
+			return &types.AttributeValueMemberS{Value: t.UTC().Format(dynamoTimeFormat)}, nil
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal recipe: %w", err)
+	}
+	// GSI key attributes must be non-empty, so recipes without a source URL are left out of the index
+	if canonical := models.CanonicalSourceURL(recipe.SourceURL); canonical != "" {
+		item["canonicalSourceUrl"] = &types.AttributeValueMemberS{Value: canonical}
+	}
+	return item, nil
+}
+
+func unmarshalRecipeItem(item map[string]types.AttributeValue) (*models.Recipe, error) {
+	var recipe models.Recipe
+	err := attributevalue.UnmarshalMapWithOptions(item, &recipe, func(o *attributevalue.DecoderOptions) {
+		o.TagKey = "dynamodb"
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal recipe: %w: %w", ErrCorrupt, err)
+	}
+	if recipe.SchemaVersion > models.CurrentSchemaVersion {
+		return nil, fmt.Errorf("failed to load recipe: %w: stored version %d, supported up to %d",
+			models.ErrSchemaTooNew, recipe.SchemaVersion, models.CurrentSchemaVersion)
+	}
+	return &recipe, nil
+}
+
+func itemString(item map[string]types.AttributeValue, name string) string {
+	if v, ok := item[name].(*types.AttributeValueMemberS); ok {
+		return v.Value
+	}
+	return ""
+}
+
+// encodeDynamoCursor turns a LastEvaluatedKey (all string attributes for our key schema and
+// GSIs) into an opaque URL-safe cursor
+func encodeDynamoCursor(key map[string]types.AttributeValue) (string, error) {
+	plain := make(map[string]string, len(key))
+	for name := range key {
+		plain[name] = itemString(key, name)
+	}
+	data, err := json.Marshal(plain)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeDynamoCursor(cursor string) (map[string]types.AttributeValue, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	var plain map[string]string
+	if err := json.Unmarshal(data, &plain); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	key := make(map[string]types.AttributeValue, len(plain))
+	for name, value := range plain {
+		key[name] = &types.AttributeValueMemberS{Value: value}
+	}
+	return key, nil
+}
@@ -0,0 +1,297 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"recipe-archive/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestDynamoCursorRoundTrip(t *testing.T) {
+	key := map[string]types.AttributeValue{
+		"userId":    &types.AttributeValueMemberS{Value: "u1"},
+		"id":        &types.AttributeValueMemberS{Value: "r1"},
+		"updatedAt": &types.AttributeValueMemberS{Value: "2025-01-02T03:04:05.000000000Z"},
+	}
+	cursor, err := encodeDynamoCursor(key)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	decoded, err := decodeDynamoCursor(cursor)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	for name := range key {
+		if itemString(decoded, name) != itemString(key, name) {
+			t.Errorf("%s: got %q want %q", name, itemString(decoded, name), itemString(key, name))
+		}
+	}
+	if _, err := decodeDynamoCursor("not a cursor!"); err == nil {
+		t.Error("expected an error for a malformed cursor")
+	}
+}
+
+func TestMarshalRecipeItemUsesDynamoTags(t *testing.T) {
+	photo := "https://example.com/p.jpg"
+	recipe := &models.Recipe{ID: "r1", UserID: "u1", Title: "Soup", MainPhotoURL: &photo,
+		UpdatedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)}
+
+	item, err := marshalRecipeItem(recipe)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if itemString(item, "userId") != "u1" || itemString(item, "mainPhotoUrl") != photo {
+		t.Errorf("unexpected item attributes: %v", item)
+	}
+	if got := itemString(item, "updatedAt"); got != "2025-01-02T03:04:05.000000000Z" {
+		t.Errorf("updatedAt should be fixed-width for GSI ordering, got %q", got)
+	}
+	if _, ok := item["prepTimeMinutes"]; ok {
+		t.Error("omitempty fields should not be written")
+	}
+
+	back, err := unmarshalRecipeItem(item)
+	if err != nil || back.Title != "Soup" || !back.UpdatedAt.Equal(recipe.UpdatedAt) {
+		t.Errorf("round trip failed: %+v (err %v)", back, err)
+	}
+}
+
+// pagingDynamo serves Query from items already in updatedAt order, applying Limit before
+// the isDeleted filter as DynamoDB does
+type pagingDynamo struct {
+	DynamoAPI
+	items   []map[string]types.AttributeValue
+	queries int
+}
+
+func (f *pagingDynamo) Query(ctx context.Context, in *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	f.queries++
+	start := 0
+	if in.ExclusiveStartKey != nil {
+		for i, item := range f.items {
+			if itemString(item, "id") == itemString(in.ExclusiveStartKey, "id") {
+				start = i + 1
+			}
+		}
+	}
+	end := start + int(aws.ToInt32(in.Limit))
+	if end > len(f.items) {
+		end = len(f.items)
+	}
+
+	out := &dynamodb.QueryOutput{}
+	for _, item := range f.items[start:end] {
+		if deleted, ok := item["isDeleted"].(*types.AttributeValueMemberBOOL); !ok || !deleted.Value {
+			out.Items = append(out.Items, item)
+		}
+	}
+	if end < len(f.items) {
+		last := f.items[end-1]
+		out.LastEvaluatedKey = map[string]types.AttributeValue{"userId": last["userId"], "id": last["id"], "updatedAt": last["updatedAt"]}
+	}
+	return out, nil
+}
+
+func TestDynamoListRecipesPageSkipsTrash(t *testing.T) {
+	client := &pagingDynamo{}
+	base := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	// r9 is the newest; every recipe but r0, r4 and r5 is in the trash
+	for i := 9; i >= 0; i-- {
+		recipe := &models.Recipe{ID: fmt.Sprintf("r%d", i), UserID: "u1", UpdatedAt: base.Add(time.Duration(i) * time.Minute),
+			IsDeleted: i != 0 && i != 4 && i != 5}
+		item, err := marshalRecipeItem(recipe)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		client.items = append(client.items, item)
+	}
+	store := NewDynamoRecipeDB(client, "recipes")
+
+	var pages [][]string
+	cursor := ""
+	for {
+		page, pagination, err := store.ListRecipesPage(context.Background(), "u1", 2, cursor)
+		if err != nil {
+			t.Fatalf("list page: %v", err)
+		}
+		var ids []string
+		for _, r := range page {
+			ids = append(ids, r.ID)
+		}
+		pages = append(pages, ids)
+		if !pagination.HasMore {
+			break
+		}
+		cursor = *pagination.NextCursor
+	}
+	if fmt.Sprint(pages) != "[[r5 r4] [r0]]" {
+		t.Errorf("pages = %v, want full pages of active recipes [[r5 r4] [r0]]", pages)
+	}
+	if _, _, err := store.ListRecipesPage(context.Background(), "u1", 2, "not a cursor!"); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("malformed cursor: got %v want ErrInvalidCursor", err)
+	}
+}
+
+// txDynamo serves GetItem from a single stored recipe and records TransactWriteItems,
+// cancelling a transaction whose first item's condition is set when conflict is
+type txDynamo struct {
+	DynamoAPI
+	stored       map[string]types.AttributeValue
+	conflict     bool
+	transactions [][]types.TransactWriteItem
+}
+
+func (f *txDynamo) GetItem(ctx context.Context, in *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: f.stored}, nil
+}
+
+func (f *txDynamo) TransactWriteItems(ctx context.Context, in *dynamodb.TransactWriteItemsInput, _ ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	f.transactions = append(f.transactions, in.TransactItems)
+	if f.conflict && in.TransactItems[0].Put != nil && in.TransactItems[0].Put.ConditionExpression != nil {
+		return nil, &types.TransactionCanceledException{CancellationReasons: []types.CancellationReason{
+			{Code: aws.String("ConditionalCheckFailed"), Item: f.stored},
+			{Code: aws.String("None")},
+			{Code: aws.String("None")},
+		}}
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func TestDynamoUpdateArchivesAndRecordsChangeAtomically(t *testing.T) {
+	ctx := context.Background()
+	stored, err := marshalRecipeItem(&models.Recipe{ID: "r1", UserID: "u1", Title: "Soup", Version: 1})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	client := &txDynamo{stored: stored}
+	store := NewDynamoRecipeDB(client, "recipes")
+
+	if err := store.UpdateRecipeIfVersion(ctx, &models.Recipe{ID: "r1", UserID: "u1", Title: "Stew", Version: 2}, 1); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if len(client.transactions) != 1 || len(client.transactions[0]) != 3 {
+		t.Fatalf("transactions = %v, want one with the recipe, its archived copy and a change", client.transactions)
+	}
+	write := client.transactions[0]
+	if got := itemString(write[1].Put.Item, "userId"); got != "u1#versions#r1" || itemString(write[1].Put.Item, "id") != "0000000001" {
+		t.Errorf("archived copy stored under %s/%s", got, itemString(write[1].Put.Item, "id"))
+	}
+	change := write[2].Put.Item
+	if itemString(change, "userId") != "u1#changes" || itemString(change, "changeType") != models.ChangeUpdate ||
+		itemString(change, "recipeId") != "r1" || itemNumber(change, "version") != "2" {
+		t.Errorf("change entry = %v", change)
+	}
+	for _, item := range []map[string]types.AttributeValue{write[1].Put.Item, change} {
+		if _, ok := item["updatedAt"]; ok {
+			t.Errorf("item %v carries a GSI key attribute", item)
+		}
+	}
+
+	// The stored copy moves on between the read and the transaction
+	client.conflict = true
+	err = store.UpdateRecipeIfVersion(ctx, &models.Recipe{ID: "r1", UserID: "u1", Title: "Stew", Version: 2}, 1)
+	var conflict *VersionConflictError
+	if !errors.As(err, &conflict) || conflict.Current.Title != "Soup" {
+		t.Errorf("cancelled update: got %v, want a VersionConflictError with the stored recipe", err)
+	}
+}
+
+// TestDynamoRecipeDBAgainstLocal runs against DynamoDB Local, e.g.
+//
+//	docker run -p 8000:8000 amazon/dynamodb-local
+//	DYNAMODB_LOCAL_ENDPOINT=http://localhost:8000 go test ./db -run Dynamo
+func TestDynamoRecipeDBAgainstLocal(t *testing.T) {
+	endpoint := os.Getenv("DYNAMODB_LOCAL_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_LOCAL_ENDPOINT not set")
+	}
+
+	ctx := context.Background()
+	client := dynamodb.New(dynamodb.Options{
+		Region:       "us-west-2",
+		BaseEndpoint: aws.String(endpoint),
+		Credentials:  aws.AnonymousCredentials{},
+	})
+	store := NewDynamoRecipeDB(client, fmt.Sprintf("recipes-test-%d", time.Now().UnixNano()))
+	if err := store.EnsureTable(ctx); err != nil {
+		t.Fatalf("ensure table: %v", err)
+	}
+
+	base := time.Now().UTC()
+	for i := 0; i < 5; i++ {
+		recipe := &models.Recipe{ID: fmt.Sprintf("r%d", i), UserID: "u1", Title: fmt.Sprintf("Recipe %d", i),
+			SourceURL: fmt.Sprintf("https://example.com/%d", i), UpdatedAt: base.Add(time.Duration(i) * time.Minute), Version: 1}
+		if err := store.CreateRecipe(ctx, recipe); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+
+	found, err := store.FindBySourceURL(ctx, "u1", "https://example.com/3")
+	if err != nil || found.ID != "r3" {
+		t.Errorf("find by source URL: got %+v err %v", found, err)
+	}
+	if found, err := store.FindBySourceURL(ctx, "u1", "http://www.example.com/3?utm_source=x"); err != nil || found.ID != "r3" {
+		t.Errorf("find by canonical source URL: got %+v err %v", found, err)
+	}
+
+	var seen []string
+	cursor := ""
+	for {
+		page, pagination, err := store.ListRecipesPage(ctx, "u1", 2, cursor)
+		if err != nil {
+			t.Fatalf("list page: %v", err)
+		}
+		for _, r := range page {
+			seen = append(seen, r.ID)
+		}
+		if !pagination.HasMore {
+			break
+		}
+		cursor = *pagination.NextCursor
+	}
+	if fmt.Sprint(seen) != "[r4 r3 r2 r1 r0]" {
+		t.Errorf("pages should be ordered by updatedAt desc, got %v", seen)
+	}
+
+	err = store.UpdateRecipeIfVersion(ctx, &models.Recipe{ID: "r0", UserID: "u1", Version: 3}, 2)
+	var conflict *VersionConflictError
+	if !errors.As(err, &conflict) || conflict.Current.Version != 1 {
+		t.Errorf("stale update: got %v", err)
+	}
+	if _, err := store.GetRecipe(ctx, "u1", "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing recipe: got %v want ErrNotFound", err)
+	}
+
+	if err := store.UpdateRecipeIfVersion(ctx, &models.Recipe{ID: "r0", UserID: "u1", Title: "Renamed", Version: 2}, 1); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	versions, err := store.ListRecipeVersions(ctx, "u1", "r0")
+	if err != nil || len(versions) != 1 || versions[0].Version != 1 {
+		t.Errorf("versions: got %+v err %v", versions, err)
+	}
+	if old, err := store.GetRecipeVersion(ctx, "u1", "r0", 1); err != nil || old.Title != "Recipe 0" {
+		t.Errorf("version 1: got %+v err %v", old, err)
+	}
+	if all, err := store.ListRecipes(ctx, "u1"); err != nil || len(all) != 5 {
+		t.Errorf("change and version items leaked into the recipes: got %d err %v", len(all), err)
+	}
+
+	saved := changeSettleWindow
+	changeSettleWindow = 0
+	defer func() { changeSettleWindow = saved }()
+	changes, cursor, _, err := store.ListChanges(ctx, "u1", "", 100)
+	if err != nil || len(changes) != 6 || changes[5].Type != models.ChangeUpdate || changes[5].RecipeID != "r0" {
+		t.Errorf("changes: got %+v err %v", changes, err)
+	}
+	if more, _, _, err := store.ListChanges(ctx, "u1", cursor, 100); err != nil || len(more) != 0 {
+		t.Errorf("changes after cursor: got %+v err %v", more, err)
+	}
+}
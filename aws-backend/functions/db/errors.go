@@ -0,0 +1,57 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"recipe-archive/models"
+)
+
+// Sentinel errors shared by every RecipeDB implementation. Backends wrap their native
+// errors with these so callers can use errors.Is regardless of the storage engine.
+var (
+	// ErrNotFound means the requested recipe does not exist
+	ErrNotFound = errors.New("recipe not found")
+	// ErrConflict means a conditional write lost against a concurrent change
+	ErrConflict = errors.New("recipe conflict")
+	// ErrCorrupt means a stored recipe exists but could not be decoded
+	ErrCorrupt = errors.New("recipe data corrupt")
+	// ErrInvalidCursor means a pagination or change log cursor was not issued by this backend
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// KeyFailure records a single recipe that could not be loaded
+type KeyFailure struct {
+	RecipeID string
+	Err      error
+}
+
+// PartialListError is returned by ListRecipes alongside the recipes that did load
+// when one or more objects failed. Callers may treat it as a warning.
+type PartialListError struct {
+	Failures []KeyFailure
+}
+
+func (e *PartialListError) Error() string {
+	parts := make([]string, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		parts = append(parts, fmt.Sprintf("%s: %v", f.RecipeID, f.Err))
+	}
+	return fmt.Sprintf("failed to load %d recipe(s): %s", len(e.Failures), strings.Join(parts, "; "))
+}
+
+// VersionConflictError is returned by conditional updates when the stored recipe is no
+// longer at the version the caller edited. Current holds the server's copy.
+type VersionConflictError struct {
+	Current *models.Recipe
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("recipe %s has been modified (current version %d)", e.Current.ID, e.Current.Version)
+}
+
+// Is makes errors.Is(err, ErrConflict) match version conflicts
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"sync"
+
+	"recipe-archive/models"
+)
+
+// GetRecipes loads the given recipes with at most concurrency parallel GetRecipe calls.
+// Results keep the order of recipeIDs; recipes that fail to load are reported in a
+// *PartialListError returned alongside the ones that succeeded. If ctx is done the
+// remaining fetches are abandoned and ctx.Err() is returned.
+func GetRecipes(ctx context.Context, store RecipeDB, userID string, recipeIDs []string, concurrency int) ([]models.Recipe, error) {
+	type result struct {
+		recipe *models.Recipe
+		err    error
+	}
+	results := make([]result, len(recipeIDs))
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(recipeIDs) {
+		concurrency = len(recipeIDs)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				recipe, err := store.GetRecipe(ctx, userID, recipeIDs[i])
+				results[i] = result{recipe: recipe, err: err}
+			}
+		}()
+	}
+dispatch:
+	for i := range recipeIDs {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	recipes := make([]models.Recipe, 0, len(recipeIDs))
+	var failures []KeyFailure
+	for i, res := range results {
+		if res.err != nil {
+			failures = append(failures, KeyFailure{RecipeID: recipeIDs[i], Err: res.err})
+			continue
+		}
+		recipes = append(recipes, *res.recipe)
+	}
+
+	if len(failures) > 0 {
+		return recipes, &PartialListError{Failures: failures}
+	}
+	return recipes, nil
+}
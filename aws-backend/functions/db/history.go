@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"recipe-archive/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Prior versions live next to the recipe as recipes/{userID}/{recipeID}/versions/{n}.json.
+// listRecipeObjects skips nested keys, so archived versions never show up as recipes.
+
+var _ RecipeHistory = (*S3RecipeDB)(nil)
+
+func versionsPrefix(userID, recipeID string) string {
+	return fmt.Sprintf("recipes/%s/%s/versions/", userID, recipeID)
+}
+
+// archiveVersion stores recipe under its own version number. Re-archiving the same
+// version overwrites it, so retrying a failed update is harmless.
+func (db *S3RecipeDB) archiveVersion(ctx context.Context, recipe *models.Recipe) error {
+	data, err := json.Marshal(recipe)
+	if err != nil {
+		return fmt.Errorf("failed to marshal recipe version: %w", err)
+	}
+	key := fmt.Sprintf("%s%d.json", versionsPrefix(recipe.UserID, recipe.ID), recipe.Version)
+	obj, err := db.codec.Encode(ctx, recipe.UserID, key, data)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.client.PutObject(ctx, obj.PutObjectInput(db.bucketName, key))
+	if err != nil {
+		return fmt.Errorf("failed to archive recipe version %d: %w", recipe.Version, mapS3Error(err))
+	}
+	return nil
+}
+
+// ListRecipeVersions returns the archived versions of a recipe, oldest first
+func (db *S3RecipeDB) ListRecipeVersions(ctx context.Context, userID, recipeID string) ([]models.RecipeVersionInfo, error) {
+	prefix := versionsPrefix(userID, recipeID)
+
+	versions := []models.RecipeVersionInfo{}
+	paginator := s3.NewListObjectsV2Paginator(db.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(db.bucketName),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list recipe versions: %w", err)
+		}
+		for _, obj := range page.Contents {
+			name := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(obj.Key), prefix), ".json")
+			version, err := strconv.Atoi(name)
+			if err != nil {
+				continue
+			}
+			versions = append(versions, models.RecipeVersionInfo{Version: version, SavedAt: aws.ToTime(obj.LastModified)})
+		}
+	}
+
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	return versions, nil
+}
+
+// GetRecipeVersion retrieves one archived version of a recipe
+func (db *S3RecipeDB) GetRecipeVersion(ctx context.Context, userID, recipeID string, version int) (*models.Recipe, error) {
+	key := fmt.Sprintf("%s%d.json", versionsPrefix(userID, recipeID), version)
+	result, err := db.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(db.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recipe version %d: %w", version, mapS3Error(err))
+	}
+	defer result.Body.Close()
+
+	data, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recipe version data: %w", err)
+	}
+	if data, err = db.codec.Decode(ctx, userID, key, data); err != nil {
+		return nil, err
+	}
+
+	return DecodeRecipe(data)
+}
+
+// deleteVersions removes every archived version of a recipe
+func (db *S3RecipeDB) deleteVersions(ctx context.Context, userID, recipeID string) error {
+	versions, err := db.ListRecipeVersions(ctx, userID, recipeID)
+	if err != nil {
+		return err
+	}
+	for _, v := range versions {
+		_, err := db.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(db.bucketName),
+			Key:    aws.String(fmt.Sprintf("%s%d.json", versionsPrefix(userID, recipeID), v.Version)),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete recipe version %d: %w", v.Version, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"recipe-archive/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// RecipeIndex is the per-user summary index stored at recipes/{userID}/_index.json.
+// Each entry remembers the ETag of the recipe object it was built from so that
+// drift (writes that bypassed S3RecipeDB, lost index updates) can be detected
+// from a cheap object listing and repaired without re-reading unchanged recipes.
+// SourceURLs maps canonical source URLs (see models.CanonicalSourceURL) to recipe IDs
+// for duplicate detection; it is derived from Entries whenever the index is saved.
+type RecipeIndex struct {
+	UpdatedAt  time.Time             `json:"updatedAt"`
+	Entries    map[string]IndexEntry `json:"entries"`
+	SourceURLs map[string]string     `json:"sourceUrls,omitempty"`
+}
+
+// IndexEntry is a recipe summary plus the ETag of the object it summarizes
+type IndexEntry struct {
+	Summary models.RecipeSummary `json:"summary"`
+	ETag    string               `json:"etag"`
+}
+
+var _ SourceURLFinder = (*S3RecipeDB)(nil)
+
+func indexKey(userID string) string {
+	return fmt.Sprintf("recipes/%s/_index.json", userID)
+}
+
+// ListRecipeSummaries returns the summaries of every recipe for a user from the index,
+// reconciling it against the object listing and rewriting it if it has drifted
+func (db *S3RecipeDB) ListRecipeSummaries(ctx context.Context, userID string) ([]models.RecipeSummary, error) {
+	objects, err := db.listRecipeObjects(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := db.loadIndex(ctx, userID)
+	if err != nil {
+		// A missing or unreadable index is rebuilt from scratch
+		index = &RecipeIndex{Entries: map[string]IndexEntry{}}
+	}
+
+	drifted := len(index.Entries) != len(objects)
+	var stale []string
+	etags := make(map[string]string, len(objects))
+	for _, obj := range objects {
+		etags[obj.recipeID] = obj.etag
+		if entry, ok := index.Entries[obj.recipeID]; !ok || entry.ETag != obj.etag {
+			stale = append(stale, obj.recipeID)
+		}
+	}
+	for recipeID := range index.Entries {
+		if _, ok := etags[recipeID]; !ok {
+			delete(index.Entries, recipeID)
+			drifted = true
+		}
+	}
+
+	var listErr error
+	if len(stale) > 0 {
+		drifted = true
+		refreshed, err := GetRecipes(ctx, db, userID, stale, db.concurrency)
+		var partial *PartialListError
+		if err != nil && !errors.As(err, &partial) {
+			return nil, err
+		}
+		listErr = err
+		for _, recipeID := range stale {
+			delete(index.Entries, recipeID)
+		}
+		for i := range refreshed {
+			index.Entries[refreshed[i].ID] = IndexEntry{
+				Summary: refreshed[i].Summary(),
+				ETag:    etags[refreshed[i].ID],
+			}
+		}
+	}
+
+	if drifted {
+		if err := db.saveIndex(ctx, userID, index); err != nil {
+			fmt.Printf("⚠️ Failed to save recipe index for user %s: %v\n", userID, err)
+		}
+	}
+
+	summaries := make([]models.RecipeSummary, 0, len(objects))
+	for _, obj := range objects {
+		if entry, ok := index.Entries[obj.recipeID]; ok {
+			summaries = append(summaries, entry.Summary)
+		}
+	}
+	return summaries, listErr
+}
+
+// loadIndex reads the user's index object, returning an empty index if none exists yet
+func (db *S3RecipeDB) loadIndex(ctx context.Context, userID string) (*RecipeIndex, error) {
+	result, err := db.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(db.bucketName),
+		Key:    aws.String(indexKey(userID)),
+	})
+	if err != nil {
+		if err = mapS3Error(err); errors.Is(err, ErrNotFound) {
+			return &RecipeIndex{Entries: map[string]IndexEntry{}}, nil
+		}
+		return nil, fmt.Errorf("failed to get recipe index: %w", err)
+	}
+	defer result.Body.Close()
+
+	data, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recipe index: %w", err)
+	}
+	if data, err = db.codec.Decode(ctx, userID, indexKey(userID), data); err != nil {
+		return nil, err
+	}
+
+	var index RecipeIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal recipe index: %w", err)
+	}
+	if index.Entries == nil {
+		index.Entries = map[string]IndexEntry{}
+	}
+	if index.SourceURLs == nil {
+		// Written before source URLs were indexed
+		index.rebuildSourceURLs()
+	}
+	return &index, nil
+}
+
+// saveIndex writes the user's index object
+func (db *S3RecipeDB) saveIndex(ctx context.Context, userID string, index *RecipeIndex) error {
+	index.UpdatedAt = time.Now().UTC()
+	index.rebuildSourceURLs()
+	data, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("failed to marshal recipe index: %w", err)
+	}
+	obj, err := db.codec.Encode(ctx, userID, indexKey(userID), data)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.client.PutObject(ctx, obj.PutObjectInput(db.bucketName, indexKey(userID)))
+	if err != nil {
+		return fmt.Errorf("failed to store recipe index: %w", err)
+	}
+	return nil
+}
+
+// rebuildSourceURLs derives the canonical source URL map from the entries. When several
+// recipes share a URL, active recipes win over trashed ones, then the most recently updated.
+func (index *RecipeIndex) rebuildSourceURLs() {
+	index.SourceURLs = make(map[string]string, len(index.Entries))
+	for recipeID, entry := range index.Entries {
+		canonical := models.CanonicalSourceURL(entry.Summary.SourceURL)
+		if canonical == "" {
+			continue
+		}
+		if current, ok := index.Entries[index.SourceURLs[canonical]]; ok && !preferForSourceURL(entry.Summary, current.Summary) {
+			continue
+		}
+		index.SourceURLs[canonical] = recipeID
+	}
+}
+
+func preferForSourceURL(candidate, current models.RecipeSummary) bool {
+	if (candidate.DeletedAt == nil) != (current.DeletedAt == nil) {
+		return candidate.DeletedAt == nil
+	}
+	if !candidate.UpdatedAt.Equal(current.UpdatedAt) {
+		return candidate.UpdatedAt.After(current.UpdatedAt)
+	}
+	return candidate.ID < current.ID
+}
+
+// FindBySourceURL returns the user's recipe whose source URL has the same canonical form
+// as sourceURL, using the index's source URL map
+func (db *S3RecipeDB) FindBySourceURL(ctx context.Context, userID, sourceURL string) (*models.Recipe, error) {
+	index, err := db.loadIndex(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if index.UpdatedAt.IsZero() {
+		// No index yet: build it from the recipes
+		if _, err := db.ListRecipeSummaries(ctx, userID); err != nil {
+			return nil, err
+		}
+		if index, err = db.loadIndex(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	recipeID, ok := index.SourceURLs[models.CanonicalSourceURL(sourceURL)]
+	if !ok {
+		return nil, fmt.Errorf("no recipe for source URL %s: %w", sourceURL, ErrNotFound)
+	}
+	// A stale entry (recipe deleted behind the index's back) surfaces as ErrNotFound too
+	return db.GetRecipe(ctx, userID, recipeID)
+}
+
+// indexPut records a freshly written recipe in the index. Failures are logged only:
+// the recipe itself is already stored and the next ListRecipeSummaries repairs the index.
+func (db *S3RecipeDB) indexPut(ctx context.Context, recipe *models.Recipe, etag string) {
+	db.updateIndex(ctx, recipe.UserID, func(index *RecipeIndex) {
+		index.Entries[recipe.ID] = IndexEntry{Summary: recipe.Summary(), ETag: etag}
+	})
+}
+
+// indexRemove drops a deleted recipe from the index (best effort, see indexPut)
+func (db *S3RecipeDB) indexRemove(ctx context.Context, userID, recipeID string) {
+	db.updateIndex(ctx, userID, func(index *RecipeIndex) {
+		delete(index.Entries, recipeID)
+	})
+}
+
+func (db *S3RecipeDB) updateIndex(ctx context.Context, userID string, mutate func(index *RecipeIndex)) {
+	if batch, ok := ctx.Value(indexBatchKey{}).(*indexBatch); ok {
+		batch.add(db, userID, mutate)
+		return
+	}
+	index, err := db.loadIndex(ctx, userID)
+	if err != nil {
+		fmt.Printf("⚠️ Skipping recipe index update for user %s: %v\n", userID, err)
+		return
+	}
+	mutate(index)
+	if err := db.saveIndex(ctx, userID, index); err != nil {
+		fmt.Printf("⚠️ Failed to update recipe index for user %s: %v\n", userID, err)
+	}
+}
+
+type indexBatchKey struct{}
+
+// indexBatch collects the index mutations of writes made under DeferIndexUpdates
+type indexBatch struct {
+	mu      sync.Mutex
+	pending map[*S3RecipeDB]map[string][]func(index *RecipeIndex)
+}
+
+func (b *indexBatch) add(db *S3RecipeDB, userID string, mutate func(index *RecipeIndex)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pending[db] == nil {
+		b.pending[db] = map[string][]func(index *RecipeIndex){}
+	}
+	b.pending[db][userID] = append(b.pending[db][userID], mutate)
+}
+
+// DeferIndexUpdates returns a context under which S3RecipeDB writes record their summary
+// index changes instead of rewriting the index object once per recipe, and a flush function
+// that applies everything recorded in a single index update per user. Call flush once the
+// writes are done. Backends without a summary index are unaffected.
+func DeferIndexUpdates(ctx context.Context) (context.Context, func(ctx context.Context)) {
+	batch := &indexBatch{pending: map[*S3RecipeDB]map[string][]func(index *RecipeIndex){}}
+	flush := func(ctx context.Context) {
+		batch.mu.Lock()
+		pending := batch.pending
+		batch.pending = map[*S3RecipeDB]map[string][]func(index *RecipeIndex){}
+		batch.mu.Unlock()
+
+		// flush may be handed the deferred context itself; make sure the update is applied
+		ctx = context.WithValue(ctx, indexBatchKey{}, nil)
+		for db, users := range pending {
+			for userID, mutations := range users {
+				db.updateIndex(ctx, userID, func(index *RecipeIndex) {
+					for _, mutate := range mutations {
+						mutate(index)
+					}
+				})
+			}
+		}
+	}
+	return context.WithValue(ctx, indexBatchKey{}, batch), flush
+}
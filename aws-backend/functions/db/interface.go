@@ -1,14 +1,81 @@
 package db
 
 import (
+	"context"
+
 	"recipe-archive/models"
 )
 
-// RecipeDB interface for database operations (S3-optimized)
+// RecipeDB interface for database operations (S3-optimized).
+// Every method takes the request context so Lambda deadlines and client
+// cancellations abort in-flight storage calls.
 type RecipeDB interface {
-	GetRecipe(userID, recipeID string) (*models.Recipe, error)
-	ListRecipes(userID string) ([]models.Recipe, error)
-	CreateRecipe(recipe *models.Recipe) error
-	UpdateRecipe(recipe *models.Recipe) error
-	DeleteRecipe(userID, recipeID string) error
+	GetRecipe(ctx context.Context, userID, recipeID string) (*models.Recipe, error)
+	ListRecipes(ctx context.Context, userID string) ([]models.Recipe, error)
+	ListRecipeSummaries(ctx context.Context, userID string) ([]models.RecipeSummary, error)
+	CreateRecipe(ctx context.Context, recipe *models.Recipe) error
+	UpdateRecipe(ctx context.Context, recipe *models.Recipe) error
+	// UpdateRecipeIfVersion is a compare-and-swap: it fails with *VersionConflictError
+	// unless the stored recipe is still at expectedVersion
+	UpdateRecipeIfVersion(ctx context.Context, recipe *models.Recipe, expectedVersion int) error
+	DeleteRecipe(ctx context.Context, userID, recipeID string) error
+}
+
+// RecipePager is implemented by backends that can page through a user's active recipes
+// natively (most recently updated first). The cursor is opaque to callers.
+type RecipePager interface {
+	ListRecipesPage(ctx context.Context, userID string, limit int, cursor string) ([]models.Recipe, models.Pagination, error)
+}
+
+// SourceURLFinder is implemented by backends with an index on source URL. FindBySourceURL
+// fails with ErrNotFound when the user has no recipe for the URL.
+type SourceURLFinder interface {
+	FindBySourceURL(ctx context.Context, userID, sourceURL string) (*models.Recipe, error)
+}
+
+// TextSearcher is implemented by backends with a full-text index. SearchRecipeIDs returns
+// the IDs of the user's recipes whose title, ingredients or instructions match query.
+type TextSearcher interface {
+	SearchRecipeIDs(ctx context.Context, userID, query string) ([]string, error)
+}
+
+// TrashPurger is implemented by backends that can permanently delete a trashed recipe
+// atomically. DeleteTrashedRecipe fails with ErrConflict unless the stored recipe is still
+// in the trash at version, so a recipe restored after it was listed for purging survives.
+type TrashPurger interface {
+	DeleteTrashedRecipe(ctx context.Context, userID, recipeID string, version int) error
+}
+
+// RecipeHistory is implemented by backends that keep prior versions of each recipe.
+// UpdateRecipeIfVersion archives the stored copy before replacing it, and DeleteRecipe
+// removes the archive along with the recipe. GetRecipeVersion fails with ErrNotFound
+// for versions that were never archived.
+type RecipeHistory interface {
+	ListRecipeVersions(ctx context.Context, userID, recipeID string) ([]models.RecipeVersionInfo, error)
+	GetRecipeVersion(ctx context.Context, userID, recipeID string, version int) (*models.Recipe, error)
+}
+
+// ChangeLog is implemented by backends that keep an append-only per-user log of recipe
+// writes for incremental sync. ListChanges returns up to limit entries after cursor ("" reads
+// from the beginning), oldest first, with the cursor to continue from and whether more
+// entries are already available. Cursors are opaque and never move backwards; a cursor the
+// backend did not issue fails with ErrInvalidCursor.
+type ChangeLog interface {
+	ListChanges(ctx context.Context, userID, cursor string, limit int) ([]models.RecipeChange, string, bool, error)
+}
+
+// ETagStore is implemented by backends whose stored recipes carry an ETag that changes on
+// every write (S3). CachingRecipeDB uses it to revalidate entries without downloading bodies.
+type ETagStore interface {
+	GetRecipeWithETag(ctx context.Context, userID, recipeID string) (*models.Recipe, string, error)
+	// HeadRecipe returns the current ETag of a recipe, failing with ErrNotFound if it is gone
+	HeadRecipe(ctx context.Context, userID, recipeID string) (string, error)
+	// ListRecipeETags returns every recipe of the user with its ETag, from one listing
+	ListRecipeETags(ctx context.Context, userID string) ([]RecipeETag, error)
+}
+
+// RecipeETag identifies one stored revision of a recipe
+type RecipeETag struct {
+	RecipeID string
+	ETag     string
 }
@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
@@ -11,116 +12,314 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 )
 
+// DefaultListConcurrency is the number of recipe bodies fetched in parallel by ListRecipes
+const DefaultListConcurrency = 16
+
+// S3API is the subset of the S3 client used by S3RecipeDB (satisfied by *s3.Client)
+type S3API interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+}
+
 // S3RecipeDB implements RecipeDB using S3 for simple, cost-effective storage
 // Structure: /recipes/{userID}/{recipeID}.json
 type S3RecipeDB struct {
-	client     *s3.Client
-	bucketName string
+	client      S3API
+	bucketName  string
+	concurrency int
+	codec       ObjectCodec
 }
 
 // NewS3RecipeDB creates a new S3-based recipe database
-func NewS3RecipeDB(client *s3.Client, bucketName string) *S3RecipeDB {
+func NewS3RecipeDB(client S3API, bucketName string) *S3RecipeDB {
 	return &S3RecipeDB{
-		client:     client,
-		bucketName: bucketName,
+		client:      client,
+		bucketName:  bucketName,
+		concurrency: DefaultListConcurrency,
+	}
+}
+
+// SetListConcurrency overrides the number of parallel GetObject calls made by ListRecipes
+func (db *S3RecipeDB) SetListConcurrency(n int) {
+	if n < 1 {
+		n = 1
 	}
+	db.concurrency = n
 }
 
 // GetRecipe retrieves a recipe by ID and userID from S3
-func (db *S3RecipeDB) GetRecipe(userID, recipeID string) (*models.Recipe, error) {
+func (db *S3RecipeDB) GetRecipe(ctx context.Context, userID, recipeID string) (*models.Recipe, error) {
+	recipe, _, err := db.GetRecipeWithETag(ctx, userID, recipeID)
+	return recipe, err
+}
+
+var _ ETagStore = (*S3RecipeDB)(nil)
+
+// GetRecipeWithETag retrieves a recipe together with the ETag of the stored object
+func (db *S3RecipeDB) GetRecipeWithETag(ctx context.Context, userID, recipeID string) (*models.Recipe, string, error) {
 	key := fmt.Sprintf("recipes/%s/%s.json", userID, recipeID)
 
-	result, err := db.client.GetObject(context.Background(), &s3.GetObjectInput{
+	result, err := db.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(db.bucketName),
 		Key:    aws.String(key),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get recipe: %w", err)
+		return nil, "", fmt.Errorf("failed to get recipe: %w", mapS3Error(err))
 	}
 	defer result.Body.Close()
 
 	data, err := io.ReadAll(result.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read recipe data: %w", err)
+		return nil, "", fmt.Errorf("failed to read recipe data: %w", err)
+	}
+	if data, err = db.codec.Decode(ctx, userID, key, data); err != nil {
+		return nil, "", err
 	}
 
-	var recipe models.Recipe
-	if err := json.Unmarshal(data, &recipe); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal recipe: %w", err)
+	recipe, err := DecodeRecipe(data)
+	if err != nil {
+		return nil, "", err
 	}
 
-	return &recipe, nil
+	return recipe, aws.ToString(result.ETag), nil
+}
+
+// HeadRecipe returns the ETag of a stored recipe without downloading it
+func (db *S3RecipeDB) HeadRecipe(ctx context.Context, userID, recipeID string) (string, error) {
+	result, err := db.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(db.bucketName),
+		Key:    aws.String(fmt.Sprintf("recipes/%s/%s.json", userID, recipeID)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to head recipe: %w", mapS3Error(err))
+	}
+	return aws.ToString(result.ETag), nil
 }
 
 // CreateRecipe stores a new recipe in S3
-func (db *S3RecipeDB) CreateRecipe(recipe *models.Recipe) error {
+func (db *S3RecipeDB) CreateRecipe(ctx context.Context, recipe *models.Recipe) error {
+	return db.putRecipe(ctx, recipe, "", models.ChangeCreate)
+}
+
+// putRecipe writes a recipe object; a non-empty ifMatch makes the write conditional on the
+// stored object's ETag, failing with S3's PreconditionFailed if it has changed. The write is
+// recorded in the change log just before the put (as a delete if it moves the recipe to the
+// trash), and the entry is removed again if the put fails.
+func (db *S3RecipeDB) putRecipe(ctx context.Context, recipe *models.Recipe, ifMatch, changeType string) error {
 	key := fmt.Sprintf("recipes/%s/%s.json", recipe.UserID, recipe.ID)
 
+	if recipe.IsDeleted {
+		changeType = models.ChangeDelete
+	}
+
 	data, err := json.Marshal(recipe)
 	if err != nil {
 		return fmt.Errorf("failed to marshal recipe: %w", err)
 	}
+	obj, err := db.codec.Encode(ctx, recipe.UserID, key, data)
+	if err != nil {
+		return err
+	}
 
-	_, err = db.client.PutObject(context.Background(), &s3.PutObjectInput{
-		Bucket:      aws.String(db.bucketName),
-		Key:         aws.String(key),
-		Body:        strings.NewReader(string(data)),
-		ContentType: aws.String("application/json"),
-	})
+	input := obj.PutObjectInput(db.bucketName, key)
+	if ifMatch != "" {
+		input.IfMatch = aws.String(ifMatch)
+	}
+
+	changeKey, err := db.recordChange(ctx, recipe.UserID, changeType, recipe.ID, recipe.Version)
+	if err != nil {
+		return err
+	}
+	output, err := db.client.PutObject(ctx, input)
 	if err != nil {
-		return fmt.Errorf("failed to store recipe: %w", err)
+		db.removeChange(ctx, changeKey)
+		return fmt.Errorf("failed to store recipe: %w", mapS3Error(err))
 	}
 
+	db.indexPut(ctx, recipe, aws.ToString(output.ETag))
 	return nil
 }
 
-// ListRecipes lists all recipes for a user by listing S3 objects
-func (db *S3RecipeDB) ListRecipes(userID string) ([]models.Recipe, error) {
+// ListRecipes lists all recipes for a user, following S3 continuation tokens and
+// fetching recipe bodies with a bounded worker pool. If some objects fail to load,
+// the successfully loaded recipes are returned together with a *PartialListError.
+func (db *S3RecipeDB) ListRecipes(ctx context.Context, userID string) ([]models.Recipe, error) {
+	objects, err := db.listRecipeObjects(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	recipeIDs := make([]string, len(objects))
+	for i, obj := range objects {
+		recipeIDs[i] = obj.recipeID
+	}
+
+	return GetRecipes(ctx, db, userID, recipeIDs, db.concurrency)
+}
+
+// recipeObject is a recipe body found by listing a user's prefix
+type recipeObject struct {
+	recipeID string
+	etag     string
+}
+
+// listRecipeObjects pages through every recipe object directly under the user's prefix
+func (db *S3RecipeDB) listRecipeObjects(ctx context.Context, userID string) ([]recipeObject, error) {
 	prefix := fmt.Sprintf("recipes/%s/", userID)
 
-	result, err := db.client.ListObjectsV2(context.Background(), &s3.ListObjectsV2Input{
+	var objects []recipeObject
+	paginator := s3.NewListObjectsV2Paginator(db.client, &s3.ListObjectsV2Input{
 		Bucket: aws.String(db.bucketName),
 		Prefix: aws.String(prefix),
 	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list recipes: %w", err)
+		}
+		for _, obj := range page.Contents {
+			name := strings.TrimPrefix(aws.ToString(obj.Key), prefix)
+			// Only top-level {recipeID}.json objects are recipes; "_" names are reserved (e.g. the index)
+			if strings.Contains(name, "/") || strings.HasPrefix(name, "_") || !strings.HasSuffix(name, ".json") {
+				continue
+			}
+			objects = append(objects, recipeObject{
+				recipeID: strings.TrimSuffix(name, ".json"),
+				etag:     aws.ToString(obj.ETag),
+			})
+		}
+	}
+	return objects, nil
+}
+
+// ListRecipeETags lists the user's recipes with their ETags, without reading any bodies
+func (db *S3RecipeDB) ListRecipeETags(ctx context.Context, userID string) ([]RecipeETag, error) {
+	objects, err := db.listRecipeObjects(ctx, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list recipes: %w", err)
+		return nil, err
+	}
+	etags := make([]RecipeETag, len(objects))
+	for i, obj := range objects {
+		etags[i] = RecipeETag{RecipeID: obj.recipeID, ETag: obj.etag}
 	}
+	return etags, nil
+}
+
+// UpdateRecipe updates an existing recipe (same as create in S3)
+func (db *S3RecipeDB) UpdateRecipe(ctx context.Context, recipe *models.Recipe) error {
+	return db.putRecipe(ctx, recipe, "", models.ChangeUpdate) // S3 overwrites by default
+}
 
-	var recipes []models.Recipe
-	for _, obj := range result.Contents {
-		// Extract recipeID from key
-		key := *obj.Key
-		recipeID := strings.TrimSuffix(strings.TrimPrefix(key, prefix), ".json")
+// UpdateRecipeIfVersion stores recipe only if the stored copy is still at expectedVersion.
+// The version check is backed by an S3 If-Match conditional put on the ETag that was read,
+// so a concurrent writer between the check and the put is also detected. The replaced
+// copy is archived first (see history.go) so it can be restored later.
+func (db *S3RecipeDB) UpdateRecipeIfVersion(ctx context.Context, recipe *models.Recipe, expectedVersion int) error {
+	current, etag, err := db.GetRecipeWithETag(ctx, recipe.UserID, recipe.ID)
+	if err != nil {
+		return err
+	}
+	if current.Version != expectedVersion {
+		return &VersionConflictError{Current: current}
+	}
+	if err := db.archiveVersion(ctx, current); err != nil {
+		return err
+	}
 
-		recipe, err := db.GetRecipe(userID, recipeID)
-		if err != nil {
-			// Log error but continue with other recipes
-			continue
+	err = db.putRecipe(ctx, recipe, etag, models.ChangeUpdate)
+	if errors.Is(err, ErrConflict) {
+		// Someone else wrote in between; report their copy
+		latest, getErr := db.GetRecipe(ctx, recipe.UserID, recipe.ID)
+		if getErr != nil {
+			return getErr
 		}
-		recipes = append(recipes, *recipe)
+		return &VersionConflictError{Current: latest}
 	}
+	return err
+}
 
-	return recipes, nil
+// DeleteRecipe removes a recipe and its archived versions from S3
+func (db *S3RecipeDB) DeleteRecipe(ctx context.Context, userID, recipeID string) error {
+	return db.deleteRecipe(ctx, userID, recipeID, "")
 }
 
-// UpdateRecipe updates an existing recipe (same as create in S3)
-func (db *S3RecipeDB) UpdateRecipe(recipe *models.Recipe) error {
-	return db.CreateRecipe(recipe) // S3 overwrites by default
+var _ TrashPurger = (*S3RecipeDB)(nil)
+
+// DeleteTrashedRecipe deletes a recipe only if it is still in the trash at version. The
+// delete is conditional on the ETag that was checked, so a restore or edit that lands in
+// between makes it fail with ErrConflict instead of being lost.
+func (db *S3RecipeDB) DeleteTrashedRecipe(ctx context.Context, userID, recipeID string, version int) error {
+	current, etag, err := db.GetRecipeWithETag(ctx, userID, recipeID)
+	if err != nil {
+		return err
+	}
+	if !current.IsDeleted || current.Version != version {
+		return fmt.Errorf("recipe %s is no longer in the trash at version %d: %w", recipeID, version, ErrConflict)
+	}
+	return db.deleteRecipe(ctx, userID, recipeID, etag)
 }
 
-// DeleteRecipe removes a recipe from S3
-func (db *S3RecipeDB) DeleteRecipe(userID, recipeID string) error {
+// deleteRecipe removes a recipe object and its archived versions; a non-empty ifMatch makes
+// the delete conditional on the stored object's ETag
+func (db *S3RecipeDB) deleteRecipe(ctx context.Context, userID, recipeID, ifMatch string) error {
 	key := fmt.Sprintf("recipes/%s/%s.json", userID, recipeID)
 
-	_, err := db.client.DeleteObject(context.Background(), &s3.DeleteObjectInput{
+	input := &s3.DeleteObjectInput{
 		Bucket: aws.String(db.bucketName),
 		Key:    aws.String(key),
-	})
+	}
+	if ifMatch != "" {
+		input.IfMatch = aws.String(ifMatch)
+	}
+
+	changeKey, err := db.recordChange(ctx, userID, models.ChangeDelete, recipeID, 0)
 	if err != nil {
-		return fmt.Errorf("failed to delete recipe: %w", err)
+		return err
+	}
+	if _, err := db.client.DeleteObject(ctx, input); err != nil {
+		db.removeChange(ctx, changeKey)
+		return fmt.Errorf("failed to delete recipe: %w", mapS3Error(err))
 	}
 
+	db.indexRemove(ctx, userID, recipeID)
+	if err := db.deleteVersions(ctx, userID, recipeID); err != nil {
+		// The recipe itself is gone; orphaned versions are unreachable and only cost storage
+		fmt.Printf("⚠️ Failed to delete versions of recipe %s: %v\n", recipeID, err)
+	}
 	return nil
 }
+
+// isPreconditionFailed reports whether err is S3 rejecting a conditional write
+func isPreconditionFailed(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "PreconditionFailed", "ConditionalRequestConflict":
+			return true
+		}
+	}
+	return false
+}
+
+// mapS3Error tags S3 errors with the matching db sentinel, keeping the original in the chain
+func mapS3Error(err error) error {
+	var noSuchKey *types.NoSuchKey
+	switch {
+	case errors.As(err, &noSuchKey):
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	case isPreconditionFailed(err):
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NotFound" {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,429 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"recipe-archive/envelope"
+	"recipe-archive/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// fakeS3 is an in-memory S3API that pages ListObjectsV2 results
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	pageSize int
+	gets     int
+	heads    int
+	puts     map[string]int
+	// beforeDelete, if set, runs at the start of every DeleteObject call
+	beforeDelete func(key string)
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: map[string][]byte{}, pageSize: 1000, puts: map[string]int{}}
+}
+
+func (f *fakeS3) GetObject(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.gets++
+	data, ok := f.objects[aws.ToString(in.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data)), ETag: aws.String(etagOf(data))}, nil
+}
+
+func (f *fakeS3) HeadObject(ctx context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.heads++
+	data, ok := f.objects[aws.ToString(in.Key)]
+	if !ok {
+		return nil, &smithy.GenericAPIError{Code: "NotFound", Message: "Not Found"}
+	}
+	return &s3.HeadObjectOutput{ETag: aws.String(etagOf(data))}, nil
+}
+
+func (f *fakeS3) PutObject(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if in.IfMatch != nil {
+		if current, ok := f.objects[aws.ToString(in.Key)]; !ok || etagOf(current) != *in.IfMatch {
+			return nil, &smithy.GenericAPIError{Code: "PreconditionFailed", Message: "At least one of the pre-conditions you specified did not hold"}
+		}
+	}
+	f.objects[aws.ToString(in.Key)] = data
+	f.puts[aws.ToString(in.Key)]++
+	return &s3.PutObjectOutput{ETag: aws.String(etagOf(data))}, nil
+}
+
+func (f *fakeS3) DeleteObject(ctx context.Context, in *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	if f.beforeDelete != nil {
+		f.beforeDelete(aws.ToString(in.Key))
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if in.IfMatch != nil {
+		if current, ok := f.objects[aws.ToString(in.Key)]; !ok || etagOf(current) != *in.IfMatch {
+			return nil, &smithy.GenericAPIError{Code: "PreconditionFailed", Message: "At least one of the pre-conditions you specified did not hold"}
+		}
+	}
+	delete(f.objects, aws.ToString(in.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func (f *fakeS3) ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var keys []string
+	for k := range f.objects {
+		if strings.HasPrefix(k, aws.ToString(in.Prefix)) && k > aws.ToString(in.StartAfter) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	start := 0
+	if in.ContinuationToken != nil {
+		start, _ = strconv.Atoi(*in.ContinuationToken)
+	}
+	pageSize := f.pageSize
+	if in.MaxKeys != nil && int(*in.MaxKeys) < pageSize {
+		pageSize = int(*in.MaxKeys)
+	}
+	end := start + pageSize
+	if end > len(keys) {
+		end = len(keys)
+	}
+
+	out := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(end < len(keys))}
+	for _, k := range keys[start:end] {
+		out.Contents = append(out.Contents, types.Object{Key: aws.String(k), ETag: aws.String(etagOf(f.objects[k]))})
+	}
+	if end < len(keys) {
+		out.NextContinuationToken = aws.String(strconv.Itoa(end))
+	}
+	return out, nil
+}
+
+func etagOf(data []byte) string {
+	return fmt.Sprintf("\"%x\"", md5.Sum(data))
+}
+
+func TestListRecipesFollowsContinuationTokens(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeS3()
+	fake.pageSize = 7
+	for i := 0; i < 25; i++ {
+		fake.objects[fmt.Sprintf("recipes/u1/r%02d.json", i)] = []byte(fmt.Sprintf(`{"id":"r%02d","userId":"u1","title":"Recipe %d"}`, i, i))
+	}
+	fake.objects["recipes/u2/other.json"] = []byte(`{"id":"other","userId":"u2"}`)
+
+	store := NewS3RecipeDB(fake, "bucket")
+	store.SetListConcurrency(3)
+
+	recipes, err := store.ListRecipes(ctx, "u1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(recipes) != 25 {
+		t.Fatalf("got %d recipes want 25", len(recipes))
+	}
+	for i, r := range recipes {
+		if want := fmt.Sprintf("r%02d", i); r.ID != want {
+			t.Errorf("recipe %d: got id %s want %s", i, r.ID, want)
+		}
+	}
+}
+
+func TestListRecipesReportsPartialFailures(t *testing.T) {
+	fake := newFakeS3()
+	fake.objects["recipes/u1/good.json"] = []byte(`{"id":"good","userId":"u1"}`)
+	fake.objects["recipes/u1/bad.json"] = []byte(`{not json`)
+
+	recipes, err := NewS3RecipeDB(fake, "bucket").ListRecipes(context.Background(), "u1")
+
+	var partial *PartialListError
+	if !errors.As(err, &partial) {
+		t.Fatalf("expected *PartialListError, got %v", err)
+	}
+	if len(partial.Failures) != 1 || partial.Failures[0].RecipeID != "bad" {
+		t.Errorf("unexpected failures: %+v", partial.Failures)
+	}
+	if len(recipes) != 1 || recipes[0].ID != "good" {
+		t.Errorf("expected the good recipe to be returned, got %+v", recipes)
+	}
+}
+
+func TestListRecipeSummariesMaintainsAndHealsIndex(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeS3()
+	store := NewS3RecipeDB(fake, "bucket")
+
+	for _, id := range []string{"a", "b"} {
+		if err := store.CreateRecipe(ctx, &models.Recipe{ID: id, UserID: "u1", Title: "Title " + id}); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+	if _, ok := fake.objects["recipes/u1/_index.json"]; !ok {
+		t.Fatal("expected index object to be written on create")
+	}
+
+	// A write that bypasses the store (e.g. the background normalizer) must be picked up
+	fake.objects["recipes/u1/b.json"] = []byte(`{"id":"b","userId":"u1","title":"Normalized B"}`)
+	fake.objects["recipes/u1/c.json"] = []byte(`{"id":"c","userId":"u1","title":"Title c"}`)
+	if err := store.DeleteRecipe(ctx, "u1", "a"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	summaries, err := store.ListRecipeSummaries(ctx, "u1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := map[string]string{}
+	for _, s := range summaries {
+		got[s.ID] = s.Title
+	}
+	want := map[string]string{"b": "Normalized B", "c": "Title c"}
+	if len(got) != len(want) || got["b"] != want["b"] || got["c"] != want["c"] {
+		t.Errorf("got summaries %v want %v", got, want)
+	}
+
+	recipes, err := store.ListRecipes(ctx, "u1")
+	if err != nil || len(recipes) != 2 {
+		t.Errorf("ListRecipes should skip the index object: got %d recipes, err %v", len(recipes), err)
+	}
+}
+
+func TestFindBySourceURLUsesCanonicalIndex(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeS3()
+	// Stored before the index existed
+	fake.objects["recipes/u1/old.json"] = []byte(`{"id":"old","userId":"u1","title":"Soup","sourceUrl":"http://www.example.com/soup/"}`)
+	store := NewS3RecipeDB(fake, "bucket")
+
+	found, err := store.FindBySourceURL(ctx, "u1", "https://example.com/soup?utm_source=newsletter")
+	if err != nil || found.ID != "old" {
+		t.Fatalf("find before index: got %+v err %v", found, err)
+	}
+
+	trashedAt := time.Now().UTC()
+	if err := store.CreateRecipe(ctx, &models.Recipe{ID: "stew", UserID: "u1", Title: "Stew",
+		SourceURL: "https://example.com/stew", DeletedAt: &trashedAt}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := store.CreateRecipe(ctx, &models.Recipe{ID: "stew2", UserID: "u1", Title: "Stew",
+		SourceURL: "https://example.com/stew#recipe", UpdatedAt: trashedAt.Add(-time.Hour)}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if found, err := store.FindBySourceURL(ctx, "u1", "https://amp.example.com/stew"); err != nil || found.ID != "stew2" {
+		t.Errorf("active recipe should win over trashed: got %+v err %v", found, err)
+	}
+
+	if _, err := store.FindBySourceURL(ctx, "u1", "https://example.com/cake"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown URL: got %v want ErrNotFound", err)
+	}
+	if _, err := store.FindBySourceURL(ctx, "u2", "https://example.com/soup"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("other user's URL: got %v want ErrNotFound", err)
+	}
+}
+
+func TestDeferIndexUpdatesWritesIndexOnce(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeS3()
+	store := NewS3RecipeDB(fake, "bucket")
+	if err := store.CreateRecipe(ctx, &models.Recipe{ID: "old", UserID: "u1", Title: "Old"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	fake.puts = map[string]int{}
+
+	batchCtx, flush := DeferIndexUpdates(ctx)
+	for _, id := range []string{"a", "b", "c"} {
+		if err := store.CreateRecipe(batchCtx, &models.Recipe{ID: id, UserID: "u1", Title: "Title " + id}); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+	if err := store.DeleteRecipe(batchCtx, "u1", "old"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if n := fake.puts["recipes/u1/_index.json"]; n != 0 {
+		t.Fatalf("index written %d times before flush", n)
+	}
+	flush(batchCtx)
+	if n := fake.puts["recipes/u1/_index.json"]; n != 1 {
+		t.Errorf("index written %d times, want 1", n)
+	}
+
+	index, err := store.loadIndex(ctx, "u1")
+	if err != nil {
+		t.Fatalf("load index: %v", err)
+	}
+	if _, ok := index.Entries["old"]; ok || len(index.Entries) != 3 {
+		t.Errorf("index entries after flush: %v", index.Entries)
+	}
+}
+
+func TestUpdateRecipeIfVersion(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeS3()
+	store := NewS3RecipeDB(fake, "bucket")
+	if err := store.CreateRecipe(ctx, &models.Recipe{ID: "r1", UserID: "u1", Title: "Original", Version: 1}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if err := store.UpdateRecipeIfVersion(ctx, &models.Recipe{ID: "r1", UserID: "u1", Title: "Phone edit", Version: 2}, 1); err != nil {
+		t.Fatalf("first update should succeed: %v", err)
+	}
+
+	// A second device still editing version 1 must not clobber the phone's edit
+	err := store.UpdateRecipeIfVersion(ctx, &models.Recipe{ID: "r1", UserID: "u1", Title: "Tablet edit", Version: 2}, 1)
+	var conflict *VersionConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected *VersionConflictError, got %v", err)
+	}
+	if conflict.Current.Title != "Phone edit" || conflict.Current.Version != 2 {
+		t.Errorf("conflict should carry the server copy, got %+v", conflict.Current)
+	}
+
+	stored, err := store.GetRecipe(ctx, "u1", "r1")
+	if err != nil || stored.Title != "Phone edit" {
+		t.Errorf("stored recipe was overwritten: %+v (err %v)", stored, err)
+	}
+}
+
+func TestUpdateRecipeIfVersionArchivesPriorVersions(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeS3()
+	store := NewS3RecipeDB(fake, "bucket")
+	if err := store.CreateRecipe(ctx, &models.Recipe{ID: "r1", UserID: "u1", Title: "Hand edited", Version: 1}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := store.UpdateRecipeIfVersion(ctx, &models.Recipe{ID: "r1", UserID: "u1", Title: "Re-captured", Version: 2}, 1); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	versions, err := store.ListRecipeVersions(ctx, "u1", "r1")
+	if err != nil || len(versions) != 1 || versions[0].Version != 1 {
+		t.Fatalf("versions: got %+v err %v", versions, err)
+	}
+	old, err := store.GetRecipeVersion(ctx, "u1", "r1", 1)
+	if err != nil || old.Title != "Hand edited" {
+		t.Fatalf("version 1: got %+v err %v", old, err)
+	}
+	if _, err := store.GetRecipeVersion(ctx, "u1", "r1", 7); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing version: got %v want ErrNotFound", err)
+	}
+
+	// Archived versions are nested keys and must not be listed as recipes
+	recipes, err := store.ListRecipes(ctx, "u1")
+	if err != nil || len(recipes) != 1 {
+		t.Errorf("list: got %d recipes err %v", len(recipes), err)
+	}
+
+	if err := store.DeleteRecipe(ctx, "u1", "r1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, ok := fake.objects["recipes/u1/r1/versions/1.json"]; ok {
+		t.Error("archived version survived delete")
+	}
+}
+
+func TestEncryptedStorage(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeS3()
+	fake.objects["recipes/u1/legacy.json"] = []byte(`{"id":"legacy","userId":"u1","title":"Written before encryption"}`)
+	keyring, err := envelope.NewKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewS3RecipeDB(fake, "bucket")
+	store.SetEncryptor(envelope.NewEncryptor(keyring))
+
+	if err := store.CreateRecipe(ctx, &models.Recipe{ID: "r1", UserID: "u1", Title: "Secret soup", Version: 1}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := store.UpdateRecipeIfVersion(ctx, &models.Recipe{ID: "r1", UserID: "u1", Title: "Secret stew", Version: 2}, 1); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if _, err := store.ListRecipeSummaries(ctx, "u1"); err != nil {
+		t.Fatalf("summaries: %v", err)
+	}
+	for _, key := range []string{"recipes/u1/r1.json", "recipes/u1/r1/versions/1.json", "recipes/u1/_index.json"} {
+		if !envelope.IsSealed(fake.objects[key]) || bytes.Contains(fake.objects[key], []byte("Secret")) {
+			t.Errorf("%s stored in plaintext", key)
+		}
+	}
+
+	recipes, err := store.ListRecipes(ctx, "u1")
+	if err != nil || len(recipes) != 2 {
+		t.Fatalf("list: got %+v err %v", recipes, err)
+	}
+	if old, err := store.GetRecipeVersion(ctx, "u1", "r1", 1); err != nil || old.Title != "Secret soup" {
+		t.Errorf("version 1: got %+v err %v", old, err)
+	}
+
+	// Without the key provider sealed recipes are unreadable, never misparsed
+	if _, err := NewS3RecipeDB(fake, "bucket").GetRecipe(ctx, "u1", "r1"); !errors.Is(err, envelope.ErrNotConfigured) {
+		t.Errorf("read without provider: got %v want ErrNotConfigured", err)
+	}
+}
+
+func TestIsPreconditionFailed(t *testing.T) {
+	if !isPreconditionFailed(fmt.Errorf("wrapped: %w", &smithy.GenericAPIError{Code: "PreconditionFailed"})) {
+		t.Error("expected wrapped PreconditionFailed to be detected")
+	}
+	if isPreconditionFailed(&types.NoSuchKey{}) {
+		t.Error("NoSuchKey is not a precondition failure")
+	}
+}
+
+func TestGetRecipeMapsStorageErrors(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeS3()
+	fake.objects["recipes/u1/broken.json"] = []byte(`{"id":`)
+	store := NewS3RecipeDB(fake, "bucket")
+
+	if _, err := store.GetRecipe(ctx, "u1", "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing recipe: got %v want ErrNotFound", err)
+	}
+	if _, err := store.GetRecipe(ctx, "u1", "broken"); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("broken recipe: got %v want ErrCorrupt", err)
+	}
+	if err := (&VersionConflictError{Current: &models.Recipe{ID: "r1"}}); !errors.Is(err, ErrConflict) {
+		t.Errorf("version conflict should match ErrConflict")
+	}
+}
+
+func TestGetRecipesStopsWhenContextCancelled(t *testing.T) {
+	fake := newFakeS3()
+	fake.objects["recipes/u1/r1.json"] = []byte(`{"id":"r1","userId":"u1"}`)
+	store := NewS3RecipeDB(fake, "bucket")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := GetRecipes(ctx, store, "u1", []string{"r1", "r1", "r1"}, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v want context.Canceled", err)
+	}
+}
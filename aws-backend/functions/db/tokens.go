@@ -0,0 +1,252 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"recipe-archive/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Personal access tokens look like rat_{tokenID}_{secret}. Each token is stored at
+// tokens/{tokenID}.json with its owner, scopes and a SHA-256 hash of the secret, so a
+// request is authenticated with a single read. An empty marker at
+// tokens/users/{userID}/{tokenID} lists a user's tokens. Secrets are 256 random bits, so an
+// unsalted fast hash is enough; the secret itself is never stored.
+
+// ErrInvalidToken means a personal access token is malformed, unknown, revoked or expired
+var ErrInvalidToken = errors.New("invalid access token")
+
+// accessTokenUseInterval limits how often last-used times are written, so a busy script
+// does not rewrite its token on every request
+const accessTokenUseInterval = 5 * time.Minute
+
+// tokenIDPattern matches the ID part of a token, which becomes an S3 key
+var tokenIDPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// storedAccessToken is the object kept for a token
+type storedAccessToken struct {
+	models.AccessToken
+	SecretHash string `json:"secretHash"`
+}
+
+// AccessTokenStore keeps personal access tokens in S3
+type AccessTokenStore struct {
+	client     S3API
+	bucketName string
+	now        func() time.Time
+}
+
+// NewAccessTokenStore creates a token store in bucketName
+func NewAccessTokenStore(client S3API, bucketName string) *AccessTokenStore {
+	return &AccessTokenStore{client: client, bucketName: bucketName, now: time.Now}
+}
+
+func accessTokenKey(tokenID string) string {
+	return fmt.Sprintf("tokens/%s.json", tokenID)
+}
+
+func accessTokenMarkersPrefix(userID string) string {
+	return fmt.Sprintf("tokens/users/%s/", userID)
+}
+
+// Create issues a token for userID and returns it with its secret, which cannot be recovered later
+func (s *AccessTokenStore) Create(ctx context.Context, userID, name string, scopes []string, expiresAt time.Time) (*models.AccessToken, string, error) {
+	id := make([]byte, 8)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", fmt.Errorf("failed to generate token ID: %w", err)
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate token secret: %w", err)
+	}
+	encodedSecret := base64.RawURLEncoding.EncodeToString(secret)
+
+	stored := storedAccessToken{
+		AccessToken: models.AccessToken{
+			ID:        hex.EncodeToString(id),
+			UserID:    userID,
+			Name:      name,
+			Scopes:    scopes,
+			CreatedAt: s.now().UTC(),
+			ExpiresAt: expiresAt.UTC(),
+		},
+		SecretHash: hashTokenSecret(encodedSecret),
+	}
+
+	// The marker goes first: a token that cannot be listed could not be revoked either
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(accessTokenMarkersPrefix(userID) + stored.ID),
+		Body:   strings.NewReader(""),
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to record access token: %w", mapS3Error(err))
+	}
+	if err := s.put(ctx, &stored, ""); err != nil {
+		return nil, "", err
+	}
+
+	token := fmt.Sprintf("%s%s_%s", models.AccessTokenPrefix, stored.ID, encodedSecret)
+	return &stored.AccessToken, token, nil
+}
+
+// List returns the user's tokens, including expired ones
+func (s *AccessTokenStore) List(ctx context.Context, userID string) ([]models.AccessToken, error) {
+	prefix := accessTokenMarkersPrefix(userID)
+	tokens := []models.AccessToken{}
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucketName),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list access tokens: %w", err)
+		}
+		for _, obj := range page.Contents {
+			stored, _, err := s.get(ctx, strings.TrimPrefix(aws.ToString(obj.Key), prefix))
+			if errors.Is(err, ErrNotFound) {
+				// Creation failed after the marker was written
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, stored.AccessToken)
+		}
+	}
+	return tokens, nil
+}
+
+// Revoke deletes one of the user's tokens, returning ErrNotFound if the user has no such token
+func (s *AccessTokenStore) Revoke(ctx context.Context, userID, tokenID string) error {
+	stored, _, err := s.get(ctx, tokenID)
+	if err != nil {
+		return err
+	}
+	if stored.UserID != userID {
+		return fmt.Errorf("%w: access token %s", ErrNotFound, tokenID)
+	}
+
+	_, err = s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(accessTokenKey(tokenID)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", mapS3Error(err))
+	}
+	_, err = s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(accessTokenMarkersPrefix(userID) + tokenID),
+	})
+	if err != nil {
+		// The token no longer works; List skips markers without a token
+		fmt.Printf("⚠️ Failed to delete marker for revoked access token %s: %v\n", tokenID, err)
+	}
+	return nil
+}
+
+// Authenticate returns the token that token is the secret form of. Invalid tokens fail with
+// ErrInvalidToken; other errors mean the store could not be read.
+func (s *AccessTokenStore) Authenticate(ctx context.Context, token string) (*models.AccessToken, error) {
+	rest, prefixed := strings.CutPrefix(token, models.AccessTokenPrefix)
+	tokenID, secret, _ := strings.Cut(rest, "_")
+	if !prefixed || secret == "" {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	stored, etag, err := s.get(ctx, tokenID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("%w: unknown token %s", ErrInvalidToken, tokenID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashTokenSecret(secret)), []byte(stored.SecretHash)) != 1 {
+		return nil, fmt.Errorf("%w: wrong secret for token %s", ErrInvalidToken, tokenID)
+	}
+	now := s.now().UTC()
+	if stored.Expired(now) {
+		return nil, fmt.Errorf("%w: token %s expired", ErrInvalidToken, tokenID)
+	}
+
+	if stored.LastUsedAt == nil || now.Sub(*stored.LastUsedAt) >= accessTokenUseInterval {
+		stored.LastUsedAt = &now
+		// Conditional on the copy that was read, so a token revoked in the meantime is not
+		// written back; losing to a concurrent use of the same token is harmless
+		err := s.put(ctx, stored, etag)
+		if err != nil && !errors.Is(err, ErrConflict) && !errors.Is(err, ErrNotFound) {
+			// Tracking usage must not lock the caller out
+			fmt.Printf("⚠️ Failed to record use of access token %s: %v\n", tokenID, err)
+		}
+	}
+	return &stored.AccessToken, nil
+}
+
+// get reads a token together with the ETag of its object
+func (s *AccessTokenStore) get(ctx context.Context, tokenID string) (*storedAccessToken, string, error) {
+	if !tokenIDPattern.MatchString(tokenID) {
+		return nil, "", fmt.Errorf("%w: access token %q", ErrNotFound, tokenID)
+	}
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(accessTokenKey(tokenID)),
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get access token: %w", mapS3Error(err))
+	}
+	defer result.Body.Close()
+
+	data, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read access token: %w", err)
+	}
+	var stored storedAccessToken
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, "", fmt.Errorf("%w: access token %s: %v", ErrCorrupt, tokenID, err)
+	}
+	return &stored, aws.ToString(result.ETag), nil
+}
+
+// put writes a token; a non-empty ifMatch makes the write conditional on the stored object's
+// ETag, failing with ErrConflict (or ErrNotFound once it is deleted) if it has changed
+func (s *AccessTokenStore) put(ctx context.Context, stored *storedAccessToken, ifMatch string) error {
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to encode access token: %w", err)
+	}
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucketName),
+		Key:         aws.String(accessTokenKey(stored.ID)),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	}
+	if ifMatch != "" {
+		input.IfMatch = aws.String(ifMatch)
+	}
+	_, err = s.client.PutObject(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to store access token: %w", mapS3Error(err))
+	}
+	return nil
+}
+
+func hashTokenSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestAccessTokenLifecycle(t *testing.T) {
+	ctx := context.Background()
+	store := NewAccessTokenStore(newFakeS3(), "bucket")
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	created, secret, err := store.Create(ctx, "user-1", "recipe-report", []string{"read"}, now.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !strings.HasPrefix(secret, "rat_"+created.ID+"_") {
+		t.Fatalf("token %q does not embed ID %s", secret, created.ID)
+	}
+
+	got, err := store.Authenticate(ctx, secret)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if got.UserID != "user-1" || strings.Join(got.Scopes, ",") != "read" {
+		t.Errorf("Authenticate = %+v", got)
+	}
+
+	tokens, err := store.List(ctx, "user-1")
+	if err != nil || len(tokens) != 1 {
+		t.Fatalf("List = %v, %v", tokens, err)
+	}
+	if tokens[0].LastUsedAt == nil || !tokens[0].LastUsedAt.Equal(now) {
+		t.Errorf("LastUsedAt = %v, want %v", tokens[0].LastUsedAt, now)
+	}
+	if others, _ := store.List(ctx, "user-2"); len(others) != 0 {
+		t.Errorf("other user sees %d tokens", len(others))
+	}
+
+	if err := store.Revoke(ctx, "user-2", created.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Revoke by another user = %v, want ErrNotFound", err)
+	}
+	if err := store.Revoke(ctx, "user-1", created.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, err := store.Authenticate(ctx, secret); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Authenticate after revoke = %v, want ErrInvalidToken", err)
+	}
+	if tokens, _ := store.List(ctx, "user-1"); len(tokens) != 0 {
+		t.Errorf("List after revoke = %v", tokens)
+	}
+}
+
+// afterGetS3 runs hook once, after the next GetObject has read its object
+type afterGetS3 struct {
+	*fakeS3
+	hook func()
+}
+
+func (f *afterGetS3) GetObject(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	output, err := f.fakeS3.GetObject(ctx, in, optFns...)
+	if hook := f.hook; hook != nil {
+		f.hook = nil
+		hook()
+	}
+	return output, err
+}
+
+func TestAccessTokenRevokedDuringAuthenticateStaysRevoked(t *testing.T) {
+	ctx := context.Background()
+	client := &afterGetS3{fakeS3: newFakeS3()}
+	store := NewAccessTokenStore(client, "bucket")
+
+	created, secret, err := store.Create(ctx, "user-1", "sync", []string{"read"}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// Revoke between Authenticate's read and its last-used write
+	client.hook = func() {
+		if err := store.Revoke(ctx, "user-1", created.ID); err != nil {
+			t.Errorf("Revoke: %v", err)
+		}
+	}
+	if _, err := store.Authenticate(ctx, secret); err != nil {
+		t.Fatalf("Authenticate in flight: %v", err)
+	}
+
+	if _, err := store.Authenticate(ctx, secret); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Authenticate after revoke = %v, want ErrInvalidToken", err)
+	}
+	if _, ok := client.objects[accessTokenKey(created.ID)]; ok {
+		t.Error("revoked token was written back")
+	}
+}
+
+func TestAccessTokenRejectsBadTokens(t *testing.T) {
+	ctx := context.Background()
+	store := NewAccessTokenStore(newFakeS3(), "bucket")
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	_, secret, err := store.Create(ctx, "user-1", "ci", []string{"read", "write"}, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	for name, token := range map[string]string{
+		"wrong secret": secret[:len(secret)-4] + "AAAA",
+		"no secret":    strings.SplitN(secret, "_", 3)[0] + "_" + strings.SplitN(secret, "_", 3)[1],
+		"unknown id":   "rat_0123456789abcdef_secret",
+		"path in id":   "rat_../recipes_secret",
+		"no prefix":    strings.TrimPrefix(secret, "rat_"),
+	} {
+		if _, err := store.Authenticate(ctx, token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: Authenticate = %v, want ErrInvalidToken", name, err)
+		}
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := store.Authenticate(ctx, secret); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expired: Authenticate = %v, want ErrInvalidToken", err)
+	}
+}
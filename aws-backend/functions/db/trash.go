@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// PurgeTrash permanently deletes the user's trashed recipes that were deleted before cutoff
+// and returns how many were removed. Recipes soft-deleted before DeletedAt was recorded
+// are aged by UpdatedAt instead. Each candidate is re-read before it is deleted, and on
+// backends implementing TrashPurger the delete is conditional on the version that was read,
+// so a recipe restored while the purge runs is kept.
+func PurgeTrash(ctx context.Context, store RecipeDB, userID string, cutoff time.Time) (int, error) {
+	summaries, err := store.ListRecipeSummaries(ctx, userID)
+	var partial *PartialListError
+	if err != nil && !errors.As(err, &partial) {
+		return 0, err
+	}
+
+	purged := 0
+	for _, summary := range summaries {
+		if !summary.IsDeleted || !trashedBefore(summary.DeletedAt, summary.UpdatedAt, cutoff) {
+			continue
+		}
+
+		recipe, err := store.GetRecipe(ctx, userID, summary.ID)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return purged, fmt.Errorf("failed to re-read recipe %s: %w", summary.ID, err)
+		}
+		if !recipe.IsDeleted || !trashedBefore(recipe.DeletedAt, recipe.UpdatedAt, cutoff) {
+			continue // restored or trashed again since it was listed
+		}
+
+		if purger, ok := store.(TrashPurger); ok {
+			err = purger.DeleteTrashedRecipe(ctx, userID, recipe.ID, recipe.Version)
+		} else {
+			err = store.DeleteRecipe(ctx, userID, recipe.ID)
+		}
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrConflict) {
+			continue
+		}
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge recipe %s: %w", recipe.ID, err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// trashedBefore reports whether a trashed recipe was deleted before cutoff
+func trashedBefore(deletedAt *time.Time, updatedAt, cutoff time.Time) bool {
+	if deletedAt != nil {
+		return deletedAt.Before(cutoff)
+	}
+	return updatedAt.Before(cutoff)
+}
+
+// ListUserIDs returns every user with a recipes/{userID}/ prefix in the bucket
+func (db *S3RecipeDB) ListUserIDs(ctx context.Context) ([]string, error) {
+	var userIDs []string
+	paginator := s3.NewListObjectsV2Paginator(db.client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(db.bucketName),
+		Prefix:    aws.String("recipes/"),
+		Delimiter: aws.String("/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list users: %w", err)
+		}
+		for _, prefix := range page.CommonPrefixes {
+			userID := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(prefix.Prefix), "recipes/"), "/")
+			if userID != "" {
+				userIDs = append(userIDs, userID)
+			}
+		}
+	}
+	return userIDs, nil
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"recipe-archive/models"
+)
+
+func TestPurgeTrashRemovesOnlyExpiredTrash(t *testing.T) {
+	ctx := context.Background()
+	store := NewS3RecipeDB(newFakeS3(), "bucket")
+
+	now := time.Now().UTC()
+	longAgo := now.Add(-40 * 24 * time.Hour)
+	recent := now.Add(-2 * 24 * time.Hour)
+	recipes := []*models.Recipe{
+		{ID: "active", UserID: "u1", UpdatedAt: longAgo},
+		{ID: "expired", UserID: "u1", IsDeleted: true, DeletedAt: &longAgo},
+		{ID: "recent", UserID: "u1", IsDeleted: true, DeletedAt: &recent},
+		{ID: "legacy", UserID: "u1", IsDeleted: true, UpdatedAt: longAgo}, // trashed before deletedAt existed
+	}
+	for _, recipe := range recipes {
+		if err := store.CreateRecipe(ctx, recipe); err != nil {
+			t.Fatalf("create %s: %v", recipe.ID, err)
+		}
+	}
+
+	purged, err := PurgeTrash(ctx, store, "u1", now.Add(-30*24*time.Hour))
+	if err != nil || purged != 2 {
+		t.Fatalf("purge: got %d err %v want 2", purged, err)
+	}
+
+	remaining, err := store.ListRecipes(ctx, "u1")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	ids := map[string]bool{}
+	for _, recipe := range remaining {
+		ids[recipe.ID] = true
+	}
+	if len(ids) != 2 || !ids["active"] || !ids["recent"] {
+		t.Errorf("remaining recipes: got %v want active and recent", ids)
+	}
+}
+
+func TestPurgeTrashKeepsRecipeRestoredDuringPurge(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeS3()
+	store := NewS3RecipeDB(client, "bucket")
+
+	longAgo := time.Now().UTC().Add(-40 * 24 * time.Hour)
+	recipe := &models.Recipe{ID: "r1", UserID: "u1", Title: "Soup", IsDeleted: true, DeletedAt: &longAgo, Version: 2}
+	if err := store.CreateRecipe(ctx, recipe); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	// Restore the recipe after the purge has re-read it but before its delete reaches S3
+	client.beforeDelete = func(key string) {
+		if key != "recipes/u1/r1.json" {
+			return
+		}
+		client.beforeDelete = nil
+		restored := *recipe
+		restored.IsDeleted = false
+		restored.DeletedAt = nil
+		restored.Version = 3
+		if err := store.UpdateRecipe(ctx, &restored); err != nil {
+			t.Errorf("restore: %v", err)
+		}
+	}
+
+	purged, err := PurgeTrash(ctx, store, "u1", time.Now().UTC().Add(-30*24*time.Hour))
+	if err != nil || purged != 0 {
+		t.Fatalf("purge: got %d err %v want 0", purged, err)
+	}
+	got, err := store.GetRecipe(ctx, "u1", "r1")
+	if err != nil {
+		t.Fatalf("restored recipe was purged: %v", err)
+	}
+	if got.IsDeleted || got.Version != 3 {
+		t.Errorf("recipe: got deleted=%v version %d want restored version 3", got.IsDeleted, got.Version)
+	}
+}
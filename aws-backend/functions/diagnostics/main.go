@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -15,9 +16,14 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/google/uuid"
+
+	"recipe-archive/envelope"
 )
 
-var s3Client *s3.Client
+var (
+	s3Client  *s3.Client
+	encryptor *envelope.Encryptor
+)
 
 func init() {
 	cfg, err := config.LoadDefaultConfig(context.Background())
@@ -26,6 +32,14 @@ func init() {
 		return
 	}
 	s3Client = s3.NewFromConfig(cfg)
+
+	// Captured pages can hold whatever the user was reading, so they are sealed like recipes.
+	// Without a working encryptor nothing is stored rather than storing plaintext.
+	encryptor, err = envelope.FromEnv(cfg)
+	if err != nil {
+		fmt.Printf("Error configuring encryption: %v\n", err)
+		s3Client = nil
+	}
 }
 
 // DiagnosticData represents the structure of diagnostic data from extensions
@@ -113,19 +127,22 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 
 			filename := fmt.Sprintf("failed-parsing/%s_%s_%s.html", timestamp, url, uuid.New().String()[:8])
 
-			// Store in S3
-			_, err := s3Client.PutObject(ctx, &s3.PutObjectInput{
-				Bucket:      aws.String(bucketName),
-				Key:         aws.String(filename),
-				Body:        strings.NewReader(diagnosticData.HTML),
-				ContentType: aws.String("text/html"),
-				Metadata: map[string]string{
-					"url":        diagnosticData.URL,
-					"error-type": diagnosticData.ErrorType,
-					"user-agent": diagnosticData.UserAgent,
-					"timestamp":  diagnosticData.Timestamp,
-				},
-			})
+			body, contentType, err := sealHTML(ctx, filename, diagnosticData.HTML)
+			if err == nil {
+				// Store in S3
+				_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
+					Bucket:      aws.String(bucketName),
+					Key:         aws.String(filename),
+					Body:        bytes.NewReader(body),
+					ContentType: aws.String(contentType),
+					Metadata: map[string]string{
+						"url":        diagnosticData.URL,
+						"error-type": diagnosticData.ErrorType,
+						"user-agent": diagnosticData.UserAgent,
+						"timestamp":  diagnosticData.Timestamp,
+					},
+				})
+			}
 
 			if err != nil {
 				fmt.Printf("⚠️ Failed to store HTML in S3: %v\n", err)
@@ -161,6 +178,19 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 	}, nil
 }
 
+// sealHTML returns a captured page as stored under key: sealed for envelope.SystemUserID when
+// encryption is configured, otherwise as plain HTML
+func sealHTML(ctx context.Context, key, html string) ([]byte, string, error) {
+	if encryptor == nil {
+		return []byte(html), "text/html", nil
+	}
+	sealed, err := encryptor.Seal(ctx, envelope.SystemUserID, key, []byte(html))
+	if err != nil {
+		return nil, "", err
+	}
+	return sealed, envelope.ContentType, nil
+}
+
 func main() {
 	lambda.Start(handler)
 }
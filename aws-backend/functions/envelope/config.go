@@ -0,0 +1,37 @@
+package envelope
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+// FromEnv builds the Encryptor selected by RECIPE_ENCRYPTION: "kms" wraps data keys with
+// RECIPE_KMS_KEY_ID, "keyring" with the local keyring file RECIPE_KEYRING_PATH. Unset or
+// "off" returns nil, which stores objects in plaintext (sealed objects stay unreadable).
+func FromEnv(cfg aws.Config) (*Encryptor, error) {
+	switch mode := os.Getenv("RECIPE_ENCRYPTION"); mode {
+	case "", "off":
+		return nil, nil
+	case "kms":
+		keyID := os.Getenv("RECIPE_KMS_KEY_ID")
+		if keyID == "" {
+			return nil, fmt.Errorf("RECIPE_ENCRYPTION=kms requires RECIPE_KMS_KEY_ID")
+		}
+		return NewEncryptor(NewKMSProvider(kms.NewFromConfig(cfg), keyID)), nil
+	case "keyring":
+		path := os.Getenv("RECIPE_KEYRING_PATH")
+		if path == "" {
+			path = "./data/keyring.json"
+		}
+		keyring, err := OpenKeyring(path)
+		if err != nil {
+			return nil, err
+		}
+		return NewEncryptor(keyring), nil
+	default:
+		return nil, fmt.Errorf("unknown RECIPE_ENCRYPTION: %s", mode)
+	}
+}
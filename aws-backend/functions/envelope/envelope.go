@@ -0,0 +1,219 @@
+// Package envelope implements client-side envelope encryption for objects stored in S3.
+//
+// Every object is sealed with AES-256-GCM under a per-user data key. The data key is
+// wrapped by a KeyProvider (KMS in production, a local keyring file in dev and tests) and
+// the wrapped copy travels in the object's header, so reading an object needs nothing but
+// the provider. The object's storage key is authenticated as additional data, so a sealed
+// object copied to another key fails to open.
+//
+// Sealed layout: "RAE" 0x01 | uvarint len + provider name | uvarint len + wrapped key |
+// 12-byte nonce | ciphertext and GCM tag.
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ContentType is stored on sealed S3 objects in place of the plaintext's type
+const ContentType = "application/vnd.recipe-archive.envelope"
+
+// SystemUserID owns the data keys of objects that belong to no user, such as the page
+// captures under failed-parsing/
+const SystemUserID = "_system"
+
+// DataKeyLifetime is how long a generated data key keeps sealing new objects before the
+// next one for the same user is generated
+const DataKeyLifetime = time.Hour
+
+var magic = []byte{'R', 'A', 'E', 0x01}
+
+// ErrNotConfigured is returned when a sealed object is read without a key provider
+var ErrNotConfigured = errors.New("object is encrypted but no key provider is configured")
+
+// KeyProvider generates and unwraps data keys. Keys are bound to the user they were
+// generated for, so unwrapping with a different userID fails.
+type KeyProvider interface {
+	// Name identifies the provider in sealed objects
+	Name() string
+	GenerateDataKey(ctx context.Context, userID string) (plaintext, wrapped []byte, err error)
+	UnwrapDataKey(ctx context.Context, userID string, wrapped []byte) ([]byte, error)
+}
+
+// Encryptor seals and opens objects, caching data keys so a warm container calls the
+// provider once per user rather than once per object
+type Encryptor struct {
+	provider KeyProvider
+	now      func() time.Time
+
+	mu        sync.Mutex
+	current   map[string]*dataKey // by user, used for sealing
+	unwrapped map[string][]byte   // by user and wrapped key, used for opening
+}
+
+type dataKey struct {
+	plaintext []byte
+	wrapped   []byte
+	createdAt time.Time
+}
+
+// NewEncryptor creates an Encryptor backed by provider
+func NewEncryptor(provider KeyProvider) *Encryptor {
+	return &Encryptor{
+		provider:  provider,
+		now:       time.Now,
+		current:   map[string]*dataKey{},
+		unwrapped: map[string][]byte{},
+	}
+}
+
+// IsSealed reports whether data is a sealed object
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// Seal encrypts plaintext for userID, authenticating objectKey
+func (e *Encryptor) Seal(ctx context.Context, userID, objectKey string, plaintext []byte) ([]byte, error) {
+	key, err := e.dataKey(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key.plaintext)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	name := e.provider.Name()
+	out := make([]byte, 0, len(magic)+2*binary.MaxVarintLen64+len(name)+len(key.wrapped)+len(nonce)+len(plaintext)+gcm.Overhead())
+	out = append(out, magic...)
+	out = binary.AppendUvarint(out, uint64(len(name)))
+	out = append(out, name...)
+	out = binary.AppendUvarint(out, uint64(len(key.wrapped)))
+	out = append(out, key.wrapped...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plaintext, []byte(objectKey)), nil
+}
+
+// Open decrypts a sealed object stored at objectKey for userID
+func (e *Encryptor) Open(ctx context.Context, userID, objectKey string, sealed []byte) ([]byte, error) {
+	if !IsSealed(sealed) {
+		return nil, fmt.Errorf("object is not sealed")
+	}
+	rest := sealed[len(magic):]
+
+	name, rest, err := readField(rest)
+	if err != nil {
+		return nil, err
+	}
+	if string(name) != e.provider.Name() {
+		return nil, fmt.Errorf("object was sealed by key provider %q, configured provider is %q", name, e.provider.Name())
+	}
+	wrapped, rest, err := readField(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintextKey, err := e.unwrap(ctx, userID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(plaintextKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < gcm.NonceSize() {
+		return nil, fmt.Errorf("sealed object is truncated")
+	}
+	plaintext, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], []byte(objectKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt object: %w", err)
+	}
+	return plaintext, nil
+}
+
+// OpenIfSealed decrypts data if it is sealed and returns it unchanged otherwise, so objects
+// written before encryption was enabled stay readable. A nil Encryptor can only read
+// plaintext objects.
+func OpenIfSealed(ctx context.Context, e *Encryptor, userID, objectKey string, data []byte) ([]byte, error) {
+	if !IsSealed(data) {
+		return data, nil
+	}
+	if e == nil {
+		return nil, ErrNotConfigured
+	}
+	return e.Open(ctx, userID, objectKey, data)
+}
+
+// dataKey returns the user's current sealing key, generating one if it has expired
+func (e *Encryptor) dataKey(ctx context.Context, userID string) (*dataKey, error) {
+	e.mu.Lock()
+	key, ok := e.current[userID]
+	e.mu.Unlock()
+	if ok && e.now().Sub(key.createdAt) < DataKeyLifetime {
+		return key, nil
+	}
+
+	plaintext, wrapped, err := e.provider.GenerateDataKey(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	key = &dataKey{plaintext: plaintext, wrapped: wrapped, createdAt: e.now()}
+
+	e.mu.Lock()
+	e.current[userID] = key
+	e.unwrapped[userID+"\x00"+string(wrapped)] = plaintext
+	e.mu.Unlock()
+	return key, nil
+}
+
+// unwrap returns the plaintext of a wrapped data key, asking the provider only once per key
+func (e *Encryptor) unwrap(ctx context.Context, userID string, wrapped []byte) ([]byte, error) {
+	cacheKey := userID + "\x00" + string(wrapped)
+	e.mu.Lock()
+	plaintext, ok := e.unwrapped[cacheKey]
+	e.mu.Unlock()
+	if ok {
+		return plaintext, nil
+	}
+
+	plaintext, err := e.provider.UnwrapDataKey(ctx, userID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+
+	e.mu.Lock()
+	e.unwrapped[cacheKey] = plaintext
+	e.mu.Unlock()
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// readField reads one uvarint length-prefixed header field
+func readField(data []byte) ([]byte, []byte, error) {
+	n, size := binary.Uvarint(data)
+	if size <= 0 || uint64(len(data)-size) < n {
+		return nil, nil, fmt.Errorf("sealed object header is malformed")
+	}
+	data = data[size:]
+	return data[:n], data[n:], nil
+}
@@ -0,0 +1,80 @@
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSealAndOpen(t *testing.T) {
+	ctx := context.Background()
+	keyring, err := NewKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptor := NewEncryptor(keyring)
+	plaintext := []byte(`{"id":"r1","title":"Soup"}`)
+
+	sealed, err := encryptor.Seal(ctx, "u1", "recipes/u1/r1.json", plaintext)
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if !IsSealed(sealed) || bytes.Contains(sealed, []byte("Soup")) {
+		t.Fatalf("sealed object leaks plaintext: %q", sealed)
+	}
+
+	// A fresh Encryptor has no cached keys and must unwrap through the keyring
+	opened, err := NewEncryptor(keyring).Open(ctx, "u1", "recipes/u1/r1.json", sealed)
+	if err != nil || !bytes.Equal(opened, plaintext) {
+		t.Fatalf("open: %v %q", err, opened)
+	}
+
+	if _, err := encryptor.Open(ctx, "u1", "recipes/u1/other.json", sealed); err == nil {
+		t.Errorf("object opened under a different key")
+	}
+	if _, err := NewEncryptor(keyring).Open(ctx, "u2", "recipes/u1/r1.json", sealed); err == nil {
+		t.Errorf("object opened for a different user")
+	}
+
+	if data, err := OpenIfSealed(ctx, nil, "u1", "recipes/u1/r1.json", plaintext); err != nil || !bytes.Equal(data, plaintext) {
+		t.Errorf("plaintext not passed through: %v", err)
+	}
+	if _, err := OpenIfSealed(ctx, nil, "u1", "recipes/u1/r1.json", sealed); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("sealed object without a provider: got %v want ErrNotConfigured", err)
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keyring.json")
+
+	keyring, err := OpenKeyring(path)
+	if err != nil {
+		t.Fatalf("create keyring: %v", err)
+	}
+	sealed, err := NewEncryptor(keyring).Seal(ctx, "u1", "backups/u1/b.zip", []byte("zip"))
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+
+	// Rotate: a new primary seals, the old key still opens
+	rotated, err := NewKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring.Keys["k2"] = rotated.Keys["k1"]
+	keyring.Primary = "k2"
+	if err := keyring.Save(path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	reopened, err := OpenKeyring(path)
+	if err != nil {
+		t.Fatalf("reopen keyring: %v", err)
+	}
+	if opened, err := NewEncryptor(reopened).Open(ctx, "u1", "backups/u1/b.zip", sealed); err != nil || string(opened) != "zip" {
+		t.Errorf("open after rotation: %v %q", err, opened)
+	}
+}
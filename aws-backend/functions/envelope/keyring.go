@@ -0,0 +1,126 @@
+package envelope
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Keyring is a KeyProvider backed by master keys in a local JSON file, for development and
+// tests. New data keys are wrapped with the primary key; the others only unwrap, which
+// allows rotating the primary without re-encrypting existing objects.
+//
+//	{"primary": "k1", "keys": {"k1": "<base64 32-byte key>"}}
+type Keyring struct {
+	Primary string            `json:"primary"`
+	Keys    map[string][]byte `json:"keys"`
+}
+
+// OpenKeyring reads the keyring at path, creating one with a fresh primary key if the file
+// does not exist yet
+func OpenKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		keyring, err := NewKeyring()
+		if err != nil {
+			return nil, err
+		}
+		if err := keyring.Save(path); err != nil {
+			return nil, err
+		}
+		return keyring, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring: %w", err)
+	}
+
+	var keyring Keyring
+	if err := json.Unmarshal(data, &keyring); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring %s: %w", path, err)
+	}
+	if len(keyring.Keys[keyring.Primary]) != 32 {
+		return nil, fmt.Errorf("keyring %s: primary key %q is missing or not 32 bytes", path, keyring.Primary)
+	}
+	return &keyring, nil
+}
+
+// NewKeyring creates an in-memory keyring with one random primary key
+func NewKeyring() (*Keyring, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate keyring key: %w", err)
+	}
+	return &Keyring{Primary: "k1", Keys: map[string][]byte{"k1": key}}, nil
+}
+
+// Save writes the keyring to path, readable only by the owner
+func (k *Keyring) Save(path string) error {
+	data, err := json.MarshalIndent(k, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal keyring: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create keyring directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write keyring: %w", err)
+	}
+	return nil
+}
+
+// Name identifies keyring-wrapped keys in sealed objects
+func (k *Keyring) Name() string {
+	return "keyring"
+}
+
+// GenerateDataKey creates a random data key and wraps it with the primary key. The wrapped
+// form is the master key ID followed by the data key sealed with the user ID as additional data.
+func (k *Keyring) GenerateDataKey(ctx context.Context, userID string) ([]byte, []byte, error) {
+	plaintext := make([]byte, 32)
+	if _, err := rand.Read(plaintext); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	gcm, err := newGCM(k.Keys[k.Primary])
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	wrapped := append([]byte{byte(len(k.Primary))}, k.Primary...)
+	wrapped = append(wrapped, nonce...)
+	return plaintext, gcm.Seal(wrapped, nonce, plaintext, []byte(userID)), nil
+}
+
+// UnwrapDataKey decrypts a data key with the master key it names
+func (k *Keyring) UnwrapDataKey(ctx context.Context, userID string, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 1 || len(wrapped) < 1+int(wrapped[0]) {
+		return nil, fmt.Errorf("wrapped key is malformed")
+	}
+	keyID := string(wrapped[1 : 1+wrapped[0]])
+	master, ok := k.Keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("keyring has no key %q", keyID)
+	}
+
+	gcm, err := newGCM(master)
+	if err != nil {
+		return nil, err
+	}
+	rest := wrapped[1+len(keyID):]
+	if len(rest) < gcm.NonceSize() {
+		return nil, fmt.Errorf("wrapped key is truncated")
+	}
+	plaintext, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], []byte(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,63 @@
+package envelope
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// KMSAPI is the subset of the KMS client used by KMSProvider (satisfied by *kms.Client)
+type KMSAPI interface {
+	GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error)
+	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+}
+
+// KMSProvider wraps data keys with a KMS key. The user ID is passed as encryption
+// context, so KMS refuses to unwrap one user's key on behalf of another.
+type KMSProvider struct {
+	client KMSAPI
+	keyID  string
+}
+
+// NewKMSProvider creates a provider using the KMS key keyID (an ID, ARN or alias)
+func NewKMSProvider(client KMSAPI, keyID string) *KMSProvider {
+	return &KMSProvider{client: client, keyID: keyID}
+}
+
+// Name identifies KMS-wrapped keys in sealed objects
+func (p *KMSProvider) Name() string {
+	return "kms"
+}
+
+// GenerateDataKey asks KMS for a new AES-256 data key for userID
+func (p *KMSProvider) GenerateDataKey(ctx context.Context, userID string) ([]byte, []byte, error) {
+	output, err := p.client.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:             aws.String(p.keyID),
+		KeySpec:           types.DataKeySpecAes256,
+		EncryptionContext: encryptionContext(userID),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("kms GenerateDataKey: %w", err)
+	}
+	return output.Plaintext, output.CiphertextBlob, nil
+}
+
+// UnwrapDataKey asks KMS to decrypt a data key generated for userID
+func (p *KMSProvider) UnwrapDataKey(ctx context.Context, userID string, wrapped []byte) ([]byte, error) {
+	output, err := p.client.Decrypt(ctx, &kms.DecryptInput{
+		KeyId:             aws.String(p.keyID),
+		CiphertextBlob:    wrapped,
+		EncryptionContext: encryptionContext(userID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("kms Decrypt: %w", err)
+	}
+	return output.Plaintext, nil
+}
+
+func encryptionContext(userID string) map[string]string {
+	return map[string]string{"userId": userID}
+}
@@ -6,8 +6,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -15,6 +17,11 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/google/uuid"
+
+	"recipe-archive/models"
+	"recipe-archive/quota"
+	"recipe-archive/ratelimit"
+	"recipe-archive/utils"
 )
 
 type ImageUploadRequest struct {
@@ -27,10 +34,31 @@ type ImageUploadResponse struct {
 	Success  bool   `json:"success"`
 	ImageURL string `json:"imageUrl,omitempty"`
 	Message  string `json:"message,omitempty"`
-	Error    string `json:"error,omitempty"`
 }
 
-var s3Client *s3.Client
+// corsHeaders differ from utils.NewAPIResponse's: uploads come from the browser extensions
+// as well as the web app
+var corsHeaders = map[string]string{
+	"Access-Control-Allow-Origin":  "*",
+	"Access-Control-Allow-Headers": "Content-Type,X-Amz-Date,Authorization,X-Api-Key,X-Amz-Security-Token",
+	"Access-Control-Allow-Methods": "OPTIONS,POST",
+	"Content-Type":                 "application/json",
+}
+
+// imageStore is the part of the S3 client used for uploads and the storage quota (satisfied
+// by *s3.Client)
+type imageStore interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+}
+
+var s3Client imageStore
+var limiter *ratelimit.Limiter
+var quotas quota.Limits
+
+// uploadLimit caps image uploads per user, or per source IP for anonymous uploads
+var uploadLimit = ratelimit.Limit{Requests: 30, Per: time.Hour}
 
 func init() {
 	cfg, err := config.LoadDefaultConfig(context.TODO())
@@ -38,74 +66,80 @@ func init() {
 		log.Fatalf("Unable to load SDK config: %v", err)
 	}
 	s3Client = s3.NewFromConfig(cfg)
+	limiter = ratelimit.New(ratelimit.StoreFromEnv(cfg))
+	quotas = quota.FromEnv()
 }
 
+// handler reports errors from handleUpload in the standard error envelope
 func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	log.Printf("Image upload request received: %s %s", request.HTTPMethod, request.Path)
-
-	// CORS headers
-	headers := map[string]string{
-		"Access-Control-Allow-Origin":  "*",
-		"Access-Control-Allow-Headers": "Content-Type,X-Amz-Date,Authorization,X-Api-Key,X-Amz-Security-Token",
-		"Access-Control-Allow-Methods": "OPTIONS,POST",
-		"Content-Type":                 "application/json",
+	response, err := utils.WithErrorResponses(handleUpload)(ctx, request)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	if response.Headers == nil {
+		response.Headers = map[string]string{}
+	}
+	for name, value := range corsHeaders {
+		response.Headers[name] = value
 	}
+	return response, nil
+}
+
+func handleUpload(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	log.Printf("Image upload request received: %s %s", request.HTTPMethod, request.Path)
 
 	// Handle preflight OPTIONS request
 	if request.HTTPMethod == "OPTIONS" {
 		return events.APIGatewayProxyResponse{
 			StatusCode: 200,
-			Headers:    headers,
 			Body:       "",
 		}, nil
 	}
 
 	// Only allow POST for image upload
 	if request.HTTPMethod != "POST" {
-		return events.APIGatewayProxyResponse{
-			StatusCode: 405,
-			Headers:    headers,
-			Body:       `{"error": "Method not allowed"}`,
-		}, nil
+		return events.APIGatewayProxyResponse{}, utils.NewAppError(http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+			fmt.Sprintf("Method %s not allowed", request.HTTPMethod))
 	}
 
 	// Parse request body
 	var uploadReq ImageUploadRequest
 	if err := json.Unmarshal([]byte(request.Body), &uploadReq); err != nil {
 		log.Printf("Error parsing request body: %v", err)
-		return events.APIGatewayProxyResponse{
-			StatusCode: 400,
-			Headers:    headers,
-			Body:       `{"error": "Invalid request body"}`,
-		}, nil
+		return events.APIGatewayProxyResponse{}, utils.NewAppError(http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
 	}
 
 	// Validate required fields
-	if uploadReq.ImageData == "" || uploadReq.Filename == "" {
-		return events.APIGatewayProxyResponse{
-			StatusCode: 400,
-			Headers:    headers,
-			Body:       `{"error": "Missing required fields: filename and imageData"}`,
-		}, nil
+	var violations []models.FieldError
+	for _, field := range []string{"filename", "imageData"} {
+		if err := utils.ValidateRequired(uploadReq, field); err != nil {
+			violations = append(violations, models.FieldError{Field: field, Rule: "required", Message: err.Error()})
+		}
+	}
+	if len(violations) > 0 {
+		return events.APIGatewayProxyResponse{}, utils.NewValidationError(violations)
 	}
 
-	// Get user ID from JWT token (set by API Gateway authorizer)
-	userID := request.RequestContext.Authorizer["sub"]
-	if userIDStr, ok := userID.(string); ok && userIDStr != "" {
-		log.Printf("Upload request for user: %s", userIDStr)
+	// Get user ID from JWT token (set by API Gateway authorizer); anonymous uploads are
+	// rate limited by source IP instead, and count toward no one's storage quota
+	limitKey := "upload:ip:" + request.RequestContext.Identity.SourceIP
+	userID := uploaderID(request)
+	if userID != "" {
+		log.Printf("Upload request for user: %s", userID)
+		limitKey = "upload:" + userID
 	} else {
 		log.Printf("No user ID found in token, using anonymous")
 	}
+	limitHeaders, err := limiter.Check(ctx, limitKey, uploadLimit)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
 
 	// Decode base64 image data
 	imageBytes, err := base64.StdEncoding.DecodeString(uploadReq.ImageData)
 	if err != nil {
 		log.Printf("Error decoding base64 image: %v", err)
-		return events.APIGatewayProxyResponse{
-			StatusCode: 400,
-			Headers:    headers,
-			Body:       `{"error": "Invalid base64 image data"}`,
-		}, nil
+		return events.APIGatewayProxyResponse{}, utils.NewAppError(http.StatusBadRequest, "INVALID_IMAGE", "Invalid base64 image data")
 	}
 
 	// Generate unique key for S3
@@ -116,11 +150,7 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 	bucketName := os.Getenv("S3_STORAGE_BUCKET")
 	if bucketName == "" {
 		log.Printf("S3_STORAGE_BUCKET environment variable not set")
-		return events.APIGatewayProxyResponse{
-			StatusCode: 500,
-			Headers:    headers,
-			Body:       `{"error": "Storage configuration error"}`,
-		}, nil
+		return events.APIGatewayProxyResponse{}, utils.NewAppError(http.StatusInternalServerError, "CONFIGURATION_ERROR", "Storage configuration error")
 	}
 
 	// Set content type
@@ -140,23 +170,43 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		}
 	}
 
+	// Images are stored by image ID, so the uploader's bytes are recorded under their own
+	// prefix for the storage quota. The entry is written first and removed again if the
+	// upload fails, so an image is never stored uncounted.
+	var usageKey string
+	var metadata map[string]string
+	if userID != "" {
+		if err := quotas.CheckStorage(ctx, s3Client, bucketName, userID, int64(len(imageBytes))); err != nil {
+			return events.APIGatewayProxyResponse{}, err
+		}
+		usageKey = quota.ImageUsageKey(userID, imageID, int64(len(imageBytes)))
+		_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(usageKey),
+			Body:   strings.NewReader(""),
+		})
+		if err != nil {
+			return events.APIGatewayProxyResponse{}, utils.NewAppError(http.StatusInternalServerError, "UPLOAD_FAILED", "Failed to upload image").WithCause(err)
+		}
+		metadata = map[string]string{"user-id": userID}
+	}
+
 	// Upload to S3
 	_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
 		Bucket:      aws.String(bucketName),
 		Key:         aws.String(s3Key),
 		Body:        strings.NewReader(string(imageBytes)),
 		ContentType: aws.String(contentType),
+		Metadata:    metadata,
 		// Note: Removed ACL due to bucket's Block Public Access settings
 		// Images will be accessible via CloudFront or bucket policy if needed
 	})
 
 	if err != nil {
-		log.Printf("Error uploading to S3: %v", err)
-		return events.APIGatewayProxyResponse{
-			StatusCode: 500,
-			Headers:    headers,
-			Body:       `{"error": "Failed to upload image"}`,
-		}, nil
+		if usageKey != "" {
+			removeUsage(ctx, bucketName, usageKey)
+		}
+		return events.APIGatewayProxyResponse{}, utils.NewAppError(http.StatusInternalServerError, "UPLOAD_FAILED", "Failed to upload image").WithCause(err)
 	}
 
 	// Generate direct S3 URL for publicly accessible recipe images
@@ -174,11 +224,36 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 
 	return events.APIGatewayProxyResponse{
 		StatusCode: 200,
-		Headers:    headers,
+		Headers:    limitHeaders,
 		Body:       string(responseBody),
 	}, nil
 }
 
+// removeUsage deletes the quota entry of an upload that failed. It runs without the request's
+// deadline so a cancelled request still cleans up; a stray entry only overcounts the user.
+func removeUsage(ctx context.Context, bucketName, key string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	_, err := s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		log.Printf("Failed to remove storage quota entry %s: %v", key, err)
+	}
+}
+
+// uploaderID returns the user ID the API Gateway Cognito authorizer found in the token
+func uploaderID(request events.APIGatewayProxyRequest) string {
+	if claims, ok := request.RequestContext.Authorizer["claims"].(map[string]interface{}); ok {
+		if sub, ok := claims["sub"].(string); ok {
+			return sub
+		}
+	}
+	sub, _ := request.RequestContext.Authorizer["sub"].(string)
+	return sub
+}
+
 func main() {
 	lambda.Start(handler)
 }
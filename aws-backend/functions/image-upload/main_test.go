@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"recipe-archive/models"
+	"recipe-archive/quota"
+	"recipe-archive/ratelimit"
+)
+
+// recordingPutter records the keys of uploaded objects in order, with their sizes and metadata
+type recordingPutter struct {
+	keys     []string
+	sizes    map[string]int64
+	metadata map[string]map[string]string
+}
+
+func (p *recordingPutter) PutObject(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	key := aws.ToString(in.Key)
+	p.keys = append(p.keys, key)
+	p.sizes[key] = int64(len(data))
+	p.metadata[key] = in.Metadata
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (p *recordingPutter) DeleteObject(ctx context.Context, in *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	delete(p.sizes, aws.ToString(in.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func (p *recordingPutter) ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	out := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(false)}
+	for key, size := range p.sizes {
+		if strings.HasPrefix(key, aws.ToString(in.Prefix)) {
+			out.Contents = append(out.Contents, types.Object{Key: aws.String(key), Size: aws.Int64(size)})
+		}
+	}
+	return out, nil
+}
+
+func setupUpload(t *testing.T) *recordingPutter {
+	t.Helper()
+	savedClient, savedLimiter, savedLimit, savedQuotas := s3Client, limiter, uploadLimit, quotas
+	t.Cleanup(func() { s3Client, limiter, uploadLimit, quotas = savedClient, savedLimiter, savedLimit, savedQuotas })
+
+	putter := &recordingPutter{sizes: map[string]int64{}, metadata: map[string]map[string]string{}}
+	s3Client = putter
+	limiter = ratelimit.New(ratelimit.NewMemoryStore())
+	quotas = quota.Limits{}
+	uploadLimit = ratelimit.Limit{Requests: 2, Per: time.Hour}
+	t.Setenv("S3_STORAGE_BUCKET", "bucket")
+	return putter
+}
+
+func uploadRequest(userID, sourceIP string) events.APIGatewayProxyRequest {
+	body, _ := json.Marshal(ImageUploadRequest{
+		Filename:  "photo.jpg",
+		ImageData: base64.StdEncoding.EncodeToString([]byte("jpeg")),
+	})
+	request := events.APIGatewayProxyRequest{HTTPMethod: "POST", Body: string(body)}
+	request.RequestContext.Identity.SourceIP = sourceIP
+	if userID != "" {
+		request.RequestContext.Authorizer = map[string]interface{}{"claims": map[string]interface{}{"sub": userID}}
+	}
+	return request
+}
+
+func TestAnonymousUploadKeepsImageKeyLayout(t *testing.T) {
+	putter := setupUpload(t)
+
+	response, err := handler(context.Background(), uploadRequest("", "203.0.113.7"))
+	if err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("anonymous upload = %d %s, %v", response.StatusCode, response.Body, err)
+	}
+	if len(putter.keys) != 1 || !regexp.MustCompile(`^recipe-images/[0-9a-f-]{36}/photo\.jpg$`).MatchString(putter.keys[0]) {
+		t.Errorf("uploaded keys = %v, want recipe-images/{imageID}/photo.jpg", putter.keys)
+	}
+}
+
+func TestUploadRateLimit(t *testing.T) {
+	setupUpload(t)
+	ctx := context.Background()
+
+	for _, caller := range []struct{ userID, sourceIP string }{
+		{"user-1", "203.0.113.7"},
+		{"", "203.0.113.7"},
+	} {
+		for i := 0; i < uploadLimit.Requests; i++ {
+			response, err := handler(ctx, uploadRequest(caller.userID, caller.sourceIP))
+			if err != nil || response.StatusCode != http.StatusOK {
+				t.Fatalf("%+v upload %d = %d %s, %v", caller, i, response.StatusCode, response.Body, err)
+			}
+		}
+		response, _ := handler(ctx, uploadRequest(caller.userID, caller.sourceIP))
+		if response.StatusCode != http.StatusTooManyRequests || response.Headers["Retry-After"] == "" {
+			t.Errorf("%+v upload over limit = %d %v", caller, response.StatusCode, response.Headers)
+		}
+	}
+
+	// Other users and other addresses have their own buckets
+	for _, caller := range []struct{ userID, sourceIP string }{
+		{"user-2", "203.0.113.7"},
+		{"", "198.51.100.1"},
+	} {
+		if response, _ := handler(ctx, uploadRequest(caller.userID, caller.sourceIP)); response.StatusCode != http.StatusOK {
+			t.Errorf("%+v upload = %d %s", caller, response.StatusCode, response.Body)
+		}
+	}
+}
+
+func TestUploadCountsTowardUploaderStorageQuota(t *testing.T) {
+	putter := setupUpload(t)
+	quotas = quota.Limits{MaxStorageBytes: 6}
+	ctx := context.Background()
+
+	// "jpeg" is 4 bytes: the first upload fits, the second would pass the quota
+	response, err := handler(ctx, uploadRequest("user-1", "203.0.113.7"))
+	if err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("first upload = %d %s, %v", response.StatusCode, response.Body, err)
+	}
+	if len(putter.keys) != 2 || !regexp.MustCompile(`^image-usage/user-1/[0-9a-f-]{36}/4$`).MatchString(putter.keys[0]) {
+		t.Fatalf("uploaded keys = %v, want the usage entry before the image", putter.keys)
+	}
+	image := putter.keys[1]
+	if !strings.HasPrefix(image, "recipe-images/") || putter.metadata[image]["user-id"] != "user-1" {
+		t.Errorf("image %s stored with metadata %v, want user-id user-1", image, putter.metadata[image])
+	}
+
+	response, _ = handler(ctx, uploadRequest("user-1", "203.0.113.7"))
+	var body models.ErrorResponse
+	_ = json.Unmarshal([]byte(response.Body), &body)
+	if response.StatusCode != http.StatusForbidden || body.Error.Code != "QUOTA_EXCEEDED" {
+		t.Errorf("upload over quota = %d %s, want 403 QUOTA_EXCEEDED", response.StatusCode, response.Body)
+	}
+	if len(putter.keys) != 2 {
+		t.Errorf("uploaded keys = %v, want nothing stored over quota", putter.keys)
+	}
+
+	// Other users have their own quota
+	if response, _ := handler(ctx, uploadRequest("user-2", "203.0.113.7")); response.StatusCode != http.StatusOK {
+		t.Errorf("user-2 upload = %d %s", response.StatusCode, response.Body)
+	}
+}
@@ -431,7 +431,7 @@ func recipeHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	case "POST":
 		createRecipeHandler(w, r)
-	case "PUT":
+	case "PUT", "PATCH":
 		updateRecipeHandler(w, r)
 	case "DELETE":
 		deleteRecipeHandler(w, r)
@@ -607,7 +607,7 @@ func main() {
 
 	// Recipe routes
 	api.HandleFunc("/recipes", recipeHandler).Methods("GET", "POST", "OPTIONS")
-	api.HandleFunc("/recipes/{id}", recipeHandler).Methods("GET", "PUT", "DELETE", "OPTIONS")
+	api.HandleFunc("/recipes/{id}", recipeHandler).Methods("GET", "PUT", "PATCH", "DELETE", "OPTIONS")
 
 	// Backup routes
 	api.HandleFunc("/backup/create", createBackupHandler).Methods("POST", "OPTIONS")
@@ -623,7 +623,7 @@ func main() {
 			"safari-web-extension://*",
 			"moz-extension://*",
 		},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowCredentials: true,
 		AllowedHeaders:   []string{"*"},
 	})
@@ -1,21 +1,36 @@
 package localdb
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 
+	recipedb "recipe-archive/db"
 	"recipe-archive/models"
 )
 
-// LocalDB implements a file-based database for local development
+// LocalDB implements recipedb.RecipeDB on the local filesystem for development.
+// Structure mirrors the S3 layout: {dataDir}/{userID}/{recipeID}.json, with prior
+// versions in {dataDir}/{userID}/{recipeID}/versions/{n}.json
 type LocalDB struct {
 	dataDir string
 	mutex   sync.RWMutex
 }
 
+var (
+	_ recipedb.RecipeDB      = (*LocalDB)(nil)
+	_ recipedb.RecipeHistory = (*LocalDB)(nil)
+	_ recipedb.TrashPurger   = (*LocalDB)(nil)
+)
+
 // NewLocalDB creates a new local database instance
 func NewLocalDB(dataDir string) *LocalDB {
 	return &LocalDB{
@@ -24,133 +39,334 @@ func NewLocalDB(dataDir string) *LocalDB {
 }
 
 // GetRecipe retrieves a recipe by user ID and recipe ID
-func (db *LocalDB) GetRecipe(userID, recipeID string) (*models.Recipe, error) {
+func (db *LocalDB) GetRecipe(ctx context.Context, userID, recipeID string) (*models.Recipe, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := checkIDs(userID, recipeID); err != nil {
+		return nil, err
+	}
+
 	db.mutex.RLock()
 	defer db.mutex.RUnlock()
 
-	filePath := filepath.Join(db.dataDir, userID, fmt.Sprintf("%s.json", recipeID))
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("recipe not found: %w", err)
-	}
+	return db.readRecipe(userID, recipeID)
+}
 
-	var recipe models.Recipe
-	if err := json.Unmarshal(data, &recipe); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal recipe: %w", err)
+// ListRecipes returns every recipe for a user. Files that fail to load are
+// reported in a *recipedb.PartialListError alongside the recipes that did.
+func (db *LocalDB) ListRecipes(ctx context.Context, userID string) ([]models.Recipe, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	return &recipe, nil
-}
+	if err := checkIDs(userID); err != nil {
+		return nil, err
+	}
 
-// ListRecipes returns a list of recipes for a user
-func (db *LocalDB) ListRecipes(userID string, limit int) ([]models.Recipe, error) {
 	db.mutex.RLock()
 	defer db.mutex.RUnlock()
 
-	userDir := filepath.Join(db.dataDir, userID)
-	entries, err := os.ReadDir(userDir)
+	recipeIDs, err := db.listRecipeIDs(userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read user directory: %w", err)
+		return nil, err
 	}
 
-	var recipes []models.Recipe
-	count := 0
-	for _, entry := range entries {
-		if count >= limit && limit > 0 {
-			break
+	recipes := make([]models.Recipe, 0, len(recipeIDs))
+	var failures []recipedb.KeyFailure
+	for _, recipeID := range recipeIDs {
+		recipe, err := db.readRecipe(userID, recipeID)
+		if err != nil {
+			failures = append(failures, recipedb.KeyFailure{RecipeID: recipeID, Err: err})
+			continue
 		}
+		recipes = append(recipes, *recipe)
+	}
 
-		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".json" {
-			filePath := filepath.Join(userDir, entry.Name())
-			data, err := os.ReadFile(filePath)
-			if err != nil {
-				continue
-			}
-
-			var recipe models.Recipe
-			if err := json.Unmarshal(data, &recipe); err != nil {
-				continue
-			}
+	if len(failures) > 0 {
+		return recipes, &recipedb.PartialListError{Failures: failures}
+	}
+	return recipes, nil
+}
 
-			recipes = append(recipes, recipe)
-			count++
-		}
+// ListRecipeSummaries returns the index projection of every recipe for a user.
+// Reads are local and cheap, so summaries are derived directly from the recipe files.
+func (db *LocalDB) ListRecipeSummaries(ctx context.Context, userID string) ([]models.RecipeSummary, error) {
+	recipes, err := db.ListRecipes(ctx, userID)
+	var partial *recipedb.PartialListError
+	if err != nil && !errors.As(err, &partial) {
+		return nil, err
 	}
 
-	return recipes, nil
+	summaries := make([]models.RecipeSummary, 0, len(recipes))
+	for i := range recipes {
+		summaries = append(summaries, recipes[i].Summary())
+	}
+	return summaries, err
 }
 
 // CreateRecipe creates a new recipe
-func (db *LocalDB) CreateRecipe(recipe models.Recipe) error {
+func (db *LocalDB) CreateRecipe(ctx context.Context, recipe *models.Recipe) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := checkIDs(recipe.UserID, recipe.ID); err != nil {
+		return err
+	}
+
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
-	userDir := filepath.Join(db.dataDir, recipe.UserID)
-	if err := os.MkdirAll(userDir, 0755); err != nil {
-		return fmt.Errorf("failed to create user directory: %w", err)
+	return db.writeRecipe(recipe)
+}
+
+// UpdateRecipe updates an existing recipe (overwrites, like S3)
+func (db *LocalDB) UpdateRecipe(ctx context.Context, recipe *models.Recipe) error {
+	return db.CreateRecipe(ctx, recipe)
+}
+
+// UpdateRecipeIfVersion stores recipe only if the stored copy is still at expectedVersion.
+// The check and the write happen under the same lock, so they are atomic for this process.
+// The replaced copy is archived under {userID}/{recipeID}/versions/{n}.json first.
+func (db *LocalDB) UpdateRecipeIfVersion(ctx context.Context, recipe *models.Recipe, expectedVersion int) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	filePath := filepath.Join(userDir, fmt.Sprintf("%s.json", recipe.ID))
-	data, err := json.MarshalIndent(recipe, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal recipe: %w", err)
+	if err := checkIDs(recipe.UserID, recipe.ID); err != nil {
+		return err
 	}
 
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write recipe file: %w", err)
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	current, err := db.readRecipe(recipe.UserID, recipe.ID)
+	if err != nil {
+		return err
+	}
+	if current.Version != expectedVersion {
+		return &recipedb.VersionConflictError{Current: current}
+	}
+	if err := writeJSONFile(db.versionsDir(current.UserID, current.ID), db.versionPath(current.UserID, current.ID, current.Version), current); err != nil {
+		return fmt.Errorf("failed to archive recipe version %d: %w", current.Version, err)
 	}
 
-	return nil
+	return db.writeRecipe(recipe)
 }
 
-// UpdateRecipe updates an existing recipe
-func (db *LocalDB) UpdateRecipe(userID, recipeID string, updates map[string]interface{}) error {
+// DeleteRecipe deletes a recipe and its archived versions
+func (db *LocalDB) DeleteRecipe(ctx context.Context, userID, recipeID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := checkIDs(userID, recipeID); err != nil {
+		return err
+	}
+
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
-	// Get existing recipe
-	recipe, err := db.GetRecipe(userID, recipeID)
-	if err != nil {
+	return db.removeRecipe(userID, recipeID)
+}
+
+// DeleteTrashedRecipe deletes a recipe only if it is still in the trash at version. The
+// check and the delete happen under the same lock, so they are atomic for this process.
+func (db *LocalDB) DeleteTrashedRecipe(ctx context.Context, userID, recipeID string, version int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := checkIDs(userID, recipeID); err != nil {
 		return err
 	}
 
-	// Apply updates (simplified - in real implementation, you'd handle this more robustly)
-	data, err := json.Marshal(recipe)
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	current, err := db.readRecipe(userID, recipeID)
 	if err != nil {
 		return err
 	}
+	if !current.IsDeleted || current.Version != version {
+		return fmt.Errorf("recipe %s is no longer in the trash at version %d: %w", recipeID, version, recipedb.ErrConflict)
+	}
+	return db.removeRecipe(userID, recipeID)
+}
 
-	var recipeMap map[string]interface{}
-	if err := json.Unmarshal(data, &recipeMap); err != nil {
-		return err
+// ListRecipeVersions returns the archived versions of a recipe, oldest first
+func (db *LocalDB) ListRecipeVersions(ctx context.Context, userID, recipeID string) ([]models.RecipeVersionInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := checkIDs(userID, recipeID); err != nil {
+		return nil, err
 	}
 
-	for key, value := range updates {
-		recipeMap[key] = value
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	entries, err := os.ReadDir(db.versionsDir(userID, recipeID))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read versions directory: %w", err)
 	}
 
-	updatedData, err := json.Marshal(recipeMap)
+	versions := []models.RecipeVersionInfo{}
+	for _, entry := range entries {
+		version, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil || entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		versions = append(versions, models.RecipeVersionInfo{Version: version, SavedAt: info.ModTime().UTC()})
+	}
+
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	return versions, nil
+}
+
+// GetRecipeVersion retrieves one archived version of a recipe
+func (db *LocalDB) GetRecipeVersion(ctx context.Context, userID, recipeID string, version int) (*models.Recipe, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := checkIDs(userID, recipeID); err != nil {
+		return nil, err
+	}
+
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	data, err := os.ReadFile(db.versionPath(userID, recipeID, version))
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to read recipe version %d: %w", version, mapFSError(err))
 	}
 
-	var updatedRecipe models.Recipe
-	if err := json.Unmarshal(updatedData, &updatedRecipe); err != nil {
-		return err
+	return recipedb.DecodeRecipe(data)
+}
+
+// checkIDs refuses IDs that are not a single safe path segment, so "../victim/r1" cannot
+// reach another user's directory; there is no such recipe
+func checkIDs(ids ...string) error {
+	for _, id := range ids {
+		if !models.ValidID(id) {
+			return fmt.Errorf("%w: invalid ID %q", recipedb.ErrNotFound, id)
+		}
 	}
+	return nil
+}
+
+// The helpers below assume the caller holds the mutex and that the IDs passed checkIDs
 
-	return db.CreateRecipe(updatedRecipe)
+func (db *LocalDB) recipePath(userID, recipeID string) string {
+	return filepath.Join(db.dataDir, userID, fmt.Sprintf("%s.json", recipeID))
 }
 
-// DeleteRecipe deletes a recipe
-func (db *LocalDB) DeleteRecipe(userID, recipeID string) error {
-	db.mutex.Lock()
-	defer db.mutex.Unlock()
+func (db *LocalDB) versionsDir(userID, recipeID string) string {
+	return filepath.Join(db.dataDir, userID, recipeID, "versions")
+}
+
+func (db *LocalDB) versionPath(userID, recipeID string, version int) string {
+	return filepath.Join(db.versionsDir(userID, recipeID), fmt.Sprintf("%d.json", version))
+}
+
+func (db *LocalDB) readRecipe(userID, recipeID string) (*models.Recipe, error) {
+	data, err := os.ReadFile(db.recipePath(userID, recipeID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recipe: %w", mapFSError(err))
+	}
+
+	return recipedb.DecodeRecipe(data)
+}
+
+// removeRecipe deletes a recipe file and its archived versions
+func (db *LocalDB) removeRecipe(userID, recipeID string) error {
+	if err := os.Remove(db.recipePath(userID, recipeID)); err != nil {
+		return fmt.Errorf("failed to delete recipe: %w", mapFSError(err))
+	}
+	if err := os.RemoveAll(filepath.Join(db.dataDir, userID, recipeID)); err != nil {
+		return fmt.Errorf("failed to delete recipe versions: %w", err)
+	}
+	return nil
+}
+
+// writeRecipe writes the recipe to a temp file in the same directory and renames it
+// into place, so readers never observe a partially written recipe
+func (db *LocalDB) writeRecipe(recipe *models.Recipe) error {
+	return writeJSONFile(filepath.Join(db.dataDir, recipe.UserID), db.recipePath(recipe.UserID, recipe.ID), recipe)
+}
 
-	filePath := filepath.Join(db.dataDir, userID, fmt.Sprintf("%s.json", recipeID))
-	if err := os.Remove(filePath); err != nil {
-		return fmt.Errorf("failed to delete recipe: %w", err)
+// writeJSONFile atomically replaces path (inside dir) with the JSON encoding of v
+func writeJSONFile(dir, path string, v interface{}) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal recipe: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-"+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write recipe file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync recipe file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close recipe file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to set recipe file mode: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to move recipe file into place: %w", err)
 	}
 
 	return nil
 }
+
+// listRecipeIDs returns the IDs of every {recipeID}.json file in the user's directory
+func (db *LocalDB) listRecipeIDs(userID string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(db.dataDir, userID))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil // no recipes yet
+		}
+		return nil, fmt.Errorf("failed to read user directory: %w", err)
+	}
+
+	var recipeIDs []string
+	for _, entry := range entries {
+		name := entry.Name()
+		// Skip directories, temp files and reserved "_" names, as S3RecipeDB does
+		if entry.IsDir() || filepath.Ext(name) != ".json" || strings.HasPrefix(name, "_") || strings.HasPrefix(name, ".") {
+			continue
+		}
+		recipeIDs = append(recipeIDs, strings.TrimSuffix(name, ".json"))
+	}
+	return recipeIDs, nil
+}
+
+// mapFSError tags filesystem errors with the matching db sentinel
+func mapFSError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %w", recipedb.ErrNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,123 @@
+package localdb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	recipedb "recipe-archive/db"
+	"recipe-archive/models"
+)
+
+func TestLocalDBCRUD(t *testing.T) {
+	ctx := context.Background()
+	store := NewLocalDB(t.TempDir())
+
+	recipe := &models.Recipe{ID: "r1", UserID: "u1", Title: "Soup", Version: 1}
+	if err := store.CreateRecipe(ctx, recipe); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	got, err := store.GetRecipe(ctx, "u1", "r1")
+	if err != nil || got.Title != "Soup" {
+		t.Fatalf("get: got %+v err %v", got, err)
+	}
+
+	recipes, err := store.ListRecipes(ctx, "u1")
+	if err != nil || len(recipes) != 1 {
+		t.Fatalf("list: got %d recipes err %v", len(recipes), err)
+	}
+
+	if err := store.DeleteRecipe(ctx, "u1", "r1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.GetRecipe(ctx, "u1", "r1"); !errors.Is(err, recipedb.ErrNotFound) {
+		t.Errorf("get after delete: got %v want ErrNotFound", err)
+	}
+	if recipes, err := store.ListRecipes(ctx, "nobody"); err != nil || len(recipes) != 0 {
+		t.Errorf("list for unknown user: got %d recipes err %v", len(recipes), err)
+	}
+}
+
+func TestLocalDBUpdateIfVersionDoesNotDeadlock(t *testing.T) {
+	ctx := context.Background()
+	store := NewLocalDB(t.TempDir())
+	if err := store.CreateRecipe(ctx, &models.Recipe{ID: "r1", UserID: "u1", Title: "v1", Version: 1}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- store.UpdateRecipeIfVersion(ctx, &models.Recipe{ID: "r1", UserID: "u1", Title: "v2", Version: 2}, 1)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("update: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("UpdateRecipeIfVersion deadlocked")
+	}
+
+	err := store.UpdateRecipeIfVersion(ctx, &models.Recipe{ID: "r1", UserID: "u1", Title: "stale", Version: 2}, 1)
+	if !errors.Is(err, recipedb.ErrConflict) {
+		t.Errorf("stale update: got %v want ErrConflict", err)
+	}
+
+	if old, err := store.GetRecipeVersion(ctx, "u1", "r1", 1); err != nil || old.Title != "v1" {
+		t.Errorf("archived version 1: got %+v err %v", old, err)
+	}
+	if recipes, err := store.ListRecipes(ctx, "u1"); err != nil || len(recipes) != 1 {
+		t.Errorf("versions directory listed as recipes: got %d err %v", len(recipes), err)
+	}
+}
+
+func TestLocalDBSkipsCorruptAndReservedFiles(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := NewLocalDB(dir)
+	if err := store.CreateRecipe(ctx, &models.Recipe{ID: "good", UserID: "u1"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "u1", "bad.json"), []byte("{"), 0644)
+	os.WriteFile(filepath.Join(dir, "u1", "_index.json"), []byte("{}"), 0644)
+
+	recipes, err := store.ListRecipes(ctx, "u1")
+	var partial *recipedb.PartialListError
+	if !errors.As(err, &partial) || len(partial.Failures) != 1 || partial.Failures[0].RecipeID != "bad" {
+		t.Fatalf("expected a partial failure for bad.json, got %v", err)
+	}
+	if len(recipes) != 1 || recipes[0].ID != "good" {
+		t.Errorf("got %+v want only the good recipe", recipes)
+	}
+	if !errors.Is(partial.Failures[0].Err, recipedb.ErrCorrupt) {
+		t.Errorf("corrupt file should map to ErrCorrupt, got %v", partial.Failures[0].Err)
+	}
+}
+
+func TestLocalDBRejectsUnsafeIDs(t *testing.T) {
+	ctx := context.Background()
+	store := NewLocalDB(t.TempDir())
+	if err := store.CreateRecipe(ctx, &models.Recipe{ID: "r1", UserID: "victim", Title: "Soup", Version: 1}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	for _, id := range []string{"../victim/r1", `..\victim\r1`, "..", ".hidden", ""} {
+		if _, err := store.GetRecipe(ctx, "attacker", id); !errors.Is(err, recipedb.ErrNotFound) {
+			t.Errorf("get %q: got %v want ErrNotFound", id, err)
+		}
+		overwrite := &models.Recipe{ID: id, UserID: "attacker", Title: "Pwned", Version: 2}
+		if err := store.UpdateRecipeIfVersion(ctx, overwrite, 1); err == nil {
+			t.Errorf("update %q succeeded", id)
+		}
+	}
+	if _, err := store.ListRecipes(ctx, "../victim"); err == nil {
+		t.Error("list with an unsafe user ID succeeded")
+	}
+	if got, err := store.GetRecipe(ctx, "victim", "r1"); err != nil || got.Title != "Soup" {
+		t.Errorf("victim's recipe = %+v, %v", got, err)
+	}
+}
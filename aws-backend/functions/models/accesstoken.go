@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// AccessTokenPrefix starts every personal access token, which is how the API tells them
+// apart from Cognito JWTs
+const AccessTokenPrefix = "rat_"
+
+// Personal access token scopes
+const (
+	// ScopeRead allows reading recipes
+	ScopeRead = "read"
+	// ScopeWrite allows creating, changing and deleting recipes
+	ScopeWrite = "write"
+	// ScopeBackup allows creating and downloading backups
+	ScopeBackup = "backup"
+)
+
+// AccessToken describes a personal access token. The secret itself is shown once, when the
+// token is created; only a hash of it is stored.
+type AccessToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"userId"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// Expired reports whether the token can no longer be used at now
+func (t *AccessToken) Expired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+// CreateAccessTokenRequest is the body of POST /v1/tokens
+type CreateAccessTokenRequest struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=read write backup"`
+	// ExpiresInDays defaults to DefaultAccessTokenDays
+	ExpiresInDays *int `json:"expiresInDays,omitempty" validate:"omitempty,min=1,max=365"`
+}
+
+// DefaultAccessTokenDays is the lifetime of a token created without expiresInDays
+const DefaultAccessTokenDays = 90
+
+// CreateAccessTokenResponse returns a new token's secret alongside its description
+type CreateAccessTokenResponse struct {
+	Token       string      `json:"token"`
+	AccessToken AccessToken `json:"accessToken"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Change log entry types
+const (
+	ChangeCreate = "create"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete" // tombstone: the recipe was moved to the trash or permanently deleted
+)
+
+// RecipeChange is one entry of a user's append-only change log. Clients apply entries in
+// order, fetching the recipe for create and update and dropping it for delete.
+type RecipeChange struct {
+	Type      string    `json:"type"`
+	RecipeID  string    `json:"recipeId"`
+	Version   int       `json:"version,omitempty"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+// RecipeChangesResponse represents the API response for reading the change log. Cursor is
+// passed back as ?since= to continue; it stays valid indefinitely.
+type RecipeChangesResponse struct {
+	Changes []RecipeChange `json:"changes"`
+	Cursor  string         `json:"cursor"`
+	HasMore bool           `json:"hasMore"`
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// RecipeVersionInfo describes one archived prior version of a recipe
+type RecipeVersionInfo struct {
+	Version int       `json:"version"`
+	SavedAt time.Time `json:"savedAt"`
+}
+
+// RecipeVersionsResponse represents the API response for listing a recipe's versions
+type RecipeVersionsResponse struct {
+	RecipeID       string              `json:"recipeId"`
+	CurrentVersion int                 `json:"currentVersion"`
+	Versions       []RecipeVersionInfo `json:"versions"`
+}
+
+// FieldChange is a single top-level field that differs between two recipe versions.
+// From and To hold the JSON values; a nil side means the field is absent.
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// RecipeDiffResponse represents the API response for diffing two recipe versions
+type RecipeDiffResponse struct {
+	RecipeID    string        `json:"recipeId"`
+	FromVersion int           `json:"fromVersion"`
+	ToVersion   int           `json:"toVersion"`
+	Changes     []FieldChange `json:"changes"`
+}
+
+// DiffRecipes compares two recipes field by field using their JSON field names, so the
+// result reads like the API payload. Changes are sorted by field name.
+func DiffRecipes(from, to *Recipe) ([]FieldChange, error) {
+	fromFields, err := recipeFields(from)
+	if err != nil {
+		return nil, err
+	}
+	toFields, err := recipeFields(to)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(fromFields)+len(toFields))
+	for name := range fromFields {
+		names[name] = true
+	}
+	for name := range toFields {
+		names[name] = true
+	}
+
+	changes := []FieldChange{}
+	for name := range names {
+		if !reflect.DeepEqual(fromFields[name], toFields[name]) {
+			changes = append(changes, FieldChange{Field: name, From: fromFields[name], To: toFields[name]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+func recipeFields(recipe *Recipe) (map[string]interface{}, error) {
+	data, err := json.Marshal(recipe)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
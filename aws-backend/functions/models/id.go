@@ -0,0 +1,10 @@
+package models
+
+import "strings"
+
+// ValidID reports whether id can name a user, recipe or token: a single non-empty path
+// segment that is not "." or ".." and does not start with "." (reserved for temp files), so
+// it can be joined into storage keys and file paths as is
+func ValidID(id string) bool {
+	return id != "" && !strings.HasPrefix(id, ".") && !strings.ContainsAny(id, `/\`) && !strings.Contains(id, "..")
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// MergePatchContentType selects RFC 7396 JSON merge patch semantics for PATCH requests
+const MergePatchContentType = "application/merge-patch+json"
+
+// PreserveServerFields copies the fields clients never edit from the stored recipe, so
+// replacing or patching a recipe cannot drop them
+func (r *Recipe) PreserveServerFields(stored *Recipe) {
+	r.ID = stored.ID
+	r.UserID = stored.UserID
+	r.CreatedAt = stored.CreatedAt
+	r.WebArchiveURL = stored.WebArchiveURL
+	r.SearchMetadata = stored.SearchMetadata
+}
+
+// ApplyUpdate sets every field present in update, leaving the others untouched
+func (r *Recipe) ApplyUpdate(update UpdateRecipeRequest) {
+	if update.Title != nil {
+		r.Title = strings.TrimSpace(*update.Title)
+	}
+	if update.Ingredients != nil {
+		r.Ingredients = *update.Ingredients
+	}
+	if update.Instructions != nil {
+		r.Instructions = *update.Instructions
+	}
+	if update.SourceURL != nil {
+		r.SourceURL = strings.TrimSpace(*update.SourceURL)
+	}
+	if update.MainPhotoURL != nil {
+		r.MainPhotoURL = update.MainPhotoURL
+	}
+	if update.PrepTimeMinutes != nil {
+		r.PrepTimeMinutes = update.PrepTimeMinutes
+	}
+	if update.CookTimeMinutes != nil {
+		r.CookTimeMinutes = update.CookTimeMinutes
+	}
+	if update.TotalTimeMinutes != nil {
+		r.TotalTimeMinutes = update.TotalTimeMinutes
+	}
+	if update.Servings != nil {
+		r.Servings = update.Servings
+	}
+	if update.Yield != nil {
+		r.Yield = update.Yield
+	}
+	if update.Categories != nil {
+		r.Categories = *update.Categories
+	}
+	if update.Description != nil {
+		r.Description = update.Description
+	}
+	if update.Reviews != nil {
+		r.Reviews = update.Reviews
+	}
+	if update.Nutrition != nil {
+		r.Nutrition = update.Nutrition
+	}
+}
+
+// ApplyMergePatch applies an RFC 7396 merge patch to a copy of recipe: members of the patch
+// replace the recipe's, objects merge recursively and null removes a field. Server-managed
+// fields are kept from recipe whatever the patch says.
+func ApplyMergePatch(recipe *Recipe, patch []byte) (*Recipe, error) {
+	var patchDoc interface{}
+	if err := json.Unmarshal(patch, &patchDoc); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %w", err)
+	}
+	if _, ok := patchDoc.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("invalid merge patch: must be a JSON object")
+	}
+
+	original, err := json.Marshal(recipe)
+	if err != nil {
+		return nil, err
+	}
+	var target interface{}
+	if err := json.Unmarshal(original, &target); err != nil {
+		return nil, err
+	}
+
+	merged, err := json.Marshal(mergePatch(target, patchDoc))
+	if err != nil {
+		return nil, err
+	}
+	var patched Recipe
+	if err := json.Unmarshal(merged, &patched); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %w", err)
+	}
+
+	patched.PreserveServerFields(recipe)
+	patched.UpdatedAt = recipe.UpdatedAt
+	patched.IsDeleted = recipe.IsDeleted
+	patched.DeletedAt = recipe.DeletedAt
+	patched.Version = recipe.Version
+	patched.SchemaVersion = recipe.SchemaVersion
+	return &patched, nil
+}
+
+// mergePatch is the MergePatch function of RFC 7396 section 2
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = mergePatch(targetObject[name], value)
+		}
+	}
+	return targetObject
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestApplyMergePatch(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	archive := "https://archive.example.com/r1"
+	reviews := "Great"
+	recipe := &Recipe{
+		ID: "r1", UserID: "u1", Title: "Soup", Categories: []string{"soup"}, Reviews: &reviews,
+		Ingredients:    []Ingredient{{Text: "1 onion"}},
+		CreatedAt:      created,
+		Version:        3,
+		WebArchiveURL:  &archive,
+		SearchMetadata: &SearchMetadata{SemanticTags: []string{"warm"}},
+	}
+
+	patched, err := ApplyMergePatch(recipe, []byte(`{"title":"Stew","reviews":null,"servings":4,"id":"other","createdAt":"2030-01-01T00:00:00Z","searchMetadata":null,"version":9}`))
+	if err != nil {
+		t.Fatalf("patch: %v", err)
+	}
+	if patched.Title != "Stew" || patched.Reviews != nil || patched.Servings == nil || *patched.Servings != 4 {
+		t.Errorf("patched fields: %+v", patched)
+	}
+	if len(patched.Categories) != 1 || len(patched.Ingredients) != 1 {
+		t.Errorf("fields absent from the patch changed: %+v", patched)
+	}
+	if patched.ID != "r1" || !patched.CreatedAt.Equal(created) || patched.Version != 3 ||
+		patched.WebArchiveURL == nil || patched.SearchMetadata == nil {
+		t.Errorf("server-managed fields not preserved: %+v", patched)
+	}
+	if recipe.Title != "Soup" || recipe.Reviews == nil {
+		t.Errorf("original recipe modified: %+v", recipe)
+	}
+
+	if _, err := ApplyMergePatch(recipe, []byte(`["title"]`)); err == nil {
+		t.Error("non-object patch accepted")
+	}
+}
+
+func TestApplyUpdateOnlyTouchesProvidedFields(t *testing.T) {
+	reviews := "Great"
+	recipe := &Recipe{Title: "Soup", Categories: []string{"soup"}, Reviews: &reviews}
+	title := "  Stew "
+	recipe.ApplyUpdate(UpdateRecipeRequest{Title: &title})
+	if recipe.Title != "Stew" || recipe.Reviews == nil || len(recipe.Categories) != 1 {
+		t.Errorf("update: %+v", recipe)
+	}
+}
@@ -31,10 +31,14 @@ type Recipe struct {
 	Nutrition        *string  `json:"nutrition,omitempty" dynamodb:"nutrition,omitempty"`
 
 	// System Fields
-	CreatedAt time.Time `json:"createdAt" dynamodb:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt" dynamodb:"updatedAt"`
-	IsDeleted bool      `json:"isDeleted" dynamodb:"isDeleted"`
-	Version   int       `json:"version" dynamodb:"version"`
+	CreatedAt time.Time  `json:"createdAt" dynamodb:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt" dynamodb:"updatedAt"`
+	IsDeleted bool       `json:"isDeleted" dynamodb:"isDeleted"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty" dynamodb:"deletedAt,omitempty"` // When the recipe was moved to the trash
+	Version   int        `json:"version" dynamodb:"version"`
+
+	// SchemaVersion records the stored shape (see CurrentSchemaVersion in schema.go)
+	SchemaVersion int `json:"schemaVersion,omitempty" dynamodb:"schemaVersion,omitempty"`
 
 	// Archive & Backup
 	WebArchiveURL *string `json:"webArchiveUrl,omitempty" dynamodb:"webArchiveUrl,omitempty"`
@@ -43,6 +47,45 @@ type Recipe struct {
 	SearchMetadata *SearchMetadata `json:"searchMetadata,omitempty" dynamodb:"searchMetadata,omitempty"`
 }
 
+// RecipeSummary is the lightweight projection of a Recipe kept in the per-user index
+// so list and search can run without downloading every recipe body
+type RecipeSummary struct {
+	ID               string          `json:"id"`
+	Title            string          `json:"title"`
+	SourceURL        string          `json:"sourceUrl"`
+	MainPhotoURL     *string         `json:"mainPhotoUrl,omitempty"`
+	PrepTimeMinutes  *int            `json:"prepTimeMinutes,omitempty"`
+	CookTimeMinutes  *int            `json:"cookTimeMinutes,omitempty"`
+	TotalTimeMinutes *int            `json:"totalTimeMinutes,omitempty"`
+	Servings         *int            `json:"servings,omitempty"`
+	SearchMetadata   *SearchMetadata `json:"searchMetadata,omitempty"`
+	CreatedAt        time.Time       `json:"createdAt"`
+	UpdatedAt        time.Time       `json:"updatedAt"`
+	IsDeleted        bool            `json:"isDeleted"`
+	DeletedAt        *time.Time      `json:"deletedAt,omitempty"`
+	Version          int             `json:"version"`
+}
+
+// Summary returns the index projection of the recipe
+func (r *Recipe) Summary() RecipeSummary {
+	return RecipeSummary{
+		ID:               r.ID,
+		Title:            r.Title,
+		SourceURL:        r.SourceURL,
+		MainPhotoURL:     r.MainPhotoURL,
+		PrepTimeMinutes:  r.PrepTimeMinutes,
+		CookTimeMinutes:  r.CookTimeMinutes,
+		TotalTimeMinutes: r.TotalTimeMinutes,
+		Servings:         r.Servings,
+		SearchMetadata:   r.SearchMetadata,
+		CreatedAt:        r.CreatedAt,
+		UpdatedAt:        r.UpdatedAt,
+		IsDeleted:        r.IsDeleted,
+		DeletedAt:        r.DeletedAt,
+		Version:          r.Version,
+	}
+}
+
 // SearchMetadata contains OpenAI-generated search fields for cost-efficient in-Lambda filtering
 // Designed to minimize S3 storage costs while enabling fast in-memory search
 type SearchMetadata struct {
@@ -110,6 +153,39 @@ type UpdateRecipeRequest struct {
 	Version          *int           `json:"version,omitempty" validate:"omitempty,min=1"`
 }
 
+// BatchRecipeRequest represents the payload for applying several operations at once
+type BatchRecipeRequest struct {
+	Operations []BatchOperation `json:"operations" validate:"required,min=1,dive"`
+}
+
+// BatchOperation is one create, update or delete within a batch request. Recipe is required
+// for create and update, ID for update and delete. Version guards an update or delete against
+// concurrent edits, like the version field of a single update.
+type BatchOperation struct {
+	Op      string               `json:"op" validate:"required,oneof=create update delete"`
+	ID      string               `json:"id,omitempty"`
+	Recipe  *CreateRecipeRequest `json:"recipe,omitempty"`
+	Version *int                 `json:"version,omitempty" validate:"omitempty,min=1"`
+}
+
+// BatchOperationResult reports the outcome of one batch operation. Recipe summarizes the
+// written recipe on success; Error is set on failure.
+type BatchOperationResult struct {
+	Index  int            `json:"index"`
+	Op     string         `json:"op"`
+	ID     string         `json:"id,omitempty"`
+	Status int            `json:"status"`
+	Recipe *RecipeSummary `json:"recipe,omitempty"`
+	Error  *APIError      `json:"error,omitempty"`
+}
+
+// BatchRecipeResponse represents the API response for a batch request
+type BatchRecipeResponse struct {
+	Results   []BatchOperationResult `json:"results"`
+	Succeeded int                    `json:"succeeded"`
+	Failed    int                    `json:"failed"`
+}
+
 // RecipeResponse represents the API response for a single recipe
 type RecipeResponse struct {
 	Recipe Recipe `json:"recipe"`
@@ -121,6 +197,12 @@ type RecipesListResponse struct {
 	Pagination Pagination `json:"pagination"`
 }
 
+// RecipeSummariesListResponse represents the API response for listing recipe summaries (view=summary)
+type RecipeSummariesListResponse struct {
+	Recipes    []RecipeSummary `json:"recipes"`
+	Pagination Pagination      `json:"pagination"`
+}
+
 // Pagination represents pagination metadata
 type Pagination struct {
 	NextCursor *string `json:"nextCursor,omitempty"`
@@ -162,6 +244,19 @@ type APIError struct {
 	RequestID string      `json:"requestId"`
 }
 
+// FieldError describes one invalid field of a request. Field is the JSON path of the value,
+// such as "ingredients[2].text", and Rule the check it failed.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationDetails is the APIError.Details of a VALIDATION_ERROR, listing every violation
+type ValidationDetails struct {
+	Fields []FieldError `json:"fields"`
+}
+
 // ErrorResponse represents the API error response wrapper
 type ErrorResponse struct {
 	Error APIError `json:"error"`
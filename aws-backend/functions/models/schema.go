@@ -0,0 +1,178 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CurrentSchemaVersion is the stored-recipe schema this code writes. Every stored recipe is
+// stamped with it (see MarshalJSON); objects with a higher schemaVersion are refused.
+//
+//	0  unversioned objects in any of the legacy shapes (see upgradeLegacyRecipe)
+//	1  the canonical camelCase Recipe shape
+const CurrentSchemaVersion = 1
+
+// ErrSchemaTooNew means a stored recipe was written by newer code than this binary understands
+var ErrSchemaTooNew = errors.New("recipe schema version is newer than supported")
+
+// SchemaMigration upgrades a raw stored recipe document from FromVersion to FromVersion+1.
+// Steps work on the decoded JSON object so they can handle shapes Recipe cannot decode.
+type SchemaMigration struct {
+	FromVersion int
+	Description string
+	Upgrade     func(doc map[string]interface{}) error
+}
+
+// SchemaMigrations is the ordered registry of upgrade steps. To change the stored shape,
+// bump CurrentSchemaVersion and append a step; never edit a released step.
+var SchemaMigrations = []SchemaMigration{
+	{FromVersion: 0, Description: "normalize legacy field names and value types", Upgrade: upgradeLegacyRecipe},
+}
+
+// DocumentSchemaVersion returns the schemaVersion stamped on a raw document (0 when absent)
+func DocumentSchemaVersion(doc map[string]interface{}) int {
+	if v, ok := doc["schemaVersion"].(float64); ok {
+		return int(v)
+	}
+	return 0
+}
+
+// UpgradeRecipeDocument applies every migration step needed to bring doc to
+// CurrentSchemaVersion, in place, and returns the version it started at
+func UpgradeRecipeDocument(doc map[string]interface{}) (int, error) {
+	from := DocumentSchemaVersion(doc)
+	if from > CurrentSchemaVersion {
+		return from, fmt.Errorf("%w: stored version %d, supported up to %d", ErrSchemaTooNew, from, CurrentSchemaVersion)
+	}
+
+	for version := from; version < CurrentSchemaVersion; version++ {
+		step, ok := findSchemaMigration(version)
+		if !ok {
+			return from, fmt.Errorf("no schema migration registered from version %d", version)
+		}
+		if err := step.Upgrade(doc); err != nil {
+			return from, fmt.Errorf("schema migration %d->%d (%s): %w", version, version+1, step.Description, err)
+		}
+		doc["schemaVersion"] = float64(version + 1)
+	}
+	return from, nil
+}
+
+// DecodeStoredRecipe decodes a stored recipe object of any supported schema version,
+// upgrading older shapes in memory. Objects newer than CurrentSchemaVersion fail with
+// ErrSchemaTooNew.
+func DecodeStoredRecipe(data []byte) (*Recipe, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	from, err := UpgradeRecipeDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	if from != CurrentSchemaVersion {
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, err
+		}
+	}
+
+	var recipe Recipe
+	if err := json.Unmarshal(data, &recipe); err != nil {
+		return nil, err
+	}
+	return &recipe, nil
+}
+
+// MarshalJSON stamps the recipe with CurrentSchemaVersion so every stored copy records its shape
+func (r Recipe) MarshalJSON() ([]byte, error) {
+	type RecipeAlias Recipe
+	alias := RecipeAlias(r)
+	alias.SchemaVersion = CurrentSchemaVersion
+	return json.Marshal(alias)
+}
+
+func findSchemaMigration(fromVersion int) (SchemaMigration, bool) {
+	for _, step := range SchemaMigrations {
+		if step.FromVersion == fromVersion {
+			return step, true
+		}
+	}
+	return SchemaMigration{}, false
+}
+
+// legacyFieldNames maps snake_case keys (local-server) and older camelCase names to the
+// canonical ones, in priority order. Legacy names only fill a canonical field that is missing.
+var legacyFieldNames = [][2]string{
+	{"user_id", "userId"},
+	{"source_url", "sourceUrl"},
+	{"main_photo_url", "mainPhotoUrl"},
+	{"prep_time_minutes", "prepTimeMinutes"},
+	{"cook_time_minutes", "cookTimeMinutes"},
+	{"total_time_minutes", "totalTimeMinutes"},
+	{"created_at", "createdAt"},
+	{"updated_at", "updatedAt"},
+	{"is_deleted", "isDeleted"},
+	{"web_archive_url", "webArchiveUrl"},
+	{"search_metadata", "searchMetadata"},
+	{"prepTime", "prepTimeMinutes"}, // pre-expanded contract and background normalizer
+	{"cookTime", "cookTimeMinutes"}, // pre-expanded contract and background normalizer
+	{"tags", "categories"},          // background normalizer
+}
+
+// upgradeLegacyRecipe is the 0->1 step. Unversioned objects come from three writers:
+// the recipes Lambda and the background normalizer before versioning (camelCase, string or
+// int times; the normalizer also wrote prepTime/cookTime and tags) and local-server
+// (snake_case, plain string ingredients and instructions).
+func upgradeLegacyRecipe(doc map[string]interface{}) error {
+	for _, names := range legacyFieldNames {
+		legacy, canonical := names[0], names[1]
+		value, ok := doc[legacy]
+		if !ok {
+			continue
+		}
+		delete(doc, legacy)
+		if _, exists := doc[canonical]; !exists {
+			doc[canonical] = value
+		}
+	}
+
+	// Integers were sometimes stored as strings ("15"); empty or unparseable values are dropped
+	for _, field := range []string{"prepTimeMinutes", "cookTimeMinutes", "totalTimeMinutes", "servings"} {
+		switch v := doc[field].(type) {
+		case string:
+			if parsed, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+				doc[field] = float64(parsed)
+			} else {
+				delete(doc, field)
+			}
+		case nil:
+			delete(doc, field)
+		}
+	}
+
+	// Optional strings were sometimes stored as "" instead of being omitted
+	for _, field := range []string{"mainPhotoUrl", "description", "yield", "reviews", "nutrition", "webArchiveUrl", "createdAt", "updatedAt"} {
+		if v, ok := doc[field].(string); ok && v == "" {
+			delete(doc, field)
+		}
+	}
+
+	if items, ok := doc["ingredients"].([]interface{}); ok {
+		for i, item := range items {
+			if text, ok := item.(string); ok {
+				items[i] = map[string]interface{}{"text": text}
+			}
+		}
+	}
+	if items, ok := doc["instructions"].([]interface{}); ok {
+		for i, item := range items {
+			if text, ok := item.(string); ok {
+				items[i] = map[string]interface{}{"stepNumber": float64(i + 1), "text": text}
+			}
+		}
+	}
+	return nil
+}
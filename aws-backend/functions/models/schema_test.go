@@ -0,0 +1,58 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestDecodeStoredRecipeUpgradesLegacyShapes(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"background normalizer", `{"id":"r1","userId":"u1","title":"Soup","prepTime":"15","cookTime":30,"tags":["soup"],"mainPhotoUrl":"","ingredients":[{"text":"1 onion"}],"instructions":[{"stepNumber":1,"text":"Chop"}],"createdAt":"2024-01-02T03:04:05Z","updatedAt":""}`},
+		{"local-server", `{"id":"r1","user_id":"u1","title":"Soup","prep_time_minutes":15,"cook_time_minutes":30,"tags":["soup"],"ingredients":["1 onion"],"instructions":["Chop"],"created_at":"2024-01-02T03:04:05Z"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recipe, err := DecodeStoredRecipe([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if recipe.UserID != "u1" || recipe.PrepTimeMinutes == nil || *recipe.PrepTimeMinutes != 15 ||
+				recipe.CookTimeMinutes == nil || *recipe.CookTimeMinutes != 30 || recipe.MainPhotoURL != nil {
+				t.Errorf("fields not upgraded: %+v", recipe)
+			}
+			if len(recipe.Categories) != 1 || recipe.Categories[0] != "soup" {
+				t.Errorf("tags not mapped to categories: %v", recipe.Categories)
+			}
+			if len(recipe.Ingredients) != 1 || recipe.Ingredients[0].Text != "1 onion" ||
+				len(recipe.Instructions) != 1 || recipe.Instructions[0].StepNumber != 1 {
+				t.Errorf("ingredients/instructions not upgraded: %+v %+v", recipe.Ingredients, recipe.Instructions)
+			}
+			if recipe.CreatedAt.Year() != 2024 {
+				t.Errorf("createdAt not kept: %v", recipe.CreatedAt)
+			}
+		})
+	}
+}
+
+func TestStoredRecipeSchemaVersion(t *testing.T) {
+	data, err := json.Marshal(&Recipe{ID: "r1", Title: "Soup"})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got := DocumentSchemaVersion(doc); got != CurrentSchemaVersion {
+		t.Errorf("marshalled recipe stamped with %d, want %d", got, CurrentSchemaVersion)
+	}
+
+	_, err = DecodeStoredRecipe([]byte(`{"id":"r1","title":"From the future","schemaVersion":99}`))
+	if !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("newer schema: got %v want ErrSchemaTooNew", err)
+	}
+}
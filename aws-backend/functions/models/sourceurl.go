@@ -0,0 +1,84 @@
+package models
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// trackingParams are query parameters that identify a visit rather than a page
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true, "yclid": true, "igshid": true,
+	"mc_cid": true, "mc_eid": true, "_ga": true, "_gl": true, "ref": true, "ref_src": true,
+	"cmpid": true, "s_cid": true, "soc_src": true, "soc_trk": true, "share": true,
+	"amp": true, "outputtype": true,
+}
+
+// CanonicalSourceURL normalizes a recipe page URL so that variants of the same page compare
+// equal: the scheme becomes https, the host is lowercased without "www."/"m."/"amp." and
+// default ports, utm_* and other tracking parameters are dropped, the remaining parameters
+// are sorted, and fragments, trailing slashes and AMP path markers are removed. Google AMP
+// cache links are unwrapped to the page they serve. Values that are not absolute http(s)
+// URLs are returned trimmed.
+func CanonicalSourceURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return raw
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+	path := u.EscapedPath()
+
+	// https://www.google.com/amp/s/example.com/recipe -> https://example.com/recipe
+	if (host == "www.google.com" || host == "google.com") && strings.HasPrefix(path, "/amp/") {
+		target := strings.TrimPrefix(path, "/amp/")
+		target = strings.TrimPrefix(target, "s/")
+		if u.RawQuery != "" {
+			target += "?" + u.RawQuery
+		}
+		return CanonicalSourceURL("https://" + target)
+	}
+
+	for _, prefix := range []string{"www.", "m.", "amp."} {
+		host = strings.TrimPrefix(host, prefix)
+	}
+
+	// AMP variants live at /amp/..., .../amp or ....amp next to the regular page
+	path = strings.TrimSuffix(path, "/")
+	if strings.HasPrefix(path, "/amp/") {
+		path = strings.TrimPrefix(path, "/amp")
+	}
+	path = strings.TrimSuffix(path, "/amp")
+	path = strings.TrimSuffix(path, ".amp")
+	path = strings.TrimSuffix(path, "/")
+
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		lower := strings.ToLower(key)
+		if strings.HasPrefix(lower, "utm_") || trackingParams[lower] {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var params []string
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			params = append(params, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		}
+	}
+
+	canonical := "https://" + host + path
+	if len(params) > 0 {
+		canonical += "?" + strings.Join(params, "&")
+	}
+	return canonical
+}
@@ -0,0 +1,28 @@
+package models
+
+import "testing"
+
+func TestCanonicalSourceURL(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"https://example.com/soup", "https://example.com/soup"},
+		{"  HTTP://WWW.Example.com:80/soup/#comments ", "https://example.com/soup"},
+		{"https://m.example.com/soup?utm_source=pinterest&utm_medium=social&fbclid=abc", "https://example.com/soup"},
+		{"https://example.com/soup?page=2&id=7&gclid=x", "https://example.com/soup?id=7&page=2"},
+		{"https://example.com/soup/amp/", "https://example.com/soup"},
+		{"https://amp.example.com/amp/soup?amp=1", "https://example.com/soup"},
+		{"https://www.google.com/amp/s/www.example.com/soup.amp", "https://example.com/soup"},
+		{"https://example.com:8443/soup", "https://example.com:8443/soup"},
+		{"https://example.com/", "https://example.com"},
+		{"https://example.com/ampersand-cake", "https://example.com/ampersand-cake"},
+		{"not a url", "not a url"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := CanonicalSourceURL(tt.raw); got != tt.want {
+			t.Errorf("CanonicalSourceURL(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
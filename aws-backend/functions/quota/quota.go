@@ -0,0 +1,130 @@
+// Package quota caps how much each user may keep: recipes outside the trash, and bytes of
+// backups and uploaded images. Images are stored by image ID rather than by user, so each
+// upload also records an entry under its uploader's prefix (see ImageUsageKey). Unlike rate
+// limits, quotas do not refill; the user has to delete something to get under them again.
+package quota
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"recipe-archive/utils"
+)
+
+const (
+	// DefaultMaxRecipes applies when RECIPE_QUOTA is unset
+	DefaultMaxRecipes = 10000
+	// DefaultMaxStorageMB applies when STORAGE_QUOTA_MB is unset
+	DefaultMaxStorageMB = 1024
+)
+
+// Limits are the per-user quotas; zero means unlimited
+type Limits struct {
+	MaxRecipes      int
+	MaxStorageBytes int64
+}
+
+// FromEnv reads RECIPE_QUOTA (recipes) and STORAGE_QUOTA_MB (backups and images)
+func FromEnv() Limits {
+	limits := Limits{MaxRecipes: DefaultMaxRecipes, MaxStorageBytes: DefaultMaxStorageMB << 20}
+	if val := os.Getenv("RECIPE_QUOTA"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			limits.MaxRecipes = parsed
+		}
+	}
+	if val := os.Getenv("STORAGE_QUOTA_MB"); val != "" {
+		if parsed, err := strconv.ParseInt(val, 10, 64); err == nil && parsed >= 0 {
+			limits.MaxStorageBytes = parsed << 20
+		}
+	}
+	return limits
+}
+
+// imageUsageRoot holds the entries written by ImageUsageKey
+const imageUsageRoot = "image-usage/"
+
+// StoragePrefixes are the S3 prefixes whose objects count toward a user's storage quota
+func StoragePrefixes(userID string) []string {
+	return []string{
+		fmt.Sprintf("backups/%s/", userID),
+		fmt.Sprintf("%s%s/", imageUsageRoot, userID),
+	}
+}
+
+// ImageUsageKey is the key of the empty object that attributes an uploaded image of size
+// bytes to userID. The size is part of the key so StorageUsed can sum it from a listing.
+func ImageUsageKey(userID, imageID string, size int64) string {
+	return fmt.Sprintf("%s%s/%s/%d", imageUsageRoot, userID, imageID, size)
+}
+
+// CheckRecipes fails with QUOTA_EXCEEDED if adding recipes to the active ones would pass the quota
+func (l Limits) CheckRecipes(active, adding int) error {
+	if l.MaxRecipes == 0 || active+adding <= l.MaxRecipes {
+		return nil
+	}
+	return exceeded("recipes", int64(active), int64(l.MaxRecipes),
+		fmt.Sprintf("Recipe limit of %d reached; delete recipes to add more", l.MaxRecipes))
+}
+
+// CheckStorage fails with QUOTA_EXCEEDED if storing adding more bytes would pass the quota
+func (l Limits) CheckStorage(ctx context.Context, client s3.ListObjectsV2APIClient, bucketName, userID string, adding int64) error {
+	if l.MaxStorageBytes == 0 {
+		return nil
+	}
+	used, err := StorageUsed(ctx, client, bucketName, userID)
+	if err != nil {
+		return utils.NewInternalError("Failed to check storage quota", err)
+	}
+	if used+adding <= l.MaxStorageBytes {
+		return nil
+	}
+	return exceeded("storage", used, l.MaxStorageBytes,
+		fmt.Sprintf("Storage limit of %d MB reached; delete backups to free space", l.MaxStorageBytes>>20))
+}
+
+// StorageUsed sums the size of the user's objects under StoragePrefixes
+func StorageUsed(ctx context.Context, client s3.ListObjectsV2APIClient, bucketName, userID string) (int64, error) {
+	var used int64
+	for _, prefix := range StoragePrefixes(userID) {
+		paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+			Bucket: aws.String(bucketName),
+			Prefix: aws.String(prefix),
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return 0, fmt.Errorf("failed to list %s: %w", prefix, err)
+			}
+			for _, obj := range page.Contents {
+				used += storedBytes(obj)
+			}
+		}
+	}
+	return used, nil
+}
+
+// storedBytes is the size an object counts for: its own, or for an image usage entry the
+// size of the image it records
+func storedBytes(obj types.Object) int64 {
+	key := aws.ToString(obj.Key)
+	if strings.HasPrefix(key, imageUsageRoot) {
+		size, err := strconv.ParseInt(key[strings.LastIndex(key, "/")+1:], 10, 64)
+		if err == nil {
+			return size
+		}
+	}
+	return aws.ToInt64(obj.Size)
+}
+
+func exceeded(quota string, used, limit int64, message string) *utils.AppError {
+	return utils.NewAppError(http.StatusForbidden, "QUOTA_EXCEEDED", message).
+		WithDetails(map[string]interface{}{"quota": quota, "used": used, "limit": limit})
+}
@@ -0,0 +1,73 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"recipe-archive/utils"
+)
+
+// fakeLister lists object sizes by key
+type fakeLister map[string]int64
+
+func (f fakeLister) ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	out := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(false)}
+	for key, size := range f {
+		if strings.HasPrefix(key, aws.ToString(in.Prefix)) {
+			out.Contents = append(out.Contents, types.Object{Key: aws.String(key), Size: aws.Int64(size)})
+		}
+	}
+	return out, nil
+}
+
+func quotaStatus(err error) int {
+	var appErr *utils.AppError
+	if errors.As(err, &appErr) && appErr.Code == "QUOTA_EXCEEDED" {
+		return appErr.Status
+	}
+	return 0
+}
+
+func TestCheckStorage(t *testing.T) {
+	lister := fakeLister{
+		"backups/user-1/backup_1.zip":      600,
+		"backups/user-1/backup_2.zip":      300,
+		"backups/user-2/backup_3.zip":      5000,
+		"recipe-images/a/photo.jpg":        5000,
+		"recipes/user-1/r.json":            5000,
+		ImageUsageKey("user-1", "a", 50):   0,
+		ImageUsageKey("user-2", "b", 5000): 0,
+	}
+	limits := Limits{MaxStorageBytes: 1000}
+
+	used, err := StorageUsed(context.Background(), lister, "bucket", "user-1")
+	if err != nil || used != 950 {
+		t.Fatalf("StorageUsed = %d, %v; want 950", used, err)
+	}
+	if err := limits.CheckStorage(context.Background(), lister, "bucket", "user-1", 50); err != nil {
+		t.Errorf("upload that fits: %v", err)
+	}
+	if err := limits.CheckStorage(context.Background(), lister, "bucket", "user-1", 51); quotaStatus(err) != http.StatusForbidden {
+		t.Errorf("upload over quota: %v", err)
+	}
+	if err := (Limits{}).CheckStorage(context.Background(), lister, "bucket", "user-2", 1<<40); err != nil {
+		t.Errorf("unlimited: %v", err)
+	}
+}
+
+func TestCheckRecipes(t *testing.T) {
+	limits := Limits{MaxRecipes: 10}
+	if err := limits.CheckRecipes(9, 1); err != nil {
+		t.Errorf("tenth recipe: %v", err)
+	}
+	if err := limits.CheckRecipes(10, 1); quotaStatus(err) != http.StatusForbidden {
+		t.Errorf("eleventh recipe: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoAPI is the subset of the DynamoDB client used by DynamoStore (satisfied by *dynamodb.Client)
+type DynamoAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+// DynamoStore keeps buckets in a DynamoDB table keyed by "key" (string), shared by every
+// Lambda container. Items carry tokens, updated (Unix nanoseconds), version and expiresAt
+// (Unix seconds), which should be the table's TTL attribute so idle buckets are removed.
+type DynamoStore struct {
+	client    DynamoAPI
+	tableName string
+}
+
+// NewDynamoStore creates a store on tableName
+func NewDynamoStore(client DynamoAPI, tableName string) *DynamoStore {
+	return &DynamoStore{client: client, tableName: tableName}
+}
+
+// StoreFromEnv returns a DynamoStore on RATE_LIMIT_TABLE_NAME, or a MemoryStore when unset
+func StoreFromEnv(cfg aws.Config) Store {
+	if tableName := os.Getenv("RATE_LIMIT_TABLE_NAME"); tableName != "" {
+		return NewDynamoStore(dynamodb.NewFromConfig(cfg), tableName)
+	}
+	return NewMemoryStore()
+}
+
+// Get reads the bucket at key
+func (s *DynamoStore) Get(ctx context.Context, key string) (Bucket, int64, bool, error) {
+	output, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.tableName),
+		Key:            map[string]types.AttributeValue{"key": &types.AttributeValueMemberS{Value: key}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return Bucket{}, 0, false, err
+	}
+	if output.Item == nil {
+		return Bucket{}, 0, false, nil
+	}
+
+	tokens, errTokens := strconv.ParseFloat(numberAttribute(output.Item, "tokens"), 64)
+	updated, errUpdated := strconv.ParseInt(numberAttribute(output.Item, "updated"), 10, 64)
+	version, errVersion := strconv.ParseInt(numberAttribute(output.Item, "version"), 10, 64)
+	if err := errors.Join(errTokens, errUpdated, errVersion); err != nil {
+		return Bucket{}, 0, false, fmt.Errorf("malformed rate limit item %s: %w", key, err)
+	}
+	return Bucket{Tokens: tokens, Updated: time.Unix(0, updated)}, version, true, nil
+}
+
+// CompareAndSwap writes the bucket if its version is unchanged
+func (s *DynamoStore) CompareAndSwap(ctx context.Context, key string, version int64, bucket Bucket, expires time.Time) (bool, error) {
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item: map[string]types.AttributeValue{
+			"key":       &types.AttributeValueMemberS{Value: key},
+			"tokens":    &types.AttributeValueMemberN{Value: strconv.FormatFloat(bucket.Tokens, 'f', -1, 64)},
+			"updated":   &types.AttributeValueMemberN{Value: strconv.FormatInt(bucket.Updated.UnixNano(), 10)},
+			"version":   &types.AttributeValueMemberN{Value: strconv.FormatInt(version+1, 10)},
+			"expiresAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(expires.Unix()+1, 10)},
+		},
+	}
+	if version == 0 {
+		// KEY is a reserved word
+		input.ConditionExpression = aws.String("attribute_not_exists(#key)")
+		input.ExpressionAttributeNames = map[string]string{"#key": "key"}
+	} else {
+		input.ConditionExpression = aws.String("version = :expected")
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":expected": &types.AttributeValueMemberN{Value: strconv.FormatInt(version, 10)},
+		}
+	}
+
+	_, err := s.client.PutItem(ctx, input)
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// numberAttribute returns a number attribute as text, or "" (which fails to parse) if missing
+func numberAttribute(item map[string]types.AttributeValue, name string) string {
+	if number, ok := item[name].(*types.AttributeValueMemberN); ok {
+		return number.Value
+	}
+	return ""
+}
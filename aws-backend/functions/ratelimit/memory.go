@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps buckets in process memory. In Lambda each container has its own, so
+// limits are enforced per container; use a DynamoStore to share them.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	now     func() time.Time
+	swaps   int
+}
+
+type memoryEntry struct {
+	bucket  Bucket
+	version int64
+	expires time.Time
+}
+
+// memorySweepEvery is how many writes pass between sweeps of expired buckets
+const memorySweepEvery = 1000
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]memoryEntry{}, now: time.Now}
+}
+
+// Get returns the bucket at key
+func (s *MemoryStore) Get(ctx context.Context, key string) (Bucket, int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		return Bucket{}, 0, false, nil
+	}
+	return entry.bucket, entry.version, true, nil
+}
+
+// CompareAndSwap stores bucket if key is still at version
+func (s *MemoryStore) CompareAndSwap(ctx context.Context, key string, version int64, bucket Bucket, expires time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.entries[key].version != version {
+		return false, nil
+	}
+	s.entries[key] = memoryEntry{bucket: bucket, version: version + 1, expires: expires}
+
+	// Full buckets need no state, so expired entries are dropped now and then
+	if s.swaps++; s.swaps%memorySweepEvery == 0 {
+		now := s.now()
+		for k, entry := range s.entries {
+			if now.After(entry.expires) {
+				delete(s.entries, k)
+			}
+		}
+	}
+	return true, nil
+}
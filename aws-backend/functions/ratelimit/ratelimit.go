@@ -0,0 +1,196 @@
+// Package ratelimit throttles API callers with token buckets.
+//
+// Each bucket holds up to Limit.Requests tokens and refills at Limit.Requests per Limit.Per; a
+// request takes one token or is refused with 429 until one is available. Responses carry
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers, and refusals Retry-After.
+// Bucket state lives in a Store, updated with compare-and-swap so concurrent Lambda
+// containers sharing a store never grant the same token twice.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"recipe-archive/utils"
+)
+
+// maxSwapAttempts bounds retries when concurrent requests race for the same bucket
+const maxSwapAttempts = 5
+
+// Limit is the size and refill rate of a token bucket
+type Limit struct {
+	// Requests is the bucket size: how many requests may be made at once
+	Requests int
+	// Per is how long an empty bucket takes to refill
+	Per time.Duration
+}
+
+func (l Limit) perSecond() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// Bucket is the stored state of one token bucket
+type Bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// Store keeps buckets by key
+type Store interface {
+	// Get returns the bucket at key and its version; found is false if there is none
+	Get(ctx context.Context, key string) (bucket Bucket, version int64, found bool, err error)
+	// CompareAndSwap stores bucket at key if the stored version is still version (0 when
+	// absent) and reports whether it did. The bucket may be forgotten after expires.
+	CompareAndSwap(ctx context.Context, key string, version int64, bucket Bucket, expires time.Time) (bool, error)
+}
+
+// Result is the outcome of taking a token
+type Result struct {
+	Allowed   bool
+	Limit     Limit
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until a refused request could succeed
+	RetryAfter time.Duration
+}
+
+// Headers returns the RateLimit-* headers describing the result, plus Retry-After if refused
+func (r Result) Headers() map[string]string {
+	headers := map[string]string{
+		"RateLimit-Limit":     strconv.Itoa(r.Limit.Requests),
+		"RateLimit-Remaining": strconv.Itoa(r.Remaining),
+		"RateLimit-Reset":     strconv.Itoa(ceilSeconds(r.Reset)),
+		"RateLimit-Policy":    fmt.Sprintf("%d;w=%d", r.Limit.Requests, ceilSeconds(r.Limit.Per)),
+	}
+	if !r.Allowed {
+		headers["Retry-After"] = strconv.Itoa(ceilSeconds(r.RetryAfter))
+	}
+	return headers
+}
+
+// Limiter takes tokens from buckets in a store
+type Limiter struct {
+	store Store
+	now   func() time.Time
+}
+
+// New creates a limiter backed by store
+func New(store Store) *Limiter {
+	return &Limiter{store: store, now: time.Now}
+}
+
+// Allow takes a token from the bucket at key, refilling it for the time since its last use
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	for attempt := 0; attempt < maxSwapAttempts; attempt++ {
+		now := l.now()
+		bucket, version, found, err := l.store.Get(ctx, key)
+		if err != nil {
+			return Result{}, fmt.Errorf("failed to read rate limit %s: %w", key, err)
+		}
+
+		capacity := float64(limit.Requests)
+		tokens := capacity
+		if found {
+			elapsed := now.Sub(bucket.Updated).Seconds()
+			tokens = math.Min(capacity, bucket.Tokens+math.Max(0, elapsed)*limit.perSecond())
+		}
+
+		if tokens < 1 {
+			// Refusals leave the bucket alone; it keeps refilling from its last update
+			return Result{
+				Limit:      limit,
+				Reset:      secondsDuration((capacity - tokens) / limit.perSecond()),
+				RetryAfter: secondsDuration((1 - tokens) / limit.perSecond()),
+			}, nil
+		}
+
+		tokens--
+		reset := secondsDuration((capacity - tokens) / limit.perSecond())
+		swapped, err := l.store.CompareAndSwap(ctx, key, version, Bucket{Tokens: tokens, Updated: now}, now.Add(reset))
+		if err != nil {
+			return Result{}, fmt.Errorf("failed to update rate limit %s: %w", key, err)
+		}
+		if swapped {
+			return Result{Allowed: true, Limit: limit, Remaining: int(tokens), Reset: reset}, nil
+		}
+	}
+	return Result{}, fmt.Errorf("rate limit %s is too contended", key)
+}
+
+// Check takes a token and returns the headers to send. A refusal is returned as a 429
+// *utils.AppError carrying the headers. If the store fails the request is let through, so
+// an outage of the limiter never takes the API down with it.
+func (l *Limiter) Check(ctx context.Context, key string, limit Limit) (map[string]string, error) {
+	result, err := l.Allow(ctx, key, limit)
+	if err != nil {
+		fmt.Printf("⚠️ Rate limiting skipped: %v\n", err)
+		return nil, nil
+	}
+	if !result.Allowed {
+		appErr := utils.NewAppError(http.StatusTooManyRequests, "RATE_LIMITED",
+			fmt.Sprintf("Too many requests; retry in %d seconds", ceilSeconds(result.RetryAfter))).
+			WithDetails(map[string]interface{}{"limit": limit.Requests, "windowSeconds": ceilSeconds(limit.Per)})
+		for name, value := range result.Headers() {
+			appErr = appErr.WithHeader(name, value)
+		}
+		return nil, appErr
+	}
+	return result.Headers(), nil
+}
+
+// Middleware limits each caller to limit for a named group of routes. keyFor identifies the
+// caller; requests it returns "" for are not limited. When several limits apply, the headers
+// of the innermost, most specific one are sent.
+func (l *Limiter) Middleware(name string, limit Limit, keyFor func(context.Context) string) func(utils.Handler) utils.Handler {
+	return func(next utils.Handler) utils.Handler {
+		return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			caller := keyFor(ctx)
+			if caller == "" {
+				return next(ctx, request)
+			}
+			headers, err := l.Check(ctx, name+":"+caller, limit)
+			if err != nil {
+				return events.APIGatewayProxyResponse{}, err
+			}
+
+			response, err := next(ctx, request)
+			if err != nil {
+				var appErr *utils.AppError
+				if !errors.As(err, &appErr) {
+					return response, err
+				}
+				for header, value := range headers {
+					if _, set := appErr.Headers[header]; !set {
+						appErr = appErr.WithHeader(header, value)
+					}
+				}
+				return response, appErr
+			}
+			if response.Headers == nil {
+				response.Headers = map[string]string{}
+			}
+			for header, value := range headers {
+				if _, set := response.Headers[header]; !set {
+					response.Headers[header] = value
+				}
+			}
+			return response, nil
+		}
+	}
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"recipe-archive/utils"
+)
+
+func newTestLimiter(now *time.Time) *Limiter {
+	limiter := New(NewMemoryStore())
+	limiter.now = func() time.Time { return *now }
+	return limiter
+}
+
+func TestAllowRefillsOverTime(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	limiter := newTestLimiter(&now)
+	limit := Limit{Requests: 3, Per: time.Minute}
+
+	for i := 2; i >= 0; i-- {
+		result, err := limiter.Allow(ctx, "user-1", limit)
+		if err != nil || !result.Allowed || result.Remaining != i {
+			t.Fatalf("request %d: %+v, %v", 3-i, result, err)
+		}
+	}
+
+	refused, err := limiter.Allow(ctx, "user-1", limit)
+	if err != nil || refused.Allowed {
+		t.Fatalf("fourth request: %+v, %v", refused, err)
+	}
+	if refused.RetryAfter != 20*time.Second || refused.Reset != time.Minute {
+		t.Errorf("RetryAfter = %v, Reset = %v; want 20s, 1m", refused.RetryAfter, refused.Reset)
+	}
+	if headers := refused.Headers(); headers["Retry-After"] != "20" || headers["RateLimit-Remaining"] != "0" {
+		t.Errorf("headers = %v", headers)
+	}
+
+	if other, _ := limiter.Allow(ctx, "user-2", limit); !other.Allowed {
+		t.Error("another key shares the bucket")
+	}
+
+	now = now.Add(20 * time.Second)
+	if result, _ := limiter.Allow(ctx, "user-1", limit); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("after refill: %+v", result)
+	}
+	now = now.Add(time.Hour)
+	if result, _ := limiter.Allow(ctx, "user-1", limit); result.Remaining != 2 {
+		t.Errorf("bucket overfilled: %+v", result)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	limiter := newTestLimiter(&now)
+	handler := limiter.Middleware("api", Limit{Requests: 1, Per: time.Minute}, func(context.Context) string { return "user-1" })(
+		func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+		})
+
+	response, err := handler(context.Background(), events.APIGatewayProxyRequest{})
+	if err != nil || response.Headers["RateLimit-Limit"] != "1" || response.Headers["RateLimit-Remaining"] != "0" {
+		t.Fatalf("first request: %+v, %v", response, err)
+	}
+
+	_, err = handler(context.Background(), events.APIGatewayProxyRequest{})
+	var appErr *utils.AppError
+	if !errors.As(err, &appErr) || appErr.Status != http.StatusTooManyRequests || appErr.Headers["Retry-After"] != "60" {
+		t.Fatalf("second request: %v", err)
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Get(ctx context.Context, key string) (Bucket, int64, bool, error) {
+	return Bucket{}, 0, false, errors.New("store unavailable")
+}
+
+func (failingStore) CompareAndSwap(ctx context.Context, key string, version int64, bucket Bucket, expires time.Time) (bool, error) {
+	return false, errors.New("store unavailable")
+}
+
+func TestCheckFailsOpen(t *testing.T) {
+	headers, err := New(failingStore{}).Check(context.Background(), "user-1", Limit{Requests: 1, Per: time.Minute})
+	if err != nil || headers != nil {
+		t.Errorf("Check = %v, %v; want the request let through", headers, err)
+	}
+}
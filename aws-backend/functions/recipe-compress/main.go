@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"recipe-archive/db"
+	"recipe-archive/envelope"
+)
+
+// recipe-compress rewrites every object under recipes/ (recipes, archived versions and
+// summary indexes) in the storage format configured by RECIPE_COMPRESSION and
+// RECIPE_ENCRYPTION, so existing libraries match what the recipes Lambda now writes.
+//
+//	RECIPE_COMPRESSION=zstd recipe-compress -bucket my-bucket -dry-run
+//	RECIPE_COMPRESSION=zstd recipe-compress -bucket my-bucket
+//
+// Objects already in the target format are skipped, so the command can be re-run safely.
+// Writes are conditional on the ETag that was read, so concurrent edits are never lost.
+func main() {
+	var bucketName = flag.String("bucket", "", "S3 bucket holding recipes/{userID}/... objects")
+	var userID = flag.String("user-id", "", "Only rewrite this user's objects")
+	var dryRun = flag.Bool("dry-run", false, "List the objects that would be rewritten without writing them")
+	flag.Parse()
+
+	if *bucketName == "" {
+		fmt.Println("-bucket is required")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Fatalf("Failed to load AWS config: %v", err)
+	}
+	codec, err := db.ObjectCodecFromEnv(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure recipe storage encoding: %v", err)
+	}
+
+	prefix := "recipes/"
+	if *userID != "" {
+		prefix += *userID + "/"
+	}
+
+	client := s3.NewFromConfig(cfg)
+	var result rewriteResult
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(*bucketName),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Fatalf("Failed to list %s: %v", prefix, err)
+		}
+		for _, obj := range page.Contents {
+			key := aws.ToString(obj.Key)
+			if err := rewriteObject(ctx, client, codec, *bucketName, key, *dryRun, &result); err != nil {
+				fmt.Printf("❌ %s: %v\n", key, err)
+				result.Failed++
+			}
+		}
+	}
+
+	verb := "Rewrote"
+	if *dryRun {
+		verb = "Would rewrite"
+	}
+	fmt.Printf("🗜️ %s %d objects (%d -> %d bytes), %d already %s, %d failed\n",
+		verb, result.Rewritten, result.BytesBefore, result.BytesAfter, result.Skipped, describe(codec), result.Failed)
+	if result.Failed > 0 {
+		os.Exit(2)
+	}
+}
+
+// rewriteResult summarizes one run
+type rewriteResult struct {
+	Rewritten   int
+	Skipped     int
+	Failed      int
+	BytesBefore int64
+	BytesAfter  int64
+}
+
+// rewriteObject re-encodes one object unless it is already in the codec's format
+func rewriteObject(ctx context.Context, client *s3.Client, codec db.ObjectCodec, bucketName, key string, dryRun bool, result *rewriteResult) error {
+	parts := strings.SplitN(key, "/", 3)
+	if len(parts) < 3 || parts[1] == "" {
+		return fmt.Errorf("not a per-user object")
+	}
+	userID := parts[1]
+
+	output, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get object: %w", err)
+	}
+	body, err := io.ReadAll(output.Body)
+	output.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read object: %w", err)
+	}
+
+	// Compression is recorded inside the sealed body, so open it before checking
+	inner, err := envelope.OpenIfSealed(ctx, codec.Encryptor, userID, key, body)
+	if err != nil {
+		return err
+	}
+	if db.DetectCompression(inner) == codec.Compression && envelope.IsSealed(body) == (codec.Encryptor != nil) {
+		result.Skipped++
+		return nil
+	}
+
+	data, err := db.ObjectCodec{}.Decode(ctx, userID, key, inner)
+	if err != nil {
+		return err
+	}
+	obj, err := codec.Encode(ctx, userID, key, data)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		fmt.Printf("🔄 %s: %d -> %d bytes\n", key, len(body), len(obj.Body))
+	} else {
+		input := obj.PutObjectInput(bucketName, key)
+		input.Metadata = output.Metadata
+		input.IfMatch = output.ETag
+		if _, err := client.PutObject(ctx, input); err != nil {
+			return fmt.Errorf("failed to put object: %w", err)
+		}
+	}
+	result.Rewritten++
+	result.BytesBefore += int64(len(body))
+	result.BytesAfter += int64(len(obj.Body))
+	return nil
+}
+
+// describe names the target format for the summary line
+func describe(codec db.ObjectCodec) string {
+	format := "uncompressed"
+	if codec.Compression != db.CompressionNone {
+		format = string(codec.Compression)
+	}
+	if codec.Encryptor != nil {
+		format += ", encrypted"
+	}
+	return format
+}
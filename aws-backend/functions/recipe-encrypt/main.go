@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"recipe-archive/envelope"
+)
+
+// recipe-encrypt seals the plaintext objects under recipes/ and backups/ in place, using
+// the key provider selected by RECIPE_ENCRYPTION (see envelope.FromEnv), together with the
+// page captures under failed-parsing/ in the failed-parsing bucket.
+//
+//	RECIPE_ENCRYPTION=kms RECIPE_KMS_KEY_ID=alias/recipe-archive recipe-encrypt -bucket my-bucket -dry-run
+//	RECIPE_ENCRYPTION=kms RECIPE_KMS_KEY_ID=alias/recipe-archive recipe-encrypt -bucket my-bucket -failed-parsing-bucket my-failed-parsing-bucket
+//
+// Objects that are already sealed are skipped, so the command can be re-run after a failure.
+func main() {
+	var bucketName = flag.String("bucket", "", "S3 bucket holding recipes/ and backups/")
+	var failedParsingBucket = flag.String("failed-parsing-bucket", os.Getenv("S3_FAILED_PARSING_BUCKET"), "S3 bucket holding failed-parsing/ (skipped when empty)")
+	var userID = flag.String("user-id", "", "Only encrypt this user's objects (skips failed-parsing/)")
+	var dryRun = flag.Bool("dry-run", false, "List the objects that would be encrypted without writing them")
+	flag.Parse()
+
+	if *bucketName == "" {
+		fmt.Println("-bucket is required")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Fatalf("Failed to load AWS config: %v", err)
+	}
+	encryptor, err := envelope.FromEnv(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure encryption: %v", err)
+	}
+	if encryptor == nil {
+		log.Fatalf("RECIPE_ENCRYPTION is not set; choose \"kms\" or \"keyring\"")
+	}
+
+	client := s3.NewFromConfig(cfg)
+	var sealed, skipped, failed int
+	roots := []struct{ bucket, prefix string }{
+		{*bucketName, "recipes/"},
+		{*bucketName, "backups/"},
+	}
+	if *failedParsingBucket != "" && *userID == "" {
+		roots = append(roots, struct{ bucket, prefix string }{*failedParsingBucket, failedParsingPrefix})
+	}
+	for _, root := range roots {
+		prefix := root.prefix
+		if *userID != "" {
+			prefix += *userID + "/"
+		}
+
+		paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+			Bucket: aws.String(root.bucket),
+			Prefix: aws.String(prefix),
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				log.Fatalf("Failed to list %s: %v", prefix, err)
+			}
+			for _, obj := range page.Contents {
+				key := aws.ToString(obj.Key)
+				done, err := sealObject(ctx, client, encryptor, root.bucket, key, *dryRun)
+				switch {
+				case err != nil:
+					fmt.Printf("❌ %s: %v\n", key, err)
+					failed++
+				case done:
+					sealed++
+				default:
+					skipped++
+				}
+			}
+		}
+	}
+
+	verb := "Encrypted"
+	if *dryRun {
+		verb = "Would encrypt"
+	}
+	fmt.Printf("🔒 %s %d objects, %d already encrypted, %d failed\n", verb, sealed, skipped, failed)
+	if failed > 0 {
+		os.Exit(2)
+	}
+}
+
+// sealObject encrypts one object unless it is already sealed, reporting whether it did.
+// The put is conditional on the ETag that was read, so a concurrent write is never lost.
+func sealObject(ctx context.Context, client *s3.Client, encryptor *envelope.Encryptor, bucketName, key string, dryRun bool) (bool, error) {
+	userID, err := objectOwner(key)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to get object: %w", err)
+	}
+	data, err := io.ReadAll(result.Body)
+	result.Body.Close()
+	if err != nil {
+		return false, fmt.Errorf("failed to read object: %w", err)
+	}
+	if envelope.IsSealed(data) {
+		return false, nil
+	}
+
+	if dryRun {
+		fmt.Printf("🔄 %s\n", key)
+		return true, nil
+	}
+
+	sealed, err := encryptor.Seal(ctx, userID, key, data)
+	if err != nil {
+		return false, err
+	}
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucketName),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(sealed),
+		ContentType: aws.String(envelope.ContentType),
+		Metadata:    result.Metadata,
+		IfMatch:     result.ETag,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to put object: %w", err)
+	}
+	return true, nil
+}
+
+// failedParsingPrefix holds the page captures stored by the diagnostics function
+const failedParsingPrefix = "failed-parsing/"
+
+// objectOwner returns the user whose data key seals key. Keys are {recipes,backups}/{userID}/...;
+// failed-parsing captures belong to no user and use envelope.SystemUserID, as diagnostics does.
+func objectOwner(key string) (string, error) {
+	if strings.HasPrefix(key, failedParsingPrefix) {
+		return envelope.SystemUserID, nil
+	}
+	parts := strings.SplitN(key, "/", 3)
+	if len(parts) < 3 || parts[1] == "" {
+		return "", fmt.Errorf("not a per-user object")
+	}
+	return parts[1], nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"recipe-archive/db"
+	"recipe-archive/models"
+)
+
+// recipe-migrate upgrades stored recipes to models.CurrentSchemaVersion in place.
+//
+//	recipe-migrate -bucket my-bucket -report      count objects per schema version, change nothing
+//	recipe-migrate -dir ./data/recipes -dry-run   list the objects that would be upgraded
+//	recipe-migrate -bucket my-bucket              upgrade every outdated object
+//
+// Objects newer than this binary understands are reported and never touched.
+func main() {
+	var bucketName = flag.String("bucket", "", "S3 bucket holding recipes/{userID}/{id}.json objects")
+	var dir = flag.String("dir", "", "Local directory in the {userID}/{id}.json layout (LocalDB)")
+	var userID = flag.String("user-id", "", "Only migrate this user's recipes")
+	var dryRun = flag.Bool("dry-run", false, "List objects that would be upgraded without writing them")
+	var report = flag.Bool("report", false, "Only report how many objects are at each schema version")
+	flag.Parse()
+
+	if (*bucketName == "") == (*dir == "") {
+		fmt.Println("Exactly one of -bucket or -dir is required")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+
+	var store objectStore
+	if *bucketName != "" {
+		cfg, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			log.Fatalf("Failed to load AWS config: %v", err)
+		}
+		// Objects are decoded and re-encoded like the recipes Lambda does
+		codec, err := db.ObjectCodecFromEnv(cfg)
+		if err != nil {
+			log.Fatalf("Failed to configure recipe encoding: %v", err)
+		}
+		store = &s3Store{client: s3.NewFromConfig(cfg), bucketName: *bucketName, codec: codec}
+	} else {
+		store = &dirStore{root: *dir}
+	}
+
+	result, err := migrate(ctx, store, *userID, !*dryRun && !*report, *dryRun)
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+	result.print()
+
+	if len(result.TooNew) > 0 || len(result.Failed) > 0 {
+		os.Exit(2)
+	}
+}
+
+// migrationResult summarizes one run
+type migrationResult struct {
+	Scanned   int
+	ByVersion map[int]int
+	Upgraded  int
+	TooNew    []string
+	Failed    []string
+}
+
+func (r *migrationResult) print() {
+	fmt.Printf("📊 Scanned %d recipe objects (current schema version %d)\n", r.Scanned, models.CurrentSchemaVersion)
+	versions := make([]int, 0, len(r.ByVersion))
+	for version := range r.ByVersion {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	for _, version := range versions {
+		fmt.Printf("   version %d: %d\n", version, r.ByVersion[version])
+	}
+	fmt.Printf("✅ Upgraded %d objects\n", r.Upgraded)
+	for _, key := range r.TooNew {
+		fmt.Printf("⛔ Newer than supported, left untouched: %s\n", key)
+	}
+	for _, failure := range r.Failed {
+		fmt.Printf("❌ %s\n", failure)
+	}
+}
+
+// migrate walks every recipe object (including archived versions) and upgrades the
+// outdated ones. With write unset nothing is changed; verbose lists each candidate.
+func migrate(ctx context.Context, store objectStore, userID string, write, verbose bool) (*migrationResult, error) {
+	keys, err := store.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &migrationResult{ByVersion: map[int]int{}}
+	for _, key := range keys {
+		// Reserved "_" objects (the summary index) and temp files are not recipes
+		if name := path.Base(key); strings.HasPrefix(name, "_") || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		result.Scanned++
+
+		data, etag, err := store.Read(ctx, key)
+		if err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+
+		var doc map[string]interface{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s: not a JSON object: %v", key, err))
+			continue
+		}
+		version := models.DocumentSchemaVersion(doc)
+		result.ByVersion[version]++
+		if version > models.CurrentSchemaVersion {
+			result.TooNew = append(result.TooNew, key)
+			continue
+		}
+		if version == models.CurrentSchemaVersion {
+			continue
+		}
+
+		// Decode through the model and re-encode so the stored shape is exactly what Recipe writes
+		recipe, err := models.DecodeStoredRecipe(data)
+		if err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+		upgraded, err := json.Marshal(recipe)
+		if err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+
+		if verbose {
+			fmt.Printf("🔄 %s: version %d -> %d\n", key, version, models.CurrentSchemaVersion)
+		}
+		if !write {
+			continue
+		}
+		if err := store.Write(ctx, key, upgraded, etag); err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+		result.Upgraded++
+	}
+	return result, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"recipe-archive/models"
+)
+
+func TestMigrateDirectory(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"u1/legacy.json":            `{"id":"legacy","userId":"u1","title":"Soup","prepTime":"15"}`,
+		"u1/current.json":           `{"id":"current","userId":"u1","title":"Stew","schemaVersion":1}`,
+		"u1/future.json":            `{"id":"future","userId":"u1","title":"Later","schemaVersion":99}`,
+		"u1/_index.json":            `{"entries":{}}`,
+		"u1/legacy/versions/1.json": `{"id":"legacy","user_id":"u1","title":"Old soup"}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store := &dirStore{root: root}
+
+	// Report mode changes nothing
+	result, err := migrate(context.Background(), store, "", false, false)
+	if err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	if result.Scanned != 4 || result.ByVersion[0] != 2 || result.Upgraded != 0 || len(result.TooNew) != 1 {
+		t.Fatalf("report result: %+v", result)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "u1/legacy.json")); string(data) != files["u1/legacy.json"] {
+		t.Fatalf("report mode rewrote a file: %s", data)
+	}
+
+	result, err = migrate(context.Background(), store, "u1", true, false)
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if result.Upgraded != 2 || len(result.Failed) != 0 {
+		t.Fatalf("migrate result: %+v", result)
+	}
+
+	for _, name := range []string{"u1/legacy.json", "u1/legacy/versions/1.json"} {
+		data, _ := os.ReadFile(filepath.Join(root, name))
+		var doc map[string]interface{}
+		if err := json.Unmarshal(data, &doc); err != nil || models.DocumentSchemaVersion(doc) != models.CurrentSchemaVersion || doc["userId"] != "u1" {
+			t.Errorf("%s not upgraded: %s", name, data)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "u1/future.json")); string(data) != files["u1/future.json"] {
+		t.Errorf("newer object was modified: %s", data)
+	}
+}
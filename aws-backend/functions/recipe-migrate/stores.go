@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"recipe-archive/db"
+)
+
+// objectStore is where stored recipe objects live
+type objectStore interface {
+	// List returns the key of every object under the recipes root, or one user's objects
+	List(ctx context.Context, userID string) ([]string, error)
+	// Read returns an object and an opaque token identifying this revision of it
+	Read(ctx context.Context, key string) ([]byte, string, error)
+	// Write replaces an object, failing if it changed since the revision was read
+	Write(ctx context.Context, key string, data []byte, revision string) error
+}
+
+// s3Store reads recipes/{userID}/... objects from a bucket. Objects are decoded on read
+// and written back with the configured compression and encryption.
+type s3Store struct {
+	client     *s3.Client
+	bucketName string
+	codec      db.ObjectCodec
+}
+
+func (s *s3Store) List(ctx context.Context, userID string) ([]string, error) {
+	prefix := "recipes/"
+	if userID != "" {
+		prefix += userID + "/"
+	}
+
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucketName),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.ToString(obj.Key))
+		}
+	}
+	return keys, nil
+}
+
+func (s *s3Store) Read(ctx context.Context, key string) ([]byte, string, error) {
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get object: %w", err)
+	}
+	defer result.Body.Close()
+
+	data, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read object: %w", err)
+	}
+	data, err = s.codec.Decode(ctx, userIDFromKey(key), key, data)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, aws.ToString(result.ETag), nil
+}
+
+// Write is an If-Match conditional put, so an edit made during the migration is never lost
+func (s *s3Store) Write(ctx context.Context, key string, data []byte, etag string) error {
+	obj, err := s.codec.Encode(ctx, userIDFromKey(key), key, data)
+	if err != nil {
+		return err
+	}
+
+	input := obj.PutObjectInput(s.bucketName, key)
+	input.IfMatch = aws.String(etag)
+	if _, err := s.client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to put object: %w", err)
+	}
+	return nil
+}
+
+// userIDFromKey returns the {userID} of a recipes/{userID}/... key
+func userIDFromKey(key string) string {
+	parts := strings.SplitN(key, "/", 3)
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[1]
+}
+
+// dirStore reads {root}/{userID}/... files, the LocalDB layout
+type dirStore struct {
+	root string
+}
+
+func (d *dirStore) List(ctx context.Context, userID string) ([]string, error) {
+	root := d.root
+	if userID != "" {
+		root = filepath.Join(d.root, userID)
+	}
+
+	var keys []string
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			keys = append(keys, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", root, err)
+	}
+	return keys, nil
+}
+
+func (d *dirStore) Read(ctx context.Context, key string) ([]byte, string, error) {
+	data, err := os.ReadFile(key)
+	if err != nil {
+		return nil, "", err
+	}
+	info, err := os.Stat(key)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, info.ModTime().String(), nil
+}
+
+// Write checks the modification time it read and replaces the file via a temp file and rename
+func (d *dirStore) Write(ctx context.Context, key string, data []byte, modTime string) error {
+	info, err := os.Stat(key)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().String() != modTime {
+		return fmt.Errorf("file changed during migration, skipped")
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(key), ".tmp-migrate-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	return os.Rename(tmp.Name(), key)
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+
+	"recipe-archive/db"
+	"recipe-archive/models"
+	"recipe-archive/utils"
+)
+
+// Batch endpoint for the apps' bulk import and cleanup:
+//   POST /v1/recipes/batch   {"operations": [{"op": "create", "recipe": {...}},
+//                                            {"op": "update", "id": "...", "version": 3, "recipe": {...}},
+//                                            {"op": "delete", "id": "..."}]}
+// Operations run in order and succeed or fail individually. The library is scanned once for
+// duplicate source URLs and the summary index is rewritten once for the whole batch.
+
+// maxBatchOperations caps a batch so it finishes well within the Lambda timeout
+const maxBatchOperations = 50
+
+// batchRun holds the state shared by the operations of one batch request
+type batchRun struct {
+	userID    string
+	requestID string
+	now       time.Time
+
+	// Existing recipes by canonical source URL, loaded on the first create
+	bySourceURL map[string]*models.RecipeSummary
+	// active counts recipes outside the trash, for the recipe quota
+	active int
+}
+
+// handleBatchRecipes applies a list of create, update and delete operations
+func handleBatchRecipes(ctx context.Context, request events.APIGatewayProxyRequest, userID string) (events.APIGatewayProxyResponse, error) {
+	var batch models.BatchRecipeRequest
+	if err := json.Unmarshal([]byte(request.Body), &batch); err != nil {
+		return errorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+	}
+	if len(batch.Operations) == 0 {
+		return errorResponse(http.StatusBadRequest, "VALIDATION_ERROR", "At least one operation is required")
+	}
+	if len(batch.Operations) > maxBatchOperations {
+		return errorResponse(http.StatusBadRequest, "VALIDATION_ERROR",
+			fmt.Sprintf("A batch may contain at most %d operations", maxBatchOperations))
+	}
+
+	run := &batchRun{
+		userID:    userID,
+		requestID: utils.GetRequestID(request),
+		now:       time.Now().UTC(),
+	}
+
+	// Index changes are collected and written once after the last operation
+	writeCtx, flushIndex := db.DeferIndexUpdates(ctx)
+	response := models.BatchRecipeResponse{Results: make([]models.BatchOperationResult, 0, len(batch.Operations))}
+	var queued []string
+	for i, op := range batch.Operations {
+		result := run.apply(writeCtx, op)
+		result.Index = i
+		result.Op = op.Op
+		if result.Error != nil {
+			response.Failed++
+		} else {
+			response.Succeeded++
+			if op.Op != "delete" {
+				queued = append(queued, result.ID)
+			}
+		}
+		response.Results = append(response.Results, result)
+	}
+	flushIndex(ctx)
+
+	// Queue async normalization jobs (don't fail the batch if queueing fails)
+	for _, recipeID := range queued {
+		if err := queueRecipeNormalization(ctx, userID, recipeID); err != nil {
+			fmt.Printf("⚠️ Failed to queue normalization for recipe %s: %v\n", recipeID, err)
+		}
+	}
+	fmt.Printf("📦 Batch for user %s: %d succeeded, %d failed\n", userID, response.Succeeded, response.Failed)
+
+	apiResponse, responseErr := utils.NewAPIResponse(http.StatusOK, response)
+	if responseErr != nil {
+		return events.APIGatewayProxyResponse{}, responseErr
+	}
+	return apiResponse, nil
+}
+
+// apply runs one operation and reports its outcome
+func (run *batchRun) apply(ctx context.Context, op models.BatchOperation) models.BatchOperationResult {
+	switch op.Op {
+	case "create":
+		return run.create(ctx, op)
+	case "update":
+		return run.update(ctx, op)
+	case "delete":
+		return run.delete(ctx, op)
+	}
+	return run.failure(op.ID, utils.NewAppError(http.StatusBadRequest, "INVALID_OPERATION",
+		fmt.Sprintf("Unknown operation %q; expected create, update or delete", op.Op)))
+}
+
+// create stores a new recipe, or overwrites the existing one saved from the same source URL
+// exactly like a single POST /recipes
+func (run *batchRun) create(ctx context.Context, op models.BatchOperation) models.BatchOperationResult {
+	if op.Recipe == nil {
+		return run.failure("", utils.NewAppError(http.StatusBadRequest, "INVALID_REQUEST", "recipe is required for create"))
+	}
+	if failed, ok := run.validate("", op.Recipe); !ok {
+		return failed
+	}
+
+	if run.bySourceURL == nil {
+		summaries, err := listSummaries(ctx, run.userID)
+		if err != nil {
+			return run.failure("", utils.NewInternalError("Failed to check existing recipes", err))
+		}
+		run.active = countActive(summaries)
+		run.bySourceURL = make(map[string]*models.RecipeSummary, len(summaries))
+		for i := range summaries {
+			canonical := models.CanonicalSourceURL(summaries[i].SourceURL)
+			if current, ok := run.bySourceURL[canonical]; !ok || (current.IsDeleted && !summaries[i].IsDeleted) {
+				run.bySourceURL[canonical] = &summaries[i]
+			}
+		}
+	}
+
+	canonical := models.CanonicalSourceURL(op.Recipe.SourceURL)
+	if existing, ok := run.bySourceURL[canonical]; ok && canonical != "" {
+		stored, err := recipeDB.GetRecipe(ctx, run.userID, existing.ID)
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			return run.failure(existing.ID, utils.NewInternalError("Failed to load existing recipe", err))
+		}
+		if err == nil {
+			return run.overwrite(ctx, stored, op.Recipe)
+		}
+		// Removed since it was listed; create it afresh
+	}
+
+	if err := quotas.CheckRecipes(run.active, 1); err != nil {
+		return run.failure("", utils.AsAppError(err))
+	}
+	recipe := run.recipeFromRequest(uuid.New().String(), op.Recipe, run.now, 1)
+	if err := recipeDB.CreateRecipe(ctx, &recipe); err != nil {
+		return run.failure("", utils.NewInternalError("Failed to create recipe", err))
+	}
+	run.active++
+	return run.success(&recipe, http.StatusCreated)
+}
+
+// overwrite replaces the stored recipe saved from the same source URL, keeping its server
+// fields. Overwriting a trashed recipe brings it back, so it counts against the quota.
+func (run *batchRun) overwrite(ctx context.Context, stored *models.Recipe, data *models.CreateRecipeRequest) models.BatchOperationResult {
+	if stored.IsDeleted {
+		if err := quotas.CheckRecipes(run.active, 1); err != nil {
+			return run.failure(stored.ID, utils.AsAppError(err))
+		}
+	}
+
+	recipe := run.recipeFromRequest(stored.ID, data, stored.CreatedAt, stored.Version+1)
+	recipe.PreserveServerFields(stored)
+	result := run.write(ctx, &recipe, stored.Version, http.StatusOK)
+	if stored.IsDeleted && result.Error == nil {
+		run.active++
+	}
+	return result
+}
+
+// update replaces an existing recipe like PUT /recipes/{id}
+func (run *batchRun) update(ctx context.Context, op models.BatchOperation) models.BatchOperationResult {
+	if op.ID == "" {
+		return run.failure("", utils.NewAppError(http.StatusBadRequest, "INVALID_REQUEST", "id is required for update"))
+	}
+	if !models.ValidID(op.ID) {
+		return run.failure(op.ID, utils.NewAppError(http.StatusBadRequest, "INVALID_ID", "id is not a valid recipe ID"))
+	}
+	if op.Recipe == nil {
+		return run.failure(op.ID, utils.NewAppError(http.StatusBadRequest, "INVALID_REQUEST", "recipe is required for update"))
+	}
+	existing, failed, ok := run.getActive(ctx, op.ID)
+	if !ok {
+		return failed
+	}
+	if failed, ok := run.validate(op.ID, op.Recipe); !ok {
+		return failed
+	}
+
+	expectedVersion := existing.Version
+	if op.Version != nil {
+		expectedVersion = *op.Version
+	}
+	recipe := run.recipeFromRequest(op.ID, op.Recipe, existing.CreatedAt, expectedVersion+1)
+	recipe.PreserveServerFields(existing)
+	return run.write(ctx, &recipe, expectedVersion, http.StatusOK)
+}
+
+// delete moves a recipe to the trash like DELETE /recipes/{id}
+func (run *batchRun) delete(ctx context.Context, op models.BatchOperation) models.BatchOperationResult {
+	if op.ID == "" {
+		return run.failure("", utils.NewAppError(http.StatusBadRequest, "INVALID_REQUEST", "id is required for delete"))
+	}
+	if !models.ValidID(op.ID) {
+		return run.failure(op.ID, utils.NewAppError(http.StatusBadRequest, "INVALID_ID", "id is not a valid recipe ID"))
+	}
+	existing, failed, ok := run.getActive(ctx, op.ID)
+	if !ok {
+		return failed
+	}
+
+	expectedVersion := existing.Version
+	if op.Version != nil {
+		expectedVersion = *op.Version
+	}
+	deletedAt := run.now
+	trashed := *existing
+	trashed.IsDeleted = true
+	trashed.DeletedAt = &deletedAt
+	trashed.UpdatedAt = run.now
+	trashed.Version = expectedVersion + 1
+	result := run.write(ctx, &trashed, expectedVersion, http.StatusOK)
+	if result.Error == nil && run.bySourceURL != nil {
+		// Frees a slot for later creates; before the first create the count is not loaded yet
+		run.active--
+	}
+	return result
+}
+
+// getActive loads a recipe that is not in the trash
+func (run *batchRun) getActive(ctx context.Context, recipeID string) (*models.Recipe, models.BatchOperationResult, bool) {
+	recipe, err := recipeDB.GetRecipe(ctx, run.userID, recipeID)
+	if errors.Is(err, db.ErrNotFound) || (err == nil && recipe.IsDeleted) {
+		return nil, run.failure(recipeID, utils.NewAppError(http.StatusNotFound, "RECIPE_NOT_FOUND", "Recipe not found")), false
+	}
+	if err != nil {
+		return nil, run.failure(recipeID, utils.NewInternalError("Failed to retrieve recipe", err)), false
+	}
+	return recipe, models.BatchOperationResult{}, true
+}
+
+// write stores recipe unless it changed since expectedVersion
+func (run *batchRun) write(ctx context.Context, recipe *models.Recipe, expectedVersion, status int) models.BatchOperationResult {
+	err := recipeDB.UpdateRecipeIfVersion(ctx, recipe, expectedVersion)
+	var conflict *db.VersionConflictError
+	if errors.As(err, &conflict) {
+		return run.failure(recipe.ID, versionConflictError(conflict.Current))
+	}
+	if err != nil {
+		return run.failure(recipe.ID, utils.NewInternalError("Failed to store recipe", err))
+	}
+	return run.success(recipe, status)
+}
+
+// validate applies the same checks as the single-recipe endpoints
+func (run *batchRun) validate(recipeID string, data *models.CreateRecipeRequest) (models.BatchOperationResult, bool) {
+	violations := utils.ValidateCreateRecipe(data)
+	if len(violations) == 0 {
+		return models.BatchOperationResult{}, true
+	}
+	return run.failure(recipeID, utils.NewValidationError(violations)), false
+}
+
+// recipeFromRequest builds the stored recipe for a create or update; normalization happens asynchronously
+func (run *batchRun) recipeFromRequest(recipeID string, data *models.CreateRecipeRequest, createdAt time.Time, version int) models.Recipe {
+	return models.Recipe{
+		ID:               recipeID,
+		UserID:           run.userID,
+		Title:            strings.TrimSpace(data.Title),
+		Ingredients:      data.Ingredients,
+		Instructions:     data.Instructions,
+		SourceURL:        strings.TrimSpace(data.SourceURL),
+		MainPhotoURL:     data.MainPhotoURL,
+		PrepTimeMinutes:  data.PrepTimeMinutes,
+		CookTimeMinutes:  data.CookTimeMinutes,
+		TotalTimeMinutes: data.TotalTimeMinutes,
+		Servings:         data.Servings,
+		Yield:            data.Yield,
+		Categories:       data.Categories,
+		Description:      data.Description,
+		Reviews:          data.Reviews,
+		Nutrition:        data.Nutrition,
+		CreatedAt:        createdAt,
+		UpdatedAt:        run.now,
+		IsDeleted:        false,
+		Version:          version,
+	}
+}
+
+// success records a written recipe so later creates in the batch see it
+func (run *batchRun) success(recipe *models.Recipe, status int) models.BatchOperationResult {
+	summary := recipe.Summary()
+	if run.bySourceURL != nil {
+		if canonical := models.CanonicalSourceURL(recipe.SourceURL); canonical != "" {
+			run.bySourceURL[canonical] = &summary
+		}
+	}
+	return models.BatchOperationResult{ID: recipe.ID, Status: status, Recipe: &summary}
+}
+
+func (run *batchRun) failure(recipeID string, appErr *utils.AppError) models.BatchOperationResult {
+	appErr.LogCause(run.requestID)
+	apiErr := appErr.APIError(run.requestID)
+	return models.BatchOperationResult{ID: recipeID, Status: appErr.Status, Error: &apiErr}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+
+	"recipe-archive/db"
+	"recipe-archive/localdb"
+	"recipe-archive/models"
+	"recipe-archive/quota"
+	"recipe-archive/utils"
+)
+
+// countingS3 is an in-memory db.S3API without paging or conditional writes that counts puts
+// per key and fails puts to the keys in failPuts
+type countingS3 struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	puts     map[string]int
+	failPuts map[string]bool
+}
+
+func newCountingS3() *countingS3 {
+	return &countingS3{objects: map[string][]byte{}, puts: map[string]int{}, failPuts: map[string]bool{}}
+}
+
+func (m *countingS3) GetObject(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[aws.ToString(in.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data)), ETag: aws.String(`"1"`)}, nil
+}
+
+func (m *countingS3) HeadObject(ctx context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.objects[aws.ToString(in.Key)]; !ok {
+		return nil, &smithy.GenericAPIError{Code: "NotFound", Message: "Not Found"}
+	}
+	return &s3.HeadObjectOutput{ETag: aws.String(`"1"`)}, nil
+}
+
+func (m *countingS3) PutObject(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := aws.ToString(in.Key)
+	if m.failPuts[key] {
+		return nil, &smithy.GenericAPIError{Code: "InternalError", Message: "We encountered an internal error"}
+	}
+	m.objects[key] = data
+	m.puts[key]++
+	return &s3.PutObjectOutput{ETag: aws.String(`"1"`)}, nil
+}
+
+func (m *countingS3) DeleteObject(ctx context.Context, in *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, aws.ToString(in.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func (m *countingS3) ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(false)}
+	for key, data := range m.objects {
+		if strings.HasPrefix(key, aws.ToString(in.Prefix)) {
+			out.Contents = append(out.Contents, types.Object{Key: aws.String(key), Size: aws.Int64(int64(len(data))), ETag: aws.String(`"1"`)})
+		}
+	}
+	return out, nil
+}
+
+// batchRecipe is a valid recipe request saved from the given source URL
+func batchRecipe(title, sourceURL string) *models.CreateRecipeRequest {
+	return &models.CreateRecipeRequest{
+		Title:        title,
+		Ingredients:  []models.Ingredient{{Text: "salt"}},
+		Instructions: []models.Instruction{{StepNumber: 1, Text: "Season"}},
+		SourceURL:    sourceURL,
+	}
+}
+
+func TestBatchRecipeQuotaCountsDeletes(t *testing.T) {
+	savedDB, savedQuotas := recipeDB, quotas
+	defer func() { recipeDB, quotas = savedDB, savedQuotas }()
+	recipeDB = localdb.NewLocalDB(t.TempDir())
+	quotas = quota.Limits{MaxRecipes: 2}
+
+	ctx := context.Background()
+	for _, id := range []string{"r1", "r2"} {
+		recipe := &models.Recipe{ID: id, UserID: "u1", Title: id, SourceURL: "https://example.com/" + id, Version: 1}
+		if err := recipeDB.CreateRecipe(ctx, recipe); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+
+	create := func(slug string) models.BatchOperation {
+		return models.BatchOperation{Op: "create", Recipe: &models.CreateRecipeRequest{
+			Title:        slug,
+			Ingredients:  []models.Ingredient{{Text: "salt"}},
+			Instructions: []models.Instruction{{StepNumber: 1, Text: "Season"}},
+			SourceURL:    "https://example.com/" + slug,
+		}}
+	}
+	// At the limit: the first create fails, the delete frees a slot for the second, and the
+	// third fails again
+	body, _ := json.Marshal(models.BatchRecipeRequest{Operations: []models.BatchOperation{
+		create("new1"),
+		{Op: "delete", ID: "r1"},
+		create("new2"),
+		create("new3"),
+	}})
+	response, err := handleBatchRecipes(ctx, events.APIGatewayProxyRequest{Body: string(body)}, "u1")
+	if err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+	var result models.BatchRecipeResponse
+	if err := json.Unmarshal([]byte(response.Body), &result); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	want := []int{http.StatusForbidden, http.StatusOK, http.StatusCreated, http.StatusForbidden}
+	if len(result.Results) != len(want) {
+		t.Fatalf("results: got %d want %d", len(result.Results), len(want))
+	}
+	for i, status := range want {
+		if got := result.Results[i]; got.Status != status {
+			t.Errorf("operation %d (%s): got status %d want %d (%+v)", i, got.Op, got.Status, status, got.Error)
+		}
+	}
+}
+
+func TestBatchRejectsUnsafeIDs(t *testing.T) {
+	savedDB := recipeDB
+	defer func() { recipeDB = savedDB }()
+	recipeDB = localdb.NewLocalDB(t.TempDir())
+
+	ctx := context.Background()
+	victim := &models.Recipe{ID: "r1", UserID: "victim", Title: "Soup", Version: 1}
+	if err := recipeDB.CreateRecipe(ctx, victim); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	body, _ := json.Marshal(models.BatchRecipeRequest{Operations: []models.BatchOperation{
+		{Op: "update", ID: "../victim/r1", Recipe: &models.CreateRecipeRequest{
+			Title:        "Pwned",
+			Ingredients:  []models.Ingredient{{Text: "salt"}},
+			Instructions: []models.Instruction{{StepNumber: 1, Text: "Season"}},
+		}},
+		{Op: "delete", ID: "../victim/r1"},
+	}})
+	response, err := handleBatchRecipes(ctx, events.APIGatewayProxyRequest{Body: string(body)}, "attacker")
+	if err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+	var result models.BatchRecipeResponse
+	if err := json.Unmarshal([]byte(response.Body), &result); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	for _, got := range result.Results {
+		if got.Status != http.StatusBadRequest || got.Error == nil || got.Error.Code != "INVALID_ID" {
+			t.Errorf("operation %d (%s): got %d %+v, want 400 INVALID_ID", got.Index, got.Op, got.Status, got.Error)
+		}
+	}
+	if stored, err := recipeDB.GetRecipe(ctx, "victim", "r1"); err != nil || stored.Title != "Soup" || stored.IsDeleted {
+		t.Errorf("victim's recipe = %+v, %v", stored, err)
+	}
+}
+
+func TestBatchMixedOperations(t *testing.T) {
+	savedDB, savedQuotas := recipeDB, quotas
+	defer func() { recipeDB, quotas = savedDB, savedQuotas }()
+	store := newCountingS3()
+	recipeDB = db.NewS3RecipeDB(store, "bucket")
+	quotas = quota.Limits{}
+
+	ctx := context.Background()
+	for _, id := range []string{"r1", "r2", "r3", "r4"} {
+		recipe := &models.Recipe{ID: id, UserID: "u1", Title: id, SourceURL: "https://example.com/" + id, Version: 1}
+		if err := recipeDB.CreateRecipe(ctx, recipe); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+	store.failPuts["recipes/u1/r4.json"] = true
+	indexKey := "recipes/u1/_index.json"
+	indexPuts := store.puts[indexKey]
+
+	stale := 7
+	body, _ := json.Marshal(models.BatchRecipeRequest{Operations: []models.BatchOperation{
+		{Op: "create", Recipe: batchRecipe("Bread", "https://example.com/bread")},
+		{Op: "create", Recipe: batchRecipe("Soup again", "https://example.com/r1")},
+		{Op: "update", ID: "r2", Recipe: batchRecipe("Stew", "https://example.com/r2")},
+		{Op: "delete", ID: "r3"},
+		{Op: "update", ID: "r2", Version: &stale, Recipe: batchRecipe("Stew", "https://example.com/r2")},
+		{Op: "update", ID: "missing", Recipe: batchRecipe("Stew", "https://example.com/missing")},
+		{Op: "create", Recipe: &models.CreateRecipeRequest{Title: "No ingredients"}},
+		{Op: "delete", ID: "r4"},
+		{Op: "rename", ID: "r2"},
+	}})
+	response, err := handleBatchRecipes(ctx, events.APIGatewayProxyRequest{Body: string(body)}, "u1")
+	if err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+	var result models.BatchRecipeResponse
+	if err := json.Unmarshal([]byte(response.Body), &result); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	want := []struct {
+		status int
+		code   string
+	}{
+		{http.StatusCreated, ""},
+		{http.StatusOK, ""}, // overwrites r1, saved from the same source URL
+		{http.StatusOK, ""},
+		{http.StatusOK, ""},
+		{http.StatusConflict, "VERSION_CONFLICT"},
+		{http.StatusNotFound, "RECIPE_NOT_FOUND"},
+		{http.StatusBadRequest, "VALIDATION_ERROR"},
+		{http.StatusInternalServerError, "INTERNAL_ERROR"},
+		{http.StatusBadRequest, "INVALID_OPERATION"},
+	}
+	if len(result.Results) != len(want) {
+		t.Fatalf("results: got %d want %d", len(result.Results), len(want))
+	}
+	for i, w := range want {
+		got := result.Results[i]
+		code := ""
+		if got.Error != nil {
+			code = got.Error.Code
+		}
+		if got.Index != i || got.Status != w.status || code != w.code {
+			t.Errorf("operation %d (%s): got #%d %d %q, want %d %q", i, got.Op, got.Index, got.Status, code, w.status, w.code)
+		}
+	}
+	if result.Succeeded != 4 || result.Failed != 5 {
+		t.Errorf("summary: %d succeeded, %d failed, want 4 and 5", result.Succeeded, result.Failed)
+	}
+	if result.Results[1].ID != "r1" {
+		t.Errorf("overwrite wrote %s, want r1", result.Results[1].ID)
+	}
+	if got := store.puts[indexKey] - indexPuts; got != 1 {
+		t.Errorf("index written %d times, want once for the batch", got)
+	}
+	if stored, err := recipeDB.GetRecipe(ctx, "u1", "r3"); err != nil || !stored.IsDeleted {
+		t.Errorf("r3 = %+v, %v, want it in the trash", stored, err)
+	}
+}
+
+func TestBatchOperationLimit(t *testing.T) {
+	operations := make([]models.BatchOperation, maxBatchOperations+1)
+	for i := range operations {
+		operations[i] = models.BatchOperation{Op: "delete", ID: "r1"}
+	}
+	body, _ := json.Marshal(models.BatchRecipeRequest{Operations: operations})
+	_, err := handleBatchRecipes(context.Background(), events.APIGatewayProxyRequest{Body: string(body)}, "u1")
+	var appErr *utils.AppError
+	if !errors.As(err, &appErr) || appErr.Status != http.StatusBadRequest || appErr.Code != "VALIDATION_ERROR" {
+		t.Errorf("batch of %d operations: got %v, want 400 VALIDATION_ERROR", len(operations), err)
+	}
+}
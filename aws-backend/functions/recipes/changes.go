@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"recipe-archive/db"
+	"recipe-archive/models"
+	"recipe-archive/utils"
+)
+
+// Change feed for incremental sync (backends implementing db.ChangeLog):
+//   GET /v1/recipes/changes?since={cursor}&limit={n}
+// Clients start without since, apply the returned changes in order, store the cursor and
+// poll with it; hasMore means another page is ready right away.
+
+const (
+	defaultChangesLimit = 200
+	maxChangesLimit     = 1000
+)
+
+// handleListChanges returns the user's recipe changes after the since cursor
+func handleListChanges(ctx context.Context, request events.APIGatewayProxyRequest, userID string) (events.APIGatewayProxyResponse, error) {
+	changeLog, ok := db.Unwrap(recipeDB).(db.ChangeLog)
+	if !ok {
+		return errorResponse(http.StatusNotImplemented, "CHANGE_FEED_UNAVAILABLE", "The change feed is not supported by this storage backend")
+	}
+
+	limit := defaultChangesLimit
+	if val := request.QueryStringParameters["limit"]; val != "" {
+		parsed, err := strconv.Atoi(val)
+		if err != nil || parsed < 1 {
+			return errorResponse(http.StatusBadRequest, "INVALID_REQUEST", "limit must be a positive integer")
+		}
+		limit = parsed
+		if limit > maxChangesLimit {
+			limit = maxChangesLimit
+		}
+	}
+
+	changes, cursor, hasMore, err := changeLog.ListChanges(ctx, userID, request.QueryStringParameters["since"], limit)
+	if errors.Is(err, db.ErrInvalidCursor) {
+		return errorResponse(http.StatusBadRequest, "INVALID_CURSOR", "since is not a cursor returned by this endpoint")
+	}
+	if err != nil {
+		return internalError(err, "Failed to retrieve changes")
+	}
+
+	response, responseErr := utils.NewAPIResponse(http.StatusOK, models.RecipeChangesResponse{
+		Changes: changes,
+		Cursor:  cursor,
+		HasMore: hasMore,
+	})
+	if responseErr != nil {
+		return events.APIGatewayProxyResponse{}, responseErr
+	}
+	return response, nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"recipe-archive/db"
+	"recipe-archive/models"
+	"recipe-archive/utils"
+)
+
+// Version history endpoints (backends implementing db.RecipeHistory):
+//   GET  /v1/recipes/{id}/versions                     list archived versions
+//   GET  /v1/recipes/{id}/versions/{version}           fetch one version
+//   GET  /v1/recipes/{id}/diff?from={n}&to={m}         field-level diff (to defaults to current)
+//   POST /v1/recipes/{id}/versions/{version}/restore   restore a version as a new version
+
+// handleListRecipeVersions lists the archived versions of a recipe
+func handleListRecipeVersions(ctx context.Context, history db.RecipeHistory, userID, recipeID string) (events.APIGatewayProxyResponse, error) {
+	current, err := getActiveRecipe(ctx, userID, recipeID)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	versions, err := history.ListRecipeVersions(ctx, userID, recipeID)
+	if err != nil {
+		return internalError(err, "Failed to list recipe versions")
+	}
+
+	response, responseErr := utils.NewAPIResponse(http.StatusOK, models.RecipeVersionsResponse{
+		RecipeID:       recipeID,
+		CurrentVersion: current.Version,
+		Versions:       versions,
+	})
+	if responseErr != nil {
+		return events.APIGatewayProxyResponse{}, responseErr
+	}
+	return response, nil
+}
+
+// handleGetRecipeVersion returns one version of a recipe (the current version included)
+func handleGetRecipeVersion(ctx context.Context, history db.RecipeHistory, userID, recipeID, versionParam string) (events.APIGatewayProxyResponse, error) {
+	version, err := strconv.Atoi(versionParam)
+	if err != nil || version < 0 {
+		return errorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Version must be a non-negative integer")
+	}
+
+	current, err := getActiveRecipe(ctx, userID, recipeID)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	recipe, err := loadRecipeVersion(ctx, history, current, version)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	response, responseErr := utils.NewAPIResponse(http.StatusOK, map[string]interface{}{
+		"recipe": recipe,
+	})
+	if responseErr != nil {
+		return events.APIGatewayProxyResponse{}, responseErr
+	}
+	return response, nil
+}
+
+// handleDiffRecipeVersions returns the fields that changed between two versions of a recipe
+func handleDiffRecipeVersions(ctx context.Context, history db.RecipeHistory, userID, recipeID string, queryParams map[string]string) (events.APIGatewayProxyResponse, error) {
+	current, err := getActiveRecipe(ctx, userID, recipeID)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	fromVersion, err := strconv.Atoi(queryParams["from"])
+	if err != nil || fromVersion < 0 {
+		return errorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Query parameter 'from' must be a non-negative integer")
+	}
+	toVersion := current.Version
+	if val := queryParams["to"]; val != "" {
+		toVersion, err = strconv.Atoi(val)
+		if err != nil || toVersion < 0 {
+			return errorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Query parameter 'to' must be a non-negative integer")
+		}
+	}
+
+	from, err := loadRecipeVersion(ctx, history, current, fromVersion)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	to, err := loadRecipeVersion(ctx, history, current, toVersion)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	changes, err := models.DiffRecipes(from, to)
+	if err != nil {
+		return internalError(err, "Failed to diff recipe versions")
+	}
+
+	response, responseErr := utils.NewAPIResponse(http.StatusOK, models.RecipeDiffResponse{
+		RecipeID:    recipeID,
+		FromVersion: fromVersion,
+		ToVersion:   toVersion,
+		Changes:     changes,
+	})
+	if responseErr != nil {
+		return events.APIGatewayProxyResponse{}, responseErr
+	}
+	return response, nil
+}
+
+// handleRestoreRecipeVersion writes the content of an old version back as a new version.
+// The current copy is archived by UpdateRecipeIfVersion, so a restore can itself be undone.
+func handleRestoreRecipeVersion(ctx context.Context, history db.RecipeHistory, userID, recipeID, versionParam string) (events.APIGatewayProxyResponse, error) {
+	version, err := strconv.Atoi(versionParam)
+	if err != nil || version < 0 {
+		return errorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Version must be a non-negative integer")
+	}
+
+	current, err := getActiveRecipe(ctx, userID, recipeID)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	old, err := loadRecipeVersion(ctx, history, current, version)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	restored := *old
+	restored.ID = current.ID
+	restored.UserID = current.UserID
+	restored.CreatedAt = current.CreatedAt // Preserve original creation
+	restored.UpdatedAt = time.Now().UTC()
+	restored.IsDeleted = false
+	restored.DeletedAt = nil
+	restored.Version = current.Version + 1
+
+	err = recipeDB.UpdateRecipeIfVersion(ctx, &restored, current.Version)
+	var conflict *db.VersionConflictError
+	if errors.As(err, &conflict) {
+		return versionConflictResponse(conflict.Current)
+	}
+	if err != nil {
+		return internalError(err, "Failed to restore recipe version")
+	}
+
+	fmt.Printf("⏪ Restored recipe %s from version %d as version %d\n", recipeID, version, restored.Version)
+	response, responseErr := utils.NewAPIResponse(http.StatusOK, map[string]interface{}{
+		"recipe":  restored,
+		"message": fmt.Sprintf("Recipe restored from version %d", version),
+	})
+	if responseErr != nil {
+		return events.APIGatewayProxyResponse{}, responseErr
+	}
+	return response, nil
+}
+
+// getActiveRecipe loads the current copy of a recipe, failing with 404 for missing or
+// trashed recipes
+func getActiveRecipe(ctx context.Context, userID, recipeID string) (*models.Recipe, error) {
+	recipe, err := recipeDB.GetRecipe(ctx, userID, recipeID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, utils.NewAppError(http.StatusNotFound, "RECIPE_NOT_FOUND", "Recipe not found")
+		}
+		return nil, utils.NewInternalError("Failed to retrieve recipe", err)
+	}
+	if recipe.IsDeleted {
+		return nil, utils.NewAppError(http.StatusNotFound, "RECIPE_NOT_FOUND", "Recipe not found")
+	}
+	return recipe, nil
+}
+
+// loadRecipeVersion returns current itself for its own version and the archived copy otherwise
+func loadRecipeVersion(ctx context.Context, history db.RecipeHistory, current *models.Recipe, version int) (*models.Recipe, error) {
+	if version == current.Version {
+		return current, nil
+	}
+
+	recipe, err := history.GetRecipeVersion(ctx, current.UserID, current.ID, version)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, utils.NewAppError(http.StatusNotFound, "VERSION_NOT_FOUND", fmt.Sprintf("Version %d of this recipe was not found", version))
+		}
+		return nil, utils.NewInternalError("Failed to retrieve recipe version", err)
+	}
+	return recipe, nil
+}
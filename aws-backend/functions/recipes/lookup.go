@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"recipe-archive/models"
+	"recipe-archive/utils"
+)
+
+// Duplicate lookup. The browser extension calls this before capturing a page so it can
+// show "already saved" without sending the page content:
+//   GET /v1/recipes/lookup?url={sourceUrl}
+
+// LookupResponse represents the API response for a source URL lookup. Recipe is set
+// only when Found is true; recipes in the trash are not reported.
+type LookupResponse struct {
+	Found        bool                  `json:"found"`
+	CanonicalURL string                `json:"canonicalUrl"`
+	Recipe       *models.RecipeSummary `json:"recipe,omitempty"`
+}
+
+// handleLookupRecipe reports whether the user already saved a recipe from the given URL
+func handleLookupRecipe(ctx context.Context, request events.APIGatewayProxyRequest, userID string) (events.APIGatewayProxyResponse, error) {
+	sourceURL := strings.TrimSpace(request.QueryStringParameters["url"])
+	if sourceURL == "" {
+		return errorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Query parameter url is required")
+	}
+
+	existing, err := findRecipeBySourceURL(ctx, userID, sourceURL)
+	if err != nil {
+		return internalError(err, "Failed to check existing recipes")
+	}
+
+	lookup := LookupResponse{CanonicalURL: models.CanonicalSourceURL(sourceURL)}
+	if existing != nil && !existing.IsDeleted {
+		lookup.Found = true
+		lookup.Recipe = existing
+	}
+
+	response, responseErr := utils.NewAPIResponse(http.StatusOK, lookup)
+	if responseErr != nil {
+		return events.APIGatewayProxyResponse{}, responseErr
+	}
+	return response, nil
+}
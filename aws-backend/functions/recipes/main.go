@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -15,16 +16,25 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/google/uuid"
 
 	"recipe-archive/db"
+	"recipe-archive/localdb"
 	"recipe-archive/models"
+	"recipe-archive/quota"
+	"recipe-archive/ratelimit"
+	"recipe-archive/sqlitedb"
 	"recipe-archive/utils"
 )
 
 var recipeDB db.RecipeDB
+var recipeCache *db.CachingRecipeDB
+var tokenStore *db.AccessTokenStore
+var limiter *ratelimit.Limiter
+var quotas quota.Limits
 var sqsClient *sqs.Client
 
 // NormalizationMessage represents an SQS message for async recipe normalization
@@ -48,10 +58,71 @@ func init() {
 		bucketName = "recipe-archive-dev" // fallback for local testing
 	}
 
-	// Initialize S3-based storage (following architecture decision)
-	s3Client := s3.NewFromConfig(cfg)
 	sqsClient = sqs.NewFromConfig(cfg)
-	recipeDB = db.NewS3RecipeDB(s3Client, bucketName)
+	s3Client := s3.NewFromConfig(cfg)
+	// Personal access tokens live in the bucket whichever backend stores recipes
+	tokenStore = db.NewAccessTokenStore(s3Client, bucketName)
+	limiter = ratelimit.New(ratelimit.StoreFromEnv(cfg))
+	quotas = quota.FromEnv()
+
+	// Select the storage backend; S3 is the production default (following architecture decision)
+	switch backend := os.Getenv("RECIPE_STORAGE_BACKEND"); backend {
+	case "", "s3":
+		s3DB := db.NewS3RecipeDB(s3Client, bucketName)
+		// Optional compression and client-side encryption of stored objects
+		codec, err := db.ObjectCodecFromEnv(cfg)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to configure recipe storage encoding: %v", err))
+		}
+		s3DB.SetCodec(codec)
+		recipeDB = s3DB
+	case "dynamodb":
+		tableName := os.Getenv("RECIPES_TABLE_NAME")
+		if tableName == "" {
+			tableName = "recipe-archive-recipes"
+		}
+		recipeDB = db.NewDynamoRecipeDB(dynamodb.NewFromConfig(cfg), tableName)
+	case "local":
+		dataDir := os.Getenv("LOCAL_DATA_DIR")
+		if dataDir == "" {
+			dataDir = "./data/recipes"
+		}
+		fmt.Printf("📁 Using local recipe storage in %s\n", dataDir)
+		recipeDB = localdb.NewLocalDB(dataDir)
+	case "sqlite":
+		dbPath := os.Getenv("SQLITE_PATH")
+		if dbPath == "" {
+			dbPath = "./data/recipes.db"
+		}
+		sqliteDB, err := sqlitedb.Open(context.Background(), dbPath)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to open SQLite database %s: %v", dbPath, err))
+		}
+		fmt.Printf("🗄️ Using SQLite recipe storage in %s\n", dbPath)
+		recipeDB = sqliteDB
+	default:
+		panic(fmt.Sprintf("Unknown RECIPE_STORAGE_BACKEND: %s", backend))
+	}
+
+	// Keep recently read recipes in memory across invocations of a warm container
+	cacheSize := db.DefaultCacheSize
+	if val := os.Getenv("RECIPE_CACHE_SIZE"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			cacheSize = parsed
+		}
+	}
+	cacheTTL := db.DefaultCacheTTL
+	if val := os.Getenv("RECIPE_CACHE_TTL_SECONDS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			cacheTTL = time.Duration(parsed) * time.Second
+		}
+	}
+	if cacheSize > 0 {
+		recipeCache = db.NewCachingRecipeDB(recipeDB, cacheSize, cacheTTL)
+		recipeDB = recipeCache
+	}
+
+	recipeRouter = newRecipeRouter()
 }
 
 // queueRecipeNormalization sends a message to SQS to normalize a recipe in the background
@@ -87,10 +158,17 @@ func queueRecipeNormalization(ctx context.Context, userID, recipeID string) erro
 }
 
 func main() {
-	lambda.Start(handler)
+	lambda.Start(utils.WithErrorResponses(handler))
 }
 
 func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Stop storage calls shortly before the Lambda deadline
+	ctx, cancel := utils.WithDeadlineMargin(ctx, utils.LambdaDeadlineMargin)
+	defer cancel()
+	if recipeCache != nil {
+		defer logCacheStats()
+	}
+
 	// Handle CORS preflight requests
 	if request.HTTPMethod == "OPTIONS" {
 		response, err := utils.NewAPIResponse(http.StatusOK, map[string]string{"message": "CORS preflight"})
@@ -100,126 +178,144 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		return response, nil
 	}
 
-	// Extract user ID from JWT claims
-	userID := getUserIDFromRequest(request)
-	if userID == "" {
-		response, err := utils.NewAPIResponse(http.StatusUnauthorized, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "UNAUTHORIZED",
-				"message":   "Invalid or missing authentication token",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if err != nil {
-			return events.APIGatewayProxyResponse{}, err
-		}
-		return response, nil
+	return recipeRouter.Serve(ctx, request)
+}
+
+// logCacheStats reports the running recipe cache counters for this container
+func logCacheStats() {
+	stats := recipeCache.Stats()
+	fmt.Printf("📦 Recipe cache: %d hits, %d misses, %d revalidated, %d evicted\n",
+		stats.Hits, stats.Misses, stats.Revalidations, stats.Evictions)
+}
+
+// errorResponse fails the request with an error that handler reports in the standard
+// {"error": {...}} envelope
+func errorResponse(statusCode int, code, message string) (events.APIGatewayProxyResponse, error) {
+	return events.APIGatewayProxyResponse{}, utils.NewAppError(statusCode, code, message)
+}
+
+// internalError fails the request with a 500; cause is logged with the request ID, message
+// is shown to the client
+func internalError(cause error, message string) (events.APIGatewayProxyResponse, error) {
+	return events.APIGatewayProxyResponse{}, utils.NewInternalError(message, cause)
+}
+
+// checkRecipeQuota fails if adding recipes would take the user past the recipe quota
+func checkRecipeQuota(ctx context.Context, userID string, adding int) error {
+	if quotas.MaxRecipes == 0 {
+		return nil
+	}
+	summaries, err := listSummaries(ctx, userID)
+	if err != nil {
+		return utils.NewInternalError("Failed to check recipe quota", err)
 	}
+	return quotas.CheckRecipes(countActive(summaries), adding)
+}
 
-	// Route based on HTTP method and path
-	switch request.HTTPMethod {
-	case "GET":
-		// Check if this is a search request
-		if strings.Contains(request.Path, "/search") {
-			return handleSearchRecipes(ctx, request, userID)
-		}
-		return handleGetRecipes(ctx, request, userID)
-	case "POST":
-		return handleCreateRecipe(ctx, request, userID)
-	case "PUT":
-		return handleUpdateRecipe(ctx, request, userID)
-	case "DELETE":
-		return handleDeleteRecipe(ctx, request, userID)
-	default:
-		response, err := utils.NewAPIResponse(http.StatusMethodNotAllowed, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "METHOD_NOT_ALLOWED",
-				"message":   fmt.Sprintf("Method %s not allowed", request.HTTPMethod),
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if err != nil {
-			return events.APIGatewayProxyResponse{}, err
+// countActive counts the recipes that are not in the trash
+func countActive(summaries []models.RecipeSummary) int {
+	active := 0
+	for _, summary := range summaries {
+		if !summary.IsDeleted {
+			active++
 		}
-		return response, nil
 	}
+	return active
 }
 
-// getUserIDFromRequest extracts user ID from the request with proper JWT validation
-func getUserIDFromRequest(request events.APIGatewayProxyRequest) string {
-	// Use the JWT validation from utils
-	claims, err := utils.ExtractUserFromJWT(request)
-	if err != nil {
-		// Log the error but don't expose details to client
-		fmt.Printf("JWT validation failed: %v\n", err)
-		return ""
+// listSummaries loads the recipe index for a user, logging (but tolerating) recipes that failed to load
+func listSummaries(ctx context.Context, userID string) ([]models.RecipeSummary, error) {
+	summaries, err := recipeDB.ListRecipeSummaries(ctx, userID)
+	var partial *db.PartialListError
+	if errors.As(err, &partial) {
+		fmt.Printf("⚠️ Partial recipe index for user %s: %v\n", userID, partial)
+		return summaries, nil
 	}
+	return summaries, err
+}
 
-	if claims.Sub == "" {
-		fmt.Printf("JWT missing subject claim\n")
-		return ""
+// recipeFromSummary expands an index summary into a Recipe carrying only the indexed fields,
+// which is enough for filtering and sorting before the page's full bodies are loaded
+func recipeFromSummary(userID string, summary models.RecipeSummary) models.Recipe {
+	return models.Recipe{
+		ID:               summary.ID,
+		UserID:           userID,
+		Title:            summary.Title,
+		SourceURL:        summary.SourceURL,
+		MainPhotoURL:     summary.MainPhotoURL,
+		PrepTimeMinutes:  summary.PrepTimeMinutes,
+		CookTimeMinutes:  summary.CookTimeMinutes,
+		TotalTimeMinutes: summary.TotalTimeMinutes,
+		Servings:         summary.Servings,
+		SearchMetadata:   summary.SearchMetadata,
+		CreatedAt:        summary.CreatedAt,
+		UpdatedAt:        summary.UpdatedAt,
+		IsDeleted:        summary.IsDeleted,
+		DeletedAt:        summary.DeletedAt,
+		Version:          summary.Version,
 	}
-
-	return claims.Sub
 }
 
-// handleGetRecipes handles GET requests for recipes (list or single)
-func handleGetRecipes(ctx context.Context, request events.APIGatewayProxyRequest, userID string) (events.APIGatewayProxyResponse, error) {
-	// Check if this is a request for a specific recipe
-	if recipeID, exists := request.PathParameters["id"]; exists {
-		return handleGetRecipeByID(ctx, userID, recipeID)
+// hydrateRecipes replaces index-derived recipes with their full stored bodies, reusing any
+// bodies already in loaded. Recipes that vanished since the index was read are dropped.
+func hydrateRecipes(ctx context.Context, userID string, page []models.Recipe, loaded map[string]models.Recipe) []models.Recipe {
+	var missing []string
+	for _, recipe := range page {
+		if _, ok := loaded[recipe.ID]; !ok {
+			missing = append(missing, recipe.ID)
+		}
+	}
+
+	if len(missing) > 0 {
+		fetched, err := db.GetRecipes(ctx, recipeDB, userID, missing, db.DefaultListConcurrency)
+		if err != nil {
+			fmt.Printf("⚠️ Failed to load some recipes for user %s: %v\n", userID, err)
+		}
+		for _, recipe := range fetched {
+			loaded[recipe.ID] = recipe
+		}
 	}
 
-	// Otherwise, list recipes
-	return handleListRecipes(ctx, userID, request.QueryStringParameters)
+	recipes := make([]models.Recipe, 0, len(page))
+	for _, recipe := range page {
+		if full, ok := loaded[recipe.ID]; ok {
+			recipes = append(recipes, full)
+		}
+	}
+	return recipes
+}
+
+// summarizeRecipes projects recipes back to their index summaries (view=summary responses)
+func summarizeRecipes(recipes []models.Recipe) []models.RecipeSummary {
+	summaries := make([]models.RecipeSummary, 0, len(recipes))
+	for i := range recipes {
+		summaries = append(summaries, recipes[i].Summary())
+	}
+	return summaries
 }
 
-// handleGetRecipeByID handles GET requests for a specific recipe
-func handleGetRecipeByID(ctx context.Context, userID, recipeID string) (events.APIGatewayProxyResponse, error) {
+// handleGetRecipeByID handles GET requests for a specific recipe, answering 304 when the
+// client's If-None-Match or If-Modified-Since shows its copy is current
+func handleGetRecipeByID(ctx context.Context, request events.APIGatewayProxyRequest, userID, recipeID string) (events.APIGatewayProxyResponse, error) {
 	// Get the recipe from S3
-	recipe, err := recipeDB.GetRecipe(userID, recipeID)
+	recipe, err := recipeDB.GetRecipe(ctx, userID, recipeID)
 	if err != nil {
 		// Check if it's a "not found" error (common S3 error patterns)
-		if strings.Contains(err.Error(), "NoSuchKey") || strings.Contains(err.Error(), "not found") {
-			response, responseErr := utils.NewAPIResponse(http.StatusNotFound, map[string]interface{}{
-				"error": map[string]interface{}{
-					"code":      "RECIPE_NOT_FOUND",
-					"message":   "Recipe not found",
-					"timestamp": time.Now().UTC(),
-				},
-			})
-			if responseErr != nil {
-				return events.APIGatewayProxyResponse{}, responseErr
-			}
-			return response, nil
+		if errors.Is(err, db.ErrNotFound) {
+			return errorResponse(http.StatusNotFound, "RECIPE_NOT_FOUND", "Recipe not found")
 		}
 
-		response, responseErr := utils.NewAPIResponse(http.StatusInternalServerError, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "INTERNAL_ERROR",
-				"message":   "Failed to retrieve recipe",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+		return internalError(err, "Failed to retrieve recipe")
 	}
 
 	// Check if recipe is soft deleted
 	if recipe.IsDeleted {
-		response, responseErr := utils.NewAPIResponse(http.StatusNotFound, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "RECIPE_NOT_FOUND",
-				"message":   "Recipe not found",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+		return errorResponse(http.StatusNotFound, "RECIPE_NOT_FOUND", "Recipe not found")
+	}
+
+	etag := utils.RecipeETag(recipe)
+	if utils.NotModified(request, etag, recipe.UpdatedAt) {
+		return utils.NewNotModifiedResponse(etag, recipe.UpdatedAt)
 	}
 
 	response, responseErr := utils.NewAPIResponse(http.StatusOK, map[string]interface{}{
@@ -228,11 +324,13 @@ func handleGetRecipeByID(ctx context.Context, userID, recipeID string) (events.A
 	if responseErr != nil {
 		return events.APIGatewayProxyResponse{}, responseErr
 	}
-	return response, nil
+	return utils.WithValidators(response, etag, recipe.UpdatedAt), nil
 }
 
-// handleListRecipes handles GET requests to list recipes with pagination
-func handleListRecipes(ctx context.Context, userID string, queryParams map[string]string) (events.APIGatewayProxyResponse, error) {
+// handleListRecipes handles GET requests to list recipes with pagination. The ETag covers
+// the query and the summary of every recipe, so an unchanged library answers 304.
+func handleListRecipes(ctx context.Context, request events.APIGatewayProxyRequest, userID string) (events.APIGatewayProxyResponse, error) {
+	queryParams := request.QueryStringParameters
 	// Parse pagination parameters
 	limit := 50 // default limit per API specification (was 20, causing Flutter app to show only 20 recipes)
 	if limitStr, exists := queryParams["limit"]; exists {
@@ -241,27 +339,49 @@ func handleListRecipes(ctx context.Context, userID string, queryParams map[strin
 		}
 	}
 
-	// Get all recipes for user from S3
-	allRecipes, err := recipeDB.ListRecipes(userID)
-	if err != nil {
-		response, responseErr := utils.NewAPIResponse(http.StatusInternalServerError, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "INTERNAL_ERROR",
-				"message":   "Failed to retrieve recipes",
-				"timestamp": time.Now().UTC(),
-			},
-		})
+	// Backends with native pagination (DynamoDB) page server-side; the cursor is theirs
+	if pager, ok := db.Unwrap(recipeDB).(db.RecipePager); ok && queryParams["view"] != "summary" {
+		recipes, pagination, err := pager.ListRecipesPage(ctx, userID, limit, queryParams["cursor"])
+		if errors.Is(err, db.ErrInvalidCursor) {
+			return errorResponse(http.StatusBadRequest, "INVALID_CURSOR", "cursor is not a cursor returned by this endpoint")
+		}
+		if err != nil {
+			return internalError(err, "Failed to retrieve recipes")
+		}
+
+		// The page is already loaded, so a match only saves the transfer
+		pageSummaries := summarizeRecipes(recipes)
+		etag := utils.ListETag(fmt.Sprintf("page limit=%d cursor=%s", limit, queryParams["cursor"]), pageSummaries)
+		lastModified := utils.LastModified(pageSummaries)
+		if utils.NotModified(request, etag, lastModified) {
+			return utils.NewNotModifiedResponse(etag, lastModified)
+		}
+
+		apiResponse, responseErr := utils.NewAPIResponse(http.StatusOK, models.RecipesListResponse{Recipes: recipes, Pagination: pagination})
 		if responseErr != nil {
 			return events.APIGatewayProxyResponse{}, responseErr
 		}
-		return response, nil
+		return utils.WithValidators(apiResponse, etag, lastModified), nil
+	}
+
+	// Read the per-user summary index (one GET) instead of every recipe body
+	summaries, err := listSummaries(ctx, userID)
+	if err != nil {
+		return internalError(err, "Failed to retrieve recipes")
+	}
+
+	// Trashed recipes count too: moving one to the trash changes the list
+	etag := utils.ListETag(fmt.Sprintf("index limit=%d cursor=%s view=%s", limit, queryParams["cursor"], queryParams["view"]), summaries)
+	lastModified := utils.LastModified(summaries)
+	if utils.NotModified(request, etag, lastModified) {
+		return utils.NewNotModifiedResponse(etag, lastModified)
 	}
 
 	// Filter out soft-deleted recipes
 	var activeRecipes []models.Recipe
-	for _, recipe := range allRecipes {
-		if !recipe.IsDeleted {
-			activeRecipes = append(activeRecipes, recipe)
+	for _, summary := range summaries {
+		if !summary.IsDeleted {
+			activeRecipes = append(activeRecipes, recipeFromSummary(userID, summary))
 		}
 	}
 
@@ -295,45 +415,40 @@ func handleListRecipes(ctx context.Context, userID string, queryParams map[strin
 		recipes = []models.Recipe{}
 	}
 
-	response := models.RecipesListResponse{
-		Recipes: recipes,
-		Pagination: models.Pagination{
-			NextCursor: nextCursor,
-			HasMore:    hasMore,
-			Total:      &total,
-		},
+	pagination := models.Pagination{
+		NextCursor: nextCursor,
+		HasMore:    hasMore,
+		Total:      &total,
+	}
+
+	var body interface{}
+	if queryParams["view"] == "summary" {
+		body = models.RecipeSummariesListResponse{Recipes: summarizeRecipes(recipes), Pagination: pagination}
+	} else {
+		// Only the requested page is downloaded in full
+		body = models.RecipesListResponse{Recipes: hydrateRecipes(ctx, userID, recipes, map[string]models.Recipe{}), Pagination: pagination}
 	}
 
-	apiResponse, responseErr := utils.NewAPIResponse(http.StatusOK, response)
+	apiResponse, responseErr := utils.NewAPIResponse(http.StatusOK, body)
 	if responseErr != nil {
 		return events.APIGatewayProxyResponse{}, responseErr
 	}
-	return apiResponse, nil
+	return utils.WithValidators(apiResponse, etag, lastModified), nil
 }
 
 // handleSearchRecipes handles GET requests to search recipes with cost-efficient in-Lambda filtering
 func handleSearchRecipes(ctx context.Context, request events.APIGatewayProxyRequest, userID string) (events.APIGatewayProxyResponse, error) {
-	// Get all recipes for user from S3 (cost-efficient: no external search service needed)
-	allRecipes, err := recipeDB.ListRecipes(userID)
+	// Structured filters run against the summary index (cost-efficient: no external search service needed)
+	summaries, err := listSummaries(ctx, userID)
 	if err != nil {
-		response, responseErr := utils.NewAPIResponse(http.StatusInternalServerError, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "INTERNAL_ERROR",
-				"message":   "Failed to retrieve recipes for search",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+		return internalError(err, "Failed to retrieve recipes for search")
 	}
 
 	// Filter out soft-deleted recipes
 	var activeRecipes []models.Recipe
-	for _, recipe := range allRecipes {
-		if !recipe.IsDeleted {
-			activeRecipes = append(activeRecipes, recipe)
+	for _, summary := range summaries {
+		if !summary.IsDeleted {
+			activeRecipes = append(activeRecipes, recipeFromSummary(userID, summary))
 		}
 	}
 
@@ -404,13 +519,61 @@ func handleSearchRecipes(ctx context.Context, request events.APIGatewayProxyRequ
 	// Source URL filtering
 	sourceFilter := strings.ToLower(strings.TrimSpace(queryParams["source"]))
 
-	// Apply cost-efficient in-memory filtering
-	var matchingRecipes []models.Recipe
+	// Apply cost-efficient in-memory filtering on the indexed fields first
+	var candidates []models.Recipe
 	for _, recipe := range activeRecipes {
-		if matchesSearchCriteria(recipe, searchQuery, minPrepTime, maxPrepTime, minCookTime, maxCookTime,
+		if matchesSearchCriteria(recipe, "", minPrepTime, maxPrepTime, minCookTime, maxCookTime,
 			minServings, maxServings, minTotalTime, maxTotalTime, semanticTags, primaryIngredients, cookingMethods, dietaryTags,
 			flavorProfile, equipment, timeCategory, complexity, mealType, sourceFilter) {
-			matchingRecipes = append(matchingRecipes, recipe)
+			candidates = append(candidates, recipe)
+		}
+	}
+
+	// The text query also covers ingredients and instructions, which are not indexed:
+	// only candidates whose title doesn't already match are downloaded to check them
+	loaded := map[string]models.Recipe{}
+	matchingRecipes := candidates
+	searcher, hasTextIndex := db.Unwrap(recipeDB).(db.TextSearcher)
+	if searchQuery != "" && hasTextIndex {
+		// Backends with a full-text index answer the text query without downloading bodies
+		matchingRecipes = nil
+		matchedIDs, err := searcher.SearchRecipeIDs(ctx, userID, searchQuery)
+		if err != nil {
+			return internalError(err, "Failed to search recipes")
+		}
+		matched := make(map[string]bool, len(matchedIDs))
+		for _, id := range matchedIDs {
+			matched[id] = true
+		}
+		for _, recipe := range candidates {
+			if matched[recipe.ID] {
+				matchingRecipes = append(matchingRecipes, recipe)
+			}
+		}
+	} else if searchQuery != "" {
+		matchingRecipes = nil
+		var needBody []string
+		for _, recipe := range candidates {
+			if !strings.Contains(strings.ToLower(recipe.Title), searchQuery) {
+				needBody = append(needBody, recipe.ID)
+			}
+		}
+		if len(needBody) > 0 {
+			fetched, err := db.GetRecipes(ctx, recipeDB, userID, needBody, db.DefaultListConcurrency)
+			if err != nil {
+				fmt.Printf("⚠️ Failed to load some recipes for search: %v\n", err)
+			}
+			for _, recipe := range fetched {
+				loaded[recipe.ID] = recipe
+			}
+		}
+		for _, recipe := range candidates {
+			if strings.Contains(strings.ToLower(recipe.Title), searchQuery) {
+				matchingRecipes = append(matchingRecipes, recipe)
+			} else if full, ok := loaded[recipe.ID]; ok && matchesSearchCriteria(full, searchQuery, nil, nil, nil, nil,
+				nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, "", "", "", "") {
+				matchingRecipes = append(matchingRecipes, recipe)
+			}
 		}
 	}
 
@@ -456,16 +619,20 @@ func handleSearchRecipes(ctx context.Context, request events.APIGatewayProxyRequ
 	}
 
 	// Build search response
-	response := models.RecipesListResponse{
-		Recipes: paginatedRecipes,
-		Pagination: models.Pagination{
-			NextCursor: nextCursor,
-			HasMore:    hasMore,
-			Total:      &total,
-		},
+	pagination := models.Pagination{
+		NextCursor: nextCursor,
+		HasMore:    hasMore,
+		Total:      &total,
+	}
+
+	var body interface{}
+	if queryParams["view"] == "summary" {
+		body = models.RecipeSummariesListResponse{Recipes: summarizeRecipes(paginatedRecipes), Pagination: pagination}
+	} else {
+		body = models.RecipesListResponse{Recipes: hydrateRecipes(ctx, userID, paginatedRecipes, loaded), Pagination: pagination}
 	}
 
-	apiResponse, responseErr := utils.NewAPIResponse(http.StatusOK, response)
+	apiResponse, responseErr := utils.NewAPIResponse(http.StatusOK, body)
 	if responseErr != nil {
 		return events.APIGatewayProxyResponse{}, responseErr
 	}
@@ -476,96 +643,47 @@ func handleSearchRecipes(ctx context.Context, request events.APIGatewayProxyRequ
 func handleCreateRecipe(ctx context.Context, request events.APIGatewayProxyRequest, userID string) (events.APIGatewayProxyResponse, error) {
 	var recipeData models.CreateRecipeRequest
 	if err := json.Unmarshal([]byte(request.Body), &recipeData); err != nil {
-		response, responseErr := utils.NewAPIResponse(http.StatusBadRequest, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "INVALID_REQUEST",
-				"message":   "Invalid request body",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+		return errorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
 	}
 
-	// Validate required fields
-	if strings.TrimSpace(recipeData.Title) == "" {
-		response, responseErr := utils.NewAPIResponse(http.StatusBadRequest, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "VALIDATION_ERROR",
-				"message":   "Title is required",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
-	}
-
-	if len(recipeData.Ingredients) == 0 {
-		response, responseErr := utils.NewAPIResponse(http.StatusBadRequest, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "VALIDATION_ERROR",
-				"message":   "At least one ingredient is required",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
-	}
-
-	if len(recipeData.Instructions) == 0 {
-		response, responseErr := utils.NewAPIResponse(http.StatusBadRequest, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "VALIDATION_ERROR",
-				"message":   "At least one instruction is required",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+	// Validate the request, reporting every invalid field at once
+	if violations := utils.ValidateCreateRecipe(&recipeData); len(violations) > 0 {
+		return events.APIGatewayProxyResponse{}, utils.NewValidationError(violations)
 	}
 
-	// Check for existing recipe with same source URL (de-duplication)
-	existingRecipes, err := recipeDB.ListRecipes(userID)
+	// Check if recipe with same source URL already exists (implement overwrite behavior)
+	sourceURL := strings.TrimSpace(recipeData.SourceURL)
+	existingRecipe, err := findRecipeBySourceURL(ctx, userID, sourceURL)
 	if err != nil {
-		response, responseErr := utils.NewAPIResponse(http.StatusInternalServerError, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "INTERNAL_ERROR",
-				"message":   "Failed to check existing recipes",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+		return internalError(err, "Failed to check existing recipes")
 	}
 
-	// Check if recipe with same source URL already exists (implement overwrite behavior)
-	sourceURL := strings.TrimSpace(recipeData.SourceURL)
-	var existingRecipe *models.Recipe
-	for _, existing := range existingRecipes {
-		if existing.SourceURL == sourceURL {
-			existingRecipe = &existing
-			break
+	// Load the stored copy of a match, so the overwrite keeps its server-maintained fields
+	var storedRecipe *models.Recipe
+	if existingRecipe != nil {
+		storedRecipe, err = recipeDB.GetRecipe(ctx, userID, existingRecipe.ID)
+		if errors.Is(err, db.ErrNotFound) {
+			storedRecipe = nil // removed since the index was read; create it afresh
+		} else if err != nil {
+			return internalError(err, "Failed to load existing recipe")
 		}
 	}
 
-	if existingRecipe != nil {
+	if storedRecipe != nil {
 		// Recipe with same URL exists - overwrite it with new data
 		fmt.Printf("Recipe with URL %s already exists, overwriting with new data", sourceURL)
 
+		// Overwriting a trashed recipe brings it back, so it counts against the quota again
+		if storedRecipe.IsDeleted {
+			if err := checkRecipeQuota(ctx, userID, 1); err != nil {
+				return events.APIGatewayProxyResponse{}, err
+			}
+		}
+
 		// Store recipe immediately with raw data - normalization will happen asynchronously
 		now := time.Now().UTC()
 		updatedRecipe := models.Recipe{
-			ID:               existingRecipe.ID,                   // Keep same ID
+			ID:               storedRecipe.ID,                     // Keep same ID
 			UserID:           userID,                              // Current user
 			Title:            strings.TrimSpace(recipeData.Title), // Raw title (will be normalized async)
 			Ingredients:      recipeData.Ingredients,              // Raw ingredients
@@ -579,26 +697,24 @@ func handleCreateRecipe(ctx context.Context, request events.APIGatewayProxyReque
 			Categories:       recipeData.Categories,
 			MainPhotoURL:     recipeData.MainPhotoURL,
 			Description:      recipeData.Description,
-			CreatedAt:        existingRecipe.CreatedAt,   // Preserve original creation
-			UpdatedAt:        now,                        // Current timestamp
-			IsDeleted:        false,                      // Ensure not deleted
-			Version:          existingRecipe.Version + 1, // Increment version
+			Reviews:          recipeData.Reviews,
+			Nutrition:        recipeData.Nutrition,
+			CreatedAt:        storedRecipe.CreatedAt,   // Preserve original creation
+			UpdatedAt:        now,                      // Current timestamp
+			IsDeleted:        false,                    // Ensure not deleted
+			Version:          storedRecipe.Version + 1, // Increment version
+		}
+		// Keep the fields clients don't send: search metadata, the web archive link, creation time
+		updatedRecipe.PreserveServerFields(storedRecipe)
+
+		// Update the recipe in storage, unless it changed since it was read
+		err = recipeDB.UpdateRecipeIfVersion(ctx, &updatedRecipe, storedRecipe.Version)
+		var conflict *db.VersionConflictError
+		if errors.As(err, &conflict) {
+			return versionConflictResponse(conflict.Current)
 		}
-
-		// Update the recipe in storage
-		err = recipeDB.UpdateRecipe(&updatedRecipe)
 		if err != nil {
-			response, responseErr := utils.NewAPIResponse(http.StatusInternalServerError, map[string]interface{}{
-				"error": map[string]interface{}{
-					"code":      "UPDATE_FAILED",
-					"message":   "Failed to update existing recipe",
-					"timestamp": time.Now().UTC(),
-				},
-			})
-			if responseErr != nil {
-				return events.APIGatewayProxyResponse{}, responseErr
-			}
-			return response, nil
+			return events.APIGatewayProxyResponse{}, utils.NewAppError(http.StatusInternalServerError, "UPDATE_FAILED", "Failed to update existing recipe").WithCause(err)
 		}
 
 		// Queue async normalization job for updated recipe (don't fail if queueing fails)
@@ -617,6 +733,10 @@ func handleCreateRecipe(ctx context.Context, request events.APIGatewayProxyReque
 		return response, nil
 	}
 
+	if err := checkRecipeQuota(ctx, userID, 1); err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
 	// Create the recipe object with raw data - normalization will happen asynchronously
 	now := time.Now().UTC()
 	recipe := models.Recipe{
@@ -643,19 +763,9 @@ func handleCreateRecipe(ctx context.Context, request events.APIGatewayProxyReque
 	}
 
 	// Save to S3
-	err = recipeDB.CreateRecipe(&recipe)
+	err = recipeDB.CreateRecipe(ctx, &recipe)
 	if err != nil {
-		response, responseErr := utils.NewAPIResponse(http.StatusInternalServerError, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "INTERNAL_ERROR",
-				"message":   "Failed to create recipe",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+		return internalError(err, "Failed to create recipe")
 	}
 
 	// Queue async normalization job (don't fail if queueing fails)
@@ -672,96 +782,81 @@ func handleCreateRecipe(ctx context.Context, request events.APIGatewayProxyReque
 	return response, nil
 }
 
+// findRecipeBySourceURL returns the summary of the user's recipe saved from this source URL
+// (compared in canonical form, see models.CanonicalSourceURL), or nil. Backends with a source
+// URL index are queried directly; otherwise the summary index is scanned.
+func findRecipeBySourceURL(ctx context.Context, userID, sourceURL string) (*models.RecipeSummary, error) {
+	if finder, ok := db.Unwrap(recipeDB).(db.SourceURLFinder); ok {
+		recipe, err := finder.FindBySourceURL(ctx, userID, sourceURL)
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		summary := recipe.Summary()
+		return &summary, nil
+	}
+
+	summaries, err := listSummaries(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	canonical := models.CanonicalSourceURL(sourceURL)
+	var match *models.RecipeSummary
+	for i := range summaries {
+		if models.CanonicalSourceURL(summaries[i].SourceURL) != canonical {
+			continue
+		}
+		// Prefer an active recipe over one in the trash
+		if match == nil || (match.IsDeleted && !summaries[i].IsDeleted) {
+			match = &summaries[i]
+		}
+	}
+	return match, nil
+}
+
 // handleUpdateRecipe handles PUT requests to update an existing recipe
 func handleUpdateRecipe(ctx context.Context, request events.APIGatewayProxyRequest, userID string) (events.APIGatewayProxyResponse, error) {
 	recipeID, exists := request.PathParameters["id"]
 	if !exists || recipeID == "" {
-		response, responseErr := utils.NewAPIResponse(http.StatusBadRequest, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "INVALID_REQUEST",
-				"message":   "Recipe ID is required",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+		return errorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Recipe ID is required")
 	}
 
 	var updateRecipe models.CreateRecipeRequest
 	if err := json.Unmarshal([]byte(request.Body), &updateRecipe); err != nil {
-		response, responseErr := utils.NewAPIResponse(http.StatusBadRequest, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "INVALID_REQUEST",
-				"message":   "Invalid request body",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+		return errorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+	}
+
+	// The version the client edited, for optimistic concurrency (defaults to the stored version)
+	var versionCheck struct {
+		Version *int `json:"version"`
 	}
+	_ = json.Unmarshal([]byte(request.Body), &versionCheck)
 
 	// Get existing recipe first to check if it exists
-	existingRecipe, err := recipeDB.GetRecipe(userID, recipeID)
+	existingRecipe, err := recipeDB.GetRecipe(ctx, userID, recipeID)
 	if err != nil {
-		if strings.Contains(err.Error(), "NoSuchKey") || strings.Contains(err.Error(), "not found") {
-			response, responseErr := utils.NewAPIResponse(http.StatusNotFound, map[string]interface{}{
-				"error": map[string]interface{}{
-					"code":      "RECIPE_NOT_FOUND",
-					"message":   "Recipe not found",
-					"timestamp": time.Now().UTC(),
-				},
-			})
-			if responseErr != nil {
-				return events.APIGatewayProxyResponse{}, responseErr
-			}
-			return response, nil
+		if errors.Is(err, db.ErrNotFound) {
+			return errorResponse(http.StatusNotFound, "RECIPE_NOT_FOUND", "Recipe not found")
 		}
 
-		response, responseErr := utils.NewAPIResponse(http.StatusInternalServerError, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "INTERNAL_ERROR",
-				"message":   "Failed to retrieve existing recipe",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+		return internalError(err, "Failed to retrieve existing recipe")
 	}
 
 	// Check if recipe is soft deleted
 	if existingRecipe.IsDeleted {
-		response, responseErr := utils.NewAPIResponse(http.StatusNotFound, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "RECIPE_NOT_FOUND",
-				"message":   "Recipe not found",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+		return errorResponse(http.StatusNotFound, "RECIPE_NOT_FOUND", "Recipe not found")
 	}
 
-	// Validate required fields for update
-	if strings.TrimSpace(updateRecipe.Title) == "" {
-		response, responseErr := utils.NewAPIResponse(http.StatusBadRequest, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "VALIDATION_ERROR",
-				"message":   "Title is required",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+	// Validate the replacement, reporting every invalid field at once
+	if violations := utils.ValidateCreateRecipe(&updateRecipe); len(violations) > 0 {
+		return events.APIGatewayProxyResponse{}, utils.NewValidationError(violations)
+	}
+
+	expectedVersion := existingRecipe.Version
+	if versionCheck.Version != nil {
+		expectedVersion = *versionCheck.Version
 	}
 
 	// Per requirement: "if a recipe exists and a user re-loads it from the web extension,
@@ -785,26 +880,22 @@ func handleUpdateRecipe(ctx context.Context, request events.APIGatewayProxyReque
 		Description:      updateRecipe.Description,
 		Reviews:          updateRecipe.Reviews,
 		Nutrition:        updateRecipe.Nutrition,
-		CreatedAt:        existingRecipe.CreatedAt,   // Preserve original creation time
-		UpdatedAt:        now,                        // Update timestamp
-		IsDeleted:        false,                      // Ensure not deleted
-		Version:          existingRecipe.Version + 1, // Increment version
+		CreatedAt:        existingRecipe.CreatedAt, // Preserve original creation time
+		UpdatedAt:        now,                      // Update timestamp
+		IsDeleted:        false,                    // Ensure not deleted
+		Version:          expectedVersion + 1,      // Increment version
 	}
+	// Keep the fields clients don't send: search metadata, the web archive link, creation time
+	updatedRecipe.PreserveServerFields(existingRecipe)
 
-	// Update the recipe in S3 (S3 overwrites by default, perfect for our use case)
-	err = recipeDB.UpdateRecipe(&updatedRecipe)
+	// Compare-and-swap: reject the write if another device or the normalizer got there first
+	err = recipeDB.UpdateRecipeIfVersion(ctx, &updatedRecipe, expectedVersion)
+	var conflict *db.VersionConflictError
+	if errors.As(err, &conflict) {
+		return versionConflictResponse(conflict.Current)
+	}
 	if err != nil {
-		response, responseErr := utils.NewAPIResponse(http.StatusInternalServerError, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "INTERNAL_ERROR",
-				"message":   "Failed to update recipe",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+		return internalError(err, "Failed to update recipe")
 	}
 
 	response, responseErr := utils.NewAPIResponse(http.StatusOK, map[string]interface{}{
@@ -816,86 +907,62 @@ func handleUpdateRecipe(ctx context.Context, request events.APIGatewayProxyReque
 	return response, nil
 }
 
-// handleDeleteRecipe handles DELETE requests to delete a recipe (soft delete)
+// versionConflictResponse builds the 409 returned when an update was based on a stale version,
+// including the current server copy so the client can merge or retry
+func versionConflictResponse(current *models.Recipe) (events.APIGatewayProxyResponse, error) {
+	return events.APIGatewayProxyResponse{}, versionConflictError(current)
+}
+
+// versionConflictError is the error behind versionConflictResponse, also reported per
+// operation by the batch endpoint
+func versionConflictError(current *models.Recipe) *utils.AppError {
+	return utils.NewAppError(http.StatusConflict, "VERSION_CONFLICT",
+		fmt.Sprintf("Recipe was modified by another client (current version %d)", current.Version)).
+		WithDetails(map[string]interface{}{"currentRecipe": current})
+}
+
+// handleDeleteRecipe handles DELETE requests by moving the recipe to the trash (soft delete)
 func handleDeleteRecipe(ctx context.Context, request events.APIGatewayProxyRequest, userID string) (events.APIGatewayProxyResponse, error) {
 	recipeID, exists := request.PathParameters["id"]
 	if !exists || recipeID == "" {
-		response, responseErr := utils.NewAPIResponse(http.StatusBadRequest, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "INVALID_REQUEST",
-				"message":   "Recipe ID is required",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+		return errorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Recipe ID is required")
 	}
 
 	// Check if recipe exists first
-	existingRecipe, err := recipeDB.GetRecipe(userID, recipeID)
+	existingRecipe, err := recipeDB.GetRecipe(ctx, userID, recipeID)
 	if err != nil {
-		if strings.Contains(err.Error(), "NoSuchKey") || strings.Contains(err.Error(), "not found") {
-			response, responseErr := utils.NewAPIResponse(http.StatusNotFound, map[string]interface{}{
-				"error": map[string]interface{}{
-					"code":      "RECIPE_NOT_FOUND",
-					"message":   "Recipe not found",
-					"timestamp": time.Now().UTC(),
-				},
-			})
-			if responseErr != nil {
-				return events.APIGatewayProxyResponse{}, responseErr
-			}
-			return response, nil
+		if errors.Is(err, db.ErrNotFound) {
+			return errorResponse(http.StatusNotFound, "RECIPE_NOT_FOUND", "Recipe not found")
 		}
 
-		response, responseErr := utils.NewAPIResponse(http.StatusInternalServerError, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "INTERNAL_ERROR",
-				"message":   "Failed to retrieve recipe",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+		return internalError(err, "Failed to retrieve recipe")
 	}
 
 	// Check if already deleted
 	if existingRecipe.IsDeleted {
-		response, responseErr := utils.NewAPIResponse(http.StatusNotFound, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "RECIPE_NOT_FOUND",
-				"message":   "Recipe not found",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+		return errorResponse(http.StatusNotFound, "RECIPE_NOT_FOUND", "Recipe not found")
 	}
 
-	// Hard delete by removing from S3 storage entirely
-	err = recipeDB.DeleteRecipe(userID, recipeID)
+	// Move to the trash; the recipe stays restorable until the retention period purges it
+	now := time.Now().UTC()
+	trashed := *existingRecipe
+	trashed.IsDeleted = true
+	trashed.DeletedAt = &now
+	trashed.UpdatedAt = now
+	trashed.Version = existingRecipe.Version + 1
+
+	err = recipeDB.UpdateRecipeIfVersion(ctx, &trashed, existingRecipe.Version)
+	var conflict *db.VersionConflictError
+	if errors.As(err, &conflict) {
+		return versionConflictResponse(conflict.Current)
+	}
 	if err != nil {
-		response, responseErr := utils.NewAPIResponse(http.StatusInternalServerError, map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":      "INTERNAL_ERROR",
-				"message":   "Failed to permanently delete recipe",
-				"timestamp": time.Now().UTC(),
-			},
-		})
-		if responseErr != nil {
-			return events.APIGatewayProxyResponse{}, responseErr
-		}
-		return response, nil
+		return internalError(err, "Failed to delete recipe")
 	}
 
 	response, responseErr := utils.NewAPIResponse(http.StatusOK, map[string]interface{}{
-		"message": "Recipe permanently deleted from storage",
+		"message":    "Recipe moved to trash",
+		"purgeAfter": now.Add(utils.TrashRetention()),
 	})
 	if responseErr != nil {
 		return events.APIGatewayProxyResponse{}, responseErr
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"recipe-archive/db"
+	"recipe-archive/localdb"
+	"recipe-archive/models"
+	"recipe-archive/utils"
+)
+
+// cursorPager pages like DynamoRecipeDB, accepting only the cursor "next"
+type cursorPager struct {
+	*localdb.LocalDB
+}
+
+func (p cursorPager) ListRecipesPage(ctx context.Context, userID string, limit int, cursor string) ([]models.Recipe, models.Pagination, error) {
+	if cursor != "" && cursor != "next" {
+		return nil, models.Pagination{}, errors.Join(db.ErrInvalidCursor, errors.New("malformed"))
+	}
+	return []models.Recipe{}, models.Pagination{}, nil
+}
+
+func TestListRecipesRejectsMalformedCursor(t *testing.T) {
+	savedDB := recipeDB
+	defer func() { recipeDB = savedDB }()
+	recipeDB = cursorPager{localdb.NewLocalDB(t.TempDir())}
+
+	for cursor, want := range map[string]int{"next": http.StatusOK, "not a cursor!": http.StatusBadRequest} {
+		request := events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"cursor": cursor}}
+		_, err := handleListRecipes(context.Background(), request, "u1")
+		var appErr *utils.AppError
+		switch {
+		case want == http.StatusOK && err != nil:
+			t.Errorf("cursor %q: %v", cursor, err)
+		case want != http.StatusOK && (!errors.As(err, &appErr) || appErr.Status != want || appErr.Code != "INVALID_CURSOR"):
+			t.Errorf("cursor %q: got %v want %d INVALID_CURSOR", cursor, err, want)
+		}
+	}
+}
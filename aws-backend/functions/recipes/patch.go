@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"recipe-archive/db"
+	"recipe-archive/models"
+	"recipe-archive/utils"
+)
+
+// Partial updates:
+//   PATCH /v1/recipes/{id}   {"title": "...", "version": 3}
+// A JSON body is an UpdateRecipeRequest and only the fields it contains change. With
+// Content-Type application/merge-patch+json the body is an RFC 7396 merge patch instead, so
+// null removes an optional field. Server-managed fields (ID, timestamps, version, web archive
+// link, search metadata) are never taken from the body.
+
+// handlePatchRecipe applies a partial update to an existing recipe
+func handlePatchRecipe(ctx context.Context, request events.APIGatewayProxyRequest, userID string) (events.APIGatewayProxyResponse, error) {
+	recipeID := request.PathParameters["id"]
+	if recipeID == "" {
+		return errorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Recipe ID is required")
+	}
+
+	// A merge patch has the same shape as an UpdateRecipeRequest, with null for removed
+	// fields, so both forms are validated the same way
+	mergePatch := isMergePatch(request)
+	var update models.UpdateRecipeRequest
+	if err := json.Unmarshal([]byte(request.Body), &update); err != nil {
+		return errorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+	}
+	if violations := utils.ValidateUpdateRecipe(&update); len(violations) > 0 {
+		return events.APIGatewayProxyResponse{}, utils.NewValidationError(violations)
+	}
+
+	// The version the client edited, for optimistic concurrency (defaults to the stored version)
+	var versionCheck struct {
+		Version *int `json:"version"`
+	}
+	_ = json.Unmarshal([]byte(request.Body), &versionCheck)
+
+	existing, err := recipeDB.GetRecipe(ctx, userID, recipeID)
+	if errors.Is(err, db.ErrNotFound) || (err == nil && existing.IsDeleted) {
+		return errorResponse(http.StatusNotFound, "RECIPE_NOT_FOUND", "Recipe not found")
+	}
+	if err != nil {
+		return internalError(err, "Failed to retrieve existing recipe")
+	}
+
+	var patched *models.Recipe
+	if mergePatch {
+		patched, err = models.ApplyMergePatch(existing, []byte(request.Body))
+		if err != nil {
+			return errorResponse(http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+		}
+	} else {
+		copied := *existing
+		copied.ApplyUpdate(update)
+		patched = &copied
+	}
+
+	// The result must be a valid recipe, reported field by field like POST and PUT
+	if violations := utils.ValidateRecipe(patched); len(violations) > 0 {
+		return events.APIGatewayProxyResponse{}, utils.NewValidationError(violations)
+	}
+
+	expectedVersion := existing.Version
+	if versionCheck.Version != nil {
+		expectedVersion = *versionCheck.Version
+	}
+	patched.PreserveServerFields(existing)
+	patched.UpdatedAt = time.Now().UTC()
+	patched.Version = expectedVersion + 1
+
+	// Compare-and-swap: reject the write if another device or the normalizer got there first
+	err = recipeDB.UpdateRecipeIfVersion(ctx, patched, expectedVersion)
+	var conflict *db.VersionConflictError
+	if errors.As(err, &conflict) {
+		return versionConflictResponse(conflict.Current)
+	}
+	if err != nil {
+		return internalError(err, "Failed to update recipe")
+	}
+
+	response, responseErr := utils.NewAPIResponse(http.StatusOK, map[string]interface{}{
+		"recipe": patched,
+	})
+	if responseErr != nil {
+		return events.APIGatewayProxyResponse{}, responseErr
+	}
+	return response, nil
+}
+
+// isMergePatch reports whether the request body is an RFC 7396 merge patch
+func isMergePatch(request events.APIGatewayProxyRequest) bool {
+	mediaType, _, err := mime.ParseMediaType(utils.GetHeader(request, "Content-Type"))
+	return err == nil && mediaType == models.MergePatchContentType
+}
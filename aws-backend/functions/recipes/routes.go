@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"recipe-archive/auth"
+	"recipe-archive/db"
+	"recipe-archive/models"
+	"recipe-archive/ratelimit"
+	"recipe-archive/router"
+	"recipe-archive/utils"
+)
+
+// The recipes API. Each endpoint is one route; capabilities a storage backend may lack are
+// checked by route middleware before the handler runs.
+
+type historyKey struct{}
+
+// recipeRouter serves every request after the CORS preflight; init builds it once the rate
+// limiter is configured
+var recipeRouter *router.Router
+
+// Rate limits per user. Every request counts against apiLimit; the costliest routes also
+// have a bucket of their own.
+var (
+	apiLimit = ratelimit.Limit{Requests: 120, Per: time.Minute}
+	// searchLimit guards search, which scans the whole library on every call
+	searchLimit = ratelimit.Limit{Requests: 20, Per: time.Minute}
+	// batchLimit guards batches of up to maxBatchOperations writes
+	batchLimit = ratelimit.Limit{Requests: 10, Per: time.Minute}
+)
+
+func newRecipeRouter() *router.Router {
+	r := router.New()
+	r.StripPrefix("/v1")
+	r.Use(requireValidIDs, requireUser, requireScope, limiter.Middleware("api", apiLimit, userIDFrom))
+
+	r.Handle("GET", "/recipes", withUser(handleListRecipes))
+	r.Handle("POST", "/recipes", withUser(handleCreateRecipe))
+	r.Handle("GET", "/recipes/search", withUser(handleSearchRecipes), limiter.Middleware("search", searchLimit, userIDFrom))
+	r.Handle("GET", "/recipes/lookup", withUser(handleLookupRecipe))
+	r.Handle("GET", "/recipes/changes", withUser(handleListChanges))
+	r.Handle("POST", "/recipes/batch", withUser(handleBatchRecipes), limiter.Middleware("batch", batchLimit, userIDFrom))
+	r.Handle("GET", "/recipes/trash", func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return handleListTrash(ctx, userIDFrom(ctx))
+	})
+
+	r.Handle("GET", "/recipes/{id}", func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return handleGetRecipeByID(ctx, request, userIDFrom(ctx), request.PathParameters["id"])
+	})
+	r.Handle("PUT", "/recipes/{id}", withUser(handleUpdateRecipe))
+	r.Handle("PATCH", "/recipes/{id}", withUser(handlePatchRecipe))
+	r.Handle("DELETE", "/recipes/{id}", withUser(handleDeleteRecipe))
+
+	// Trash actions
+	r.Handle("POST", "/recipes/{id}/restore", func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return handleRestoreFromTrash(ctx, userIDFrom(ctx), request.PathParameters["id"])
+	})
+	r.Handle("DELETE", "/recipes/{id}/permanent", func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return handlePermanentDelete(ctx, userIDFrom(ctx), request.PathParameters["id"])
+	})
+
+	// Version history
+	r.Handle("GET", "/recipes/{id}/versions", func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return handleListRecipeVersions(ctx, historyFrom(ctx), userIDFrom(ctx), request.PathParameters["id"])
+	}, requireHistory)
+	r.Handle("GET", "/recipes/{id}/versions/{version}", func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return handleGetRecipeVersion(ctx, historyFrom(ctx), userIDFrom(ctx), request.PathParameters["id"], request.PathParameters["version"])
+	}, requireHistory)
+	r.Handle("POST", "/recipes/{id}/versions/{version}/restore", func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return handleRestoreRecipeVersion(ctx, historyFrom(ctx), userIDFrom(ctx), request.PathParameters["id"], request.PathParameters["version"])
+	}, requireHistory)
+	r.Handle("GET", "/recipes/{id}/diff", func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return handleDiffRecipeVersions(ctx, historyFrom(ctx), userIDFrom(ctx), request.PathParameters["id"], request.QueryStringParameters)
+	}, requireHistory)
+
+	// Personal access tokens, managed only from a signed-in session
+	r.Handle("GET", "/tokens", withUser(handleListAccessTokens), requireSession)
+	r.Handle("POST", "/tokens", withUser(handleCreateAccessToken), requireSession)
+	r.Handle("DELETE", "/tokens/{tokenId}", func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return handleRevokeAccessToken(ctx, userIDFrom(ctx), request.PathParameters["tokenId"])
+	}, requireSession)
+
+	return r
+}
+
+// requireValidIDs rejects paths with a segment that cannot be an ID, such as "..", so no
+// {id} parameter reaches a storage key or file path
+func requireValidIDs(next utils.Handler) utils.Handler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		for _, segment := range strings.Split(request.Path, "/") {
+			if segment != "" && !models.ValidID(segment) {
+				return errorResponse(http.StatusBadRequest, "INVALID_ID", fmt.Sprintf("%q is not a valid ID", segment))
+			}
+		}
+		return next(ctx, request)
+	}
+}
+
+// requireUser rejects requests without a valid JWT or access token and stores the caller in
+// the context
+func requireUser(next utils.Handler) utils.Handler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		principal, err := auth.Authenticate(ctx, request, tokenStore)
+		if err != nil {
+			return events.APIGatewayProxyResponse{}, err
+		}
+		return next(auth.NewContext(ctx, principal), request)
+	}
+}
+
+// requireScope limits access tokens by method: reads need the read scope, anything else write
+func requireScope(next utils.Handler) utils.Handler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		scope := models.ScopeWrite
+		if request.HTTPMethod == http.MethodGet || request.HTTPMethod == http.MethodHead {
+			scope = models.ScopeRead
+		}
+		if err := auth.RequireScope(auth.FromContext(ctx), scope); err != nil {
+			return events.APIGatewayProxyResponse{}, err
+		}
+		return next(ctx, request)
+	}
+}
+
+// requireSession keeps access tokens from managing access tokens, so a leaked token cannot
+// mint more or outlive its revocation
+func requireSession(next utils.Handler) utils.Handler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		if auth.FromContext(ctx).IsAccessToken() {
+			return errorResponse(http.StatusForbidden, "SESSION_REQUIRED", "Access tokens can only be managed after signing in")
+		}
+		return next(ctx, request)
+	}
+}
+
+// requireHistory answers 501 when the storage backend keeps no version history
+func requireHistory(next utils.Handler) utils.Handler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		history, ok := db.Unwrap(recipeDB).(db.RecipeHistory)
+		if !ok {
+			return errorResponse(http.StatusNotImplemented, "VERSION_HISTORY_UNAVAILABLE", "Version history is not supported by this storage backend")
+		}
+		return next(context.WithValue(ctx, historyKey{}, history), request)
+	}
+}
+
+// withUser adapts a handler that takes the caller's user ID
+func withUser(handle func(context.Context, events.APIGatewayProxyRequest, string) (events.APIGatewayProxyResponse, error)) utils.Handler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return handle(ctx, request, userIDFrom(ctx))
+	}
+}
+
+// userIDFrom returns the ID of the caller authenticated by requireUser
+func userIDFrom(ctx context.Context) string {
+	if principal := auth.FromContext(ctx); principal != nil {
+		return principal.UserID
+	}
+	return ""
+}
+
+// historyFrom returns the version history stored by requireHistory
+func historyFrom(ctx context.Context) db.RecipeHistory {
+	history, _ := ctx.Value(historyKey{}).(db.RecipeHistory)
+	return history
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"recipe-archive/db"
+	"recipe-archive/models"
+	"recipe-archive/utils"
+)
+
+// Personal access tokens for scripts and the CLI:
+//   POST   /v1/tokens             {"name": "recipe-report", "scopes": ["read"], "expiresInDays": 30}
+//   GET    /v1/tokens
+//   DELETE /v1/tokens/{tokenId}
+// The secret is returned once, by POST. A token authenticates as its owner, limited to its
+// scopes; only a signed-in session can manage tokens.
+
+// maxAccessTokensPerUser bounds how many tokens, expired ones included, a user can hold
+const maxAccessTokensPerUser = 25
+
+// AccessTokenListResponse is the body of GET /v1/tokens
+type AccessTokenListResponse struct {
+	Tokens []models.AccessToken `json:"tokens"`
+}
+
+// handleCreateAccessToken issues a token and returns its secret
+func handleCreateAccessToken(ctx context.Context, request events.APIGatewayProxyRequest, userID string) (events.APIGatewayProxyResponse, error) {
+	var createReq models.CreateAccessTokenRequest
+	if err := json.Unmarshal([]byte(request.Body), &createReq); err != nil {
+		return errorResponse(http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body")
+	}
+	createReq.Name = strings.TrimSpace(createReq.Name)
+	if violations := utils.ValidateStruct(&createReq); len(violations) > 0 {
+		return events.APIGatewayProxyResponse{}, utils.NewValidationError(violations)
+	}
+
+	existing, err := tokenStore.List(ctx, userID)
+	if err != nil {
+		return internalError(err, "Failed to create access token")
+	}
+	if len(existing) >= maxAccessTokensPerUser {
+		return errorResponse(http.StatusConflict, "TOKEN_LIMIT_REACHED",
+			fmt.Sprintf("At most %d access tokens are allowed; revoke one first", maxAccessTokensPerUser))
+	}
+
+	days := models.DefaultAccessTokenDays
+	if createReq.ExpiresInDays != nil {
+		days = *createReq.ExpiresInDays
+	}
+	expiresAt := time.Now().UTC().Add(time.Duration(days) * 24 * time.Hour)
+
+	accessToken, secret, err := tokenStore.Create(ctx, userID, createReq.Name, uniqueScopes(createReq.Scopes), expiresAt)
+	if err != nil {
+		return internalError(err, "Failed to create access token")
+	}
+	fmt.Printf("🔑 Created access token %s for user %s with scopes %v\n", accessToken.ID, userID, accessToken.Scopes)
+
+	response, responseErr := utils.NewAPIResponse(http.StatusCreated, models.CreateAccessTokenResponse{
+		Token:       secret,
+		AccessToken: *accessToken,
+	})
+	if responseErr != nil {
+		return events.APIGatewayProxyResponse{}, responseErr
+	}
+	return response, nil
+}
+
+// handleListAccessTokens lists the user's tokens, newest first, without their secrets
+func handleListAccessTokens(ctx context.Context, request events.APIGatewayProxyRequest, userID string) (events.APIGatewayProxyResponse, error) {
+	tokens, err := tokenStore.List(ctx, userID)
+	if err != nil {
+		return internalError(err, "Failed to list access tokens")
+	}
+	sort.SliceStable(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+
+	response, responseErr := utils.NewAPIResponse(http.StatusOK, AccessTokenListResponse{Tokens: tokens})
+	if responseErr != nil {
+		return events.APIGatewayProxyResponse{}, responseErr
+	}
+	return response, nil
+}
+
+// handleRevokeAccessToken deletes one of the user's tokens; it stops working immediately
+func handleRevokeAccessToken(ctx context.Context, userID, tokenID string) (events.APIGatewayProxyResponse, error) {
+	err := tokenStore.Revoke(ctx, userID, tokenID)
+	if errors.Is(err, db.ErrNotFound) {
+		return errorResponse(http.StatusNotFound, "TOKEN_NOT_FOUND", "Access token not found")
+	}
+	if err != nil {
+		return internalError(err, "Failed to revoke access token")
+	}
+	fmt.Printf("🔑 Revoked access token %s for user %s\n", tokenID, userID)
+
+	response, responseErr := utils.NewAPIResponse(http.StatusOK, map[string]interface{}{
+		"message": "Access token revoked",
+	})
+	if responseErr != nil {
+		return events.APIGatewayProxyResponse{}, responseErr
+	}
+	return response, nil
+}
+
+// uniqueScopes drops repeated scopes, keeping the first occurrence
+func uniqueScopes(scopes []string) []string {
+	seen := make(map[string]bool, len(scopes))
+	unique := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			unique = append(unique, scope)
+		}
+	}
+	return unique
+}
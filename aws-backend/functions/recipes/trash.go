@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"recipe-archive/db"
+	"recipe-archive/models"
+	"recipe-archive/utils"
+)
+
+// Trash endpoints. DELETE /v1/recipes/{id} only moves a recipe to the trash; the
+// trash-purge Lambda permanently deletes it once TRASH_RETENTION_DAYS have passed.
+//   GET    /v1/recipes/trash               list trashed recipes
+//   POST   /v1/recipes/{id}/restore        move a recipe out of the trash
+//   DELETE /v1/recipes/{id}/permanent      permanently delete a trashed recipe
+
+// TrashListResponse represents the API response for listing the trash
+type TrashListResponse struct {
+	Recipes       []models.RecipeSummary `json:"recipes"`
+	RetentionDays int                    `json:"retentionDays"`
+}
+
+// handleListTrash lists the user's trashed recipes, most recently deleted first
+func handleListTrash(ctx context.Context, userID string) (events.APIGatewayProxyResponse, error) {
+	summaries, err := listSummaries(ctx, userID)
+	if err != nil {
+		return internalError(err, "Failed to retrieve trash")
+	}
+
+	trashed := []models.RecipeSummary{}
+	for _, summary := range summaries {
+		if summary.IsDeleted {
+			trashed = append(trashed, summary)
+		}
+	}
+	sort.SliceStable(trashed, func(i, j int) bool {
+		return deletedAt(trashed[i]).After(deletedAt(trashed[j]))
+	})
+
+	response, responseErr := utils.NewAPIResponse(http.StatusOK, TrashListResponse{
+		Recipes:       trashed,
+		RetentionDays: int(utils.TrashRetention() / (24 * time.Hour)),
+	})
+	if responseErr != nil {
+		return events.APIGatewayProxyResponse{}, responseErr
+	}
+	return response, nil
+}
+
+// handleRestoreFromTrash clears the deleted flag as a new version of the recipe
+func handleRestoreFromTrash(ctx context.Context, userID, recipeID string) (events.APIGatewayProxyResponse, error) {
+	recipe, err := getTrashedRecipe(ctx, userID, recipeID)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	if err := checkRecipeQuota(ctx, userID, 1); err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	restored := *recipe
+	restored.IsDeleted = false
+	restored.DeletedAt = nil
+	restored.UpdatedAt = time.Now().UTC()
+	restored.Version = recipe.Version + 1
+
+	err = recipeDB.UpdateRecipeIfVersion(ctx, &restored, recipe.Version)
+	var conflict *db.VersionConflictError
+	if errors.As(err, &conflict) {
+		return versionConflictResponse(conflict.Current)
+	}
+	if err != nil {
+		return internalError(err, "Failed to restore recipe")
+	}
+
+	fmt.Printf("♻️ Restored recipe %s from trash\n", recipeID)
+	response, responseErr := utils.NewAPIResponse(http.StatusOK, map[string]interface{}{
+		"recipe":  restored,
+		"message": "Recipe restored from trash",
+	})
+	if responseErr != nil {
+		return events.APIGatewayProxyResponse{}, responseErr
+	}
+	return response, nil
+}
+
+// handlePermanentDelete removes a trashed recipe (and its version history) for good.
+// Recipes must be moved to the trash first so a single mistaken call cannot lose data.
+// The delete is conditional on the version that was read, so a restore or edit that lands
+// in between fails it with 409 instead of being lost.
+func handlePermanentDelete(ctx context.Context, userID, recipeID string) (events.APIGatewayProxyResponse, error) {
+	recipe, err := getTrashedRecipe(ctx, userID, recipeID)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	if purger, ok := recipeDB.(db.TrashPurger); ok {
+		err = purger.DeleteTrashedRecipe(ctx, userID, recipeID, recipe.Version)
+	} else {
+		err = recipeDB.DeleteRecipe(ctx, userID, recipeID)
+	}
+	if errors.Is(err, db.ErrConflict) {
+		return errorResponse(http.StatusConflict, "RECIPE_CHANGED", "Recipe was restored or changed while it was being deleted")
+	}
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		return internalError(err, "Failed to permanently delete recipe")
+	}
+
+	response, responseErr := utils.NewAPIResponse(http.StatusOK, map[string]interface{}{
+		"message": "Recipe permanently deleted from storage",
+	})
+	if responseErr != nil {
+		return events.APIGatewayProxyResponse{}, responseErr
+	}
+	return response, nil
+}
+
+// getTrashedRecipe loads a recipe that must currently be in the trash, failing with 409
+// for one that is not
+func getTrashedRecipe(ctx context.Context, userID, recipeID string) (*models.Recipe, error) {
+	recipe, err := recipeDB.GetRecipe(ctx, userID, recipeID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, utils.NewAppError(http.StatusNotFound, "RECIPE_NOT_FOUND", "Recipe not found")
+		}
+		return nil, utils.NewInternalError("Failed to retrieve recipe", err)
+	}
+	if !recipe.IsDeleted {
+		return nil, utils.NewAppError(http.StatusConflict, "RECIPE_NOT_IN_TRASH", "Recipe is not in the trash")
+	}
+	return recipe, nil
+}
+
+// deletedAt is when a trashed recipe was deleted, falling back to UpdatedAt for recipes
+// trashed before DeletedAt was recorded
+func deletedAt(summary models.RecipeSummary) time.Time {
+	if summary.DeletedAt != nil {
+		return *summary.DeletedAt
+	}
+	return summary.UpdatedAt
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"recipe-archive/localdb"
+	"recipe-archive/models"
+	"recipe-archive/utils"
+)
+
+// restoringDB restores a trashed recipe right after the next GetRecipe has read it, as a
+// concurrent POST /recipes/{id}/restore would
+type restoringDB struct {
+	*localdb.LocalDB
+	restored bool
+}
+
+func (r *restoringDB) GetRecipe(ctx context.Context, userID, recipeID string) (*models.Recipe, error) {
+	recipe, err := r.LocalDB.GetRecipe(ctx, userID, recipeID)
+	if err == nil && !r.restored {
+		r.restored = true
+		restored := *recipe
+		restored.IsDeleted, restored.DeletedAt, restored.Version = false, nil, recipe.Version+1
+		if err := r.LocalDB.UpdateRecipeIfVersion(ctx, &restored, recipe.Version); err != nil {
+			return nil, err
+		}
+	}
+	return recipe, err
+}
+
+func TestPermanentDeleteKeepsRecipeRestoredMeanwhile(t *testing.T) {
+	savedDB := recipeDB
+	defer func() { recipeDB = savedDB }()
+	store := &restoringDB{LocalDB: localdb.NewLocalDB(t.TempDir())}
+	recipeDB = store
+
+	ctx := context.Background()
+	deletedAt := time.Now().UTC()
+	trashed := &models.Recipe{ID: "r1", UserID: "u1", Title: "Soup", Version: 2, IsDeleted: true, DeletedAt: &deletedAt}
+	if err := store.LocalDB.CreateRecipe(ctx, trashed); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	_, err := handlePermanentDelete(ctx, "u1", "r1")
+	var appErr *utils.AppError
+	if !errors.As(err, &appErr) || appErr.Status != http.StatusConflict {
+		t.Fatalf("permanent delete: got %v want 409", err)
+	}
+	if stored, err := store.LocalDB.GetRecipe(ctx, "u1", "r1"); err != nil || stored.IsDeleted {
+		t.Errorf("restored recipe = %+v, %v; want it kept", stored, err)
+	}
+}
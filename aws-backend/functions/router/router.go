@@ -0,0 +1,201 @@
+// Package router dispatches API Gateway proxy requests to handlers by method and path template.
+//
+// Templates such as "/recipes/{id}/versions/{version}" must match the whole request path, after
+// a leading API Gateway stage segment and any prefix registered with StripPrefix ("/v1") are
+// removed. When several templates match, literal segments win over {parameters}. Matched
+// parameters are added to request.PathParameters. A path no template matches gets a 404; a
+// path that matches only under other methods gets a 405 listing them in the Allow header.
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"recipe-archive/utils"
+)
+
+// Middleware wraps a handler, running code before or after it or answering in its place
+type Middleware func(next utils.Handler) utils.Handler
+
+// Router holds the registered routes
+type Router struct {
+	routes     []route
+	middleware []Middleware
+	prefixes   [][]string
+}
+
+type route struct {
+	method   string
+	segments []string
+	handler  utils.Handler
+}
+
+// New creates an empty router
+func New() *Router {
+	return &Router{}
+}
+
+// Use adds middleware that runs for every request, before routing. The first added runs first.
+func (r *Router) Use(middleware ...Middleware) {
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// StripPrefix removes prefix from the start of request paths that have it before matching, so
+// the same templates serve "/v1/recipes" and "/recipes"
+func (r *Router) StripPrefix(prefix string) {
+	r.prefixes = append(r.prefixes, splitPath(prefix))
+}
+
+// Handle registers handler for method and path template, wrapped in the route's own middleware
+func (r *Router) Handle(method, pattern string, handler utils.Handler, middleware ...Middleware) {
+	r.routes = append(r.routes, route{
+		method:   strings.ToUpper(method),
+		segments: splitPath(pattern),
+		handler:  chain(handler, middleware),
+	})
+}
+
+// Serve routes one request
+func (r *Router) Serve(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return chain(r.dispatch, r.middleware)(ctx, request)
+}
+
+func (r *Router) dispatch(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	path := r.trimPrefixes(request)
+
+	var best *route
+	var bestParams map[string]string
+	allowed := map[string]bool{}
+	for i := range r.routes {
+		candidate := &r.routes[i]
+		params, ok := candidate.match(path)
+		if !ok {
+			continue
+		}
+		if candidate.method != request.HTTPMethod {
+			allowed[candidate.method] = true
+			continue
+		}
+		if best == nil || candidate.moreSpecific(best) {
+			best, bestParams = candidate, params
+		}
+	}
+
+	if best == nil {
+		if len(allowed) > 0 {
+			methods := make([]string, 0, len(allowed))
+			for method := range allowed {
+				methods = append(methods, method)
+			}
+			sort.Strings(methods)
+			return events.APIGatewayProxyResponse{}, utils.NewAppError(http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+				fmt.Sprintf("Method %s not allowed", request.HTTPMethod)).
+				WithDetails(map[string]interface{}{"allowedMethods": methods}).
+				WithHeader("Allow", strings.Join(methods, ", "))
+		}
+		return events.APIGatewayProxyResponse{}, utils.NewAppError(http.StatusNotFound, "NOT_FOUND",
+			fmt.Sprintf("No route for %s %s", request.HTTPMethod, request.Path))
+	}
+
+	if len(bestParams) > 0 {
+		merged := make(map[string]string, len(request.PathParameters)+len(bestParams))
+		for name, value := range request.PathParameters {
+			merged[name] = value
+		}
+		for name, value := range bestParams {
+			merged[name] = value
+		}
+		request.PathParameters = merged
+	}
+	return best.handler(ctx, request)
+}
+
+// trimPrefixes splits the request path and removes the stage segment, when API Gateway
+// includes it, and the first registered prefix the path starts with
+func (r *Router) trimPrefixes(request events.APIGatewayProxyRequest) []string {
+	path := splitPath(request.Path)
+	if stage := request.RequestContext.Stage; stage != "" && len(path) > 0 && path[0] == stage {
+		path = path[1:]
+	}
+	for _, prefix := range r.prefixes {
+		if hasPrefix(path, prefix) {
+			return path[len(prefix):]
+		}
+	}
+	return path
+}
+
+func hasPrefix(path, prefix []string) bool {
+	if len(path) < len(prefix) {
+		return false
+	}
+	for i, segment := range prefix {
+		if path[i] != segment {
+			return false
+		}
+	}
+	return true
+}
+
+// match compares the template with every segment of the path and returns its parameters
+func (rt *route) match(path []string) (map[string]string, bool) {
+	if len(path) != len(rt.segments) {
+		return nil, false
+	}
+	var params map[string]string
+	for i, segment := range rt.segments {
+		value := path[i]
+		if name, ok := paramName(segment); ok {
+			if params == nil {
+				params = map[string]string{}
+			}
+			params[name] = value
+		} else if segment != value {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// moreSpecific reports whether rt should win over other when both match the same path: the
+// first segment where they differ decides, a literal beating a parameter
+func (rt *route) moreSpecific(other *route) bool {
+	for i := range rt.segments {
+		_, mine := paramName(rt.segments[i])
+		_, theirs := paramName(other.segments[i])
+		if mine != theirs {
+			return theirs
+		}
+	}
+	return false
+}
+
+func paramName(segment string) (string, bool) {
+	if len(segment) > 2 && strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
+
+func splitPath(path string) []string {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// chain wraps handler so the first middleware runs first
+func chain(handler utils.Handler, middleware []Middleware) utils.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
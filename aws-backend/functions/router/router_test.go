@@ -0,0 +1,119 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"recipe-archive/utils"
+)
+
+// named returns a handler that answers with its name and the id and version parameters
+func named(name string) utils.Handler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		body := name
+		for _, param := range []string{"id", "version"} {
+			if value := request.PathParameters[param]; value != "" {
+				body += " " + param + "=" + value
+			}
+		}
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: body}, nil
+	}
+}
+
+func TestRouterDispatch(t *testing.T) {
+	r := New()
+	r.StripPrefix("/v1")
+	r.Handle("GET", "/recipes", named("list"))
+	r.Handle("GET", "/recipes/trash", named("trash"))
+	r.Handle("GET", "/recipes/{id}", named("get"))
+	r.Handle("DELETE", "/recipes/{id}", named("delete"))
+	r.Handle("GET", "/recipes/{id}/versions/{version}", named("version"))
+
+	tests := []struct {
+		method, path, want string
+	}{
+		{"GET", "/v1/recipes", "list"},
+		{"GET", "/v1/recipes/", "list"},
+		{"GET", "/v1/recipes/trash", "trash"},
+		{"GET", "/v1/recipes/abc", "get id=abc"},
+		{"DELETE", "/v1/recipes/trash", "delete id=trash"},
+		{"GET", "/recipes/abc/versions/3", "version id=abc version=3"},
+		{"GET", "/prod/v1/recipes/abc", "get id=abc"},
+	}
+	for _, tt := range tests {
+		request := events.APIGatewayProxyRequest{HTTPMethod: tt.method, Path: tt.path}
+		request.RequestContext.Stage = "prod"
+		response, err := r.Serve(context.Background(), request)
+		if err != nil || response.Body != tt.want {
+			t.Errorf("%s %s: got %q, %v; want %q", tt.method, tt.path, response.Body, err, tt.want)
+		}
+	}
+}
+
+func TestRouterNotFoundAndMethodNotAllowed(t *testing.T) {
+	r := New()
+	r.StripPrefix("/v1")
+	r.Handle("GET", "/recipes/{id}", named("get"))
+	r.Handle("PUT", "/recipes/{id}", named("put"))
+
+	_, err := r.Serve(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/v1/recipes/abc/unknown"})
+	var appErr *utils.AppError
+	if !errors.As(err, &appErr) || appErr.Status != http.StatusNotFound {
+		t.Errorf("unknown path: %v", err)
+	}
+
+	_, err = r.Serve(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "POST", Path: "/v1/recipes/abc"})
+	if !errors.As(err, &appErr) || appErr.Status != http.StatusMethodNotAllowed || appErr.Headers["Allow"] != "GET, PUT" {
+		t.Errorf("wrong method: %+v", err)
+	}
+}
+
+func TestRouterMatchesWholePath(t *testing.T) {
+	r := New()
+	r.StripPrefix("/v1")
+	r.Handle("GET", "/recipes/{id}", named("get"))
+	r.Handle("DELETE", "/tokens/{tokenId}", named("revoke"))
+
+	for _, path := range []string{
+		"/recipes/tokens/abc", // must not reach DELETE /tokens/{tokenId}
+		"/v2/recipes/abc",
+		"/anything/v1/recipes/abc",
+		"/v1/v1/recipes/abc",
+		"/v1",
+	} {
+		for _, method := range []string{"GET", "DELETE"} {
+			_, err := r.Serve(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: method, Path: path})
+			var appErr *utils.AppError
+			if !errors.As(err, &appErr) || appErr.Status != http.StatusNotFound {
+				t.Errorf("%s %s: got %v, want 404", method, path, err)
+			}
+		}
+	}
+}
+
+func TestRouterMiddlewareOrder(t *testing.T) {
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next utils.Handler) utils.Handler {
+			return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				calls = append(calls, name)
+				return next(ctx, request)
+			}
+		}
+	}
+
+	r := New()
+	r.Use(trace("global1"), trace("global2"))
+	r.Handle("GET", "/recipes", named("list"), trace("route1"), trace("route2"))
+	if _, err := r.Serve(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/recipes"}); err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	if got := strings.Join(calls, ","); got != "global1,global2,route1,route2" {
+		t.Errorf("middleware order: %s", got)
+	}
+}
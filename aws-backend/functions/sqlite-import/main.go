@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"recipe-archive/sqlitedb"
+)
+
+// sqlite-import loads recipes from the {userID}/{recipeID}.json layout into a SQLite database.
+// To migrate from S3: aws s3 sync s3://<bucket>/recipes ./export && sqlite-import -db recipes.db -dir ./export
+func main() {
+	var dbPath = flag.String("db", "./data/recipes.db", "SQLite database file (created if missing)")
+	var dir = flag.String("dir", "", "Directory containing {userID}/{recipeID}.json files (required)")
+	flag.Parse()
+
+	if *dir == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+
+	store, err := sqlitedb.Open(ctx, *dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open SQLite database: %v", err)
+	}
+	defer store.Close()
+
+	result, err := store.ImportFromDirectory(ctx, *dir)
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+
+	for _, failure := range result.Failures {
+		fmt.Printf("⚠️ Skipped %s: %v\n", failure.RecipeID, failure.Err)
+	}
+	fmt.Printf("✅ Imported %d recipes into %s (%d skipped)\n", result.Imported, *dbPath, len(result.Failures))
+}
@@ -0,0 +1,73 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	recipedb "recipe-archive/db"
+	"recipe-archive/models"
+)
+
+// The change log is the recipe_changes table. Entries are inserted in the same transaction
+// as the write they describe and numbered by an AUTOINCREMENT sequence, which never reuses
+// or reorders numbers, so the cursor is simply the last sequence number read.
+
+// recordChange appends an entry to the user's change log
+func recordChange(ctx context.Context, tx *sql.Tx, userID, changeType, recipeID string, version int) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO recipe_changes (user_id, recipe_id, type, version, changed_at) VALUES (?, ?, ?, ?, ?)`,
+		userID, recipeID, changeType, version, formatTime(time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to record recipe change: %w", err)
+	}
+	return nil
+}
+
+// ListChanges returns the user's change log entries after cursor, oldest first
+func (db *SQLiteDB) ListChanges(ctx context.Context, userID, cursor string, limit int) ([]models.RecipeChange, string, bool, error) {
+	var after int64
+	if cursor != "" {
+		parsed, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil || parsed < 0 {
+			return nil, "", false, fmt.Errorf("%w: %q", recipedb.ErrInvalidCursor, cursor)
+		}
+		after = parsed
+	}
+
+	// One extra row tells whether more entries follow
+	rows, err := db.sqlDB.QueryContext(ctx,
+		`SELECT seq, recipe_id, type, version, changed_at FROM recipe_changes
+		WHERE user_id = ? AND seq > ? ORDER BY seq LIMIT ?`, userID, after, limit+1)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to list recipe changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []models.RecipeChange{}
+	hasMore := false
+	for rows.Next() {
+		if len(changes) == limit {
+			hasMore = true
+			break
+		}
+		var change models.RecipeChange
+		var changedAt string
+		if err := rows.Scan(&after, &change.RecipeID, &change.Type, &change.Version, &changedAt); err != nil {
+			return nil, "", false, fmt.Errorf("failed to scan recipe change row: %w", err)
+		}
+		change.ChangedAt, _ = time.Parse(sqliteTimeFormat, changedAt)
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", false, fmt.Errorf("failed to list recipe changes: %w", err)
+	}
+
+	next := cursor
+	if len(changes) > 0 {
+		next = strconv.FormatInt(after, 10)
+	}
+	return changes, next, hasMore, nil
+}
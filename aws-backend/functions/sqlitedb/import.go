@@ -0,0 +1,79 @@
+package sqlitedb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	recipedb "recipe-archive/db"
+)
+
+// ImportResult summarizes an ImportFromDirectory run
+type ImportResult struct {
+	Imported int
+	Failures []recipedb.KeyFailure
+}
+
+// ImportFromDirectory loads every recipe in the {root}/{userID}/{recipeID}.json layout
+// used by the S3 bucket (after `aws s3 sync s3://bucket/recipes root`) and by LocalDB.
+// Existing rows are overwritten, so the import can be re-run. Files that cannot be
+// read or decoded are reported in the result and do not stop the import.
+func (db *SQLiteDB) ImportFromDirectory(ctx context.Context, root string) (*ImportResult, error) {
+	userDirs, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import directory: %w", err)
+	}
+
+	result := &ImportResult{}
+	for _, userDir := range userDirs {
+		if !userDir.IsDir() || strings.HasPrefix(userDir.Name(), ".") {
+			continue
+		}
+		userID := userDir.Name()
+
+		entries, err := os.ReadDir(filepath.Join(root, userID))
+		if err != nil {
+			return result, fmt.Errorf("failed to read user directory %s: %w", userID, err)
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			// Skip nested keys and reserved "_" objects such as _index.json, as S3RecipeDB does
+			if entry.IsDir() || filepath.Ext(name) != ".json" || strings.HasPrefix(name, "_") || strings.HasPrefix(name, ".") {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+
+			recipeID := strings.TrimSuffix(name, ".json")
+			if err := db.importFile(ctx, filepath.Join(root, userID, name), userID, recipeID); err != nil {
+				if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+					return result, err
+				}
+				result.Failures = append(result.Failures, recipedb.KeyFailure{RecipeID: userID + "/" + recipeID, Err: err})
+				continue
+			}
+			result.Imported++
+		}
+	}
+	return result, nil
+}
+
+func (db *SQLiteDB) importFile(ctx context.Context, path, userID, recipeID string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read recipe file: %w", err)
+	}
+	recipe, err := decodeRecipe(string(data))
+	if err != nil {
+		return err
+	}
+
+	// The file path decides ownership, whatever the JSON body says
+	recipe.UserID = userID
+	recipe.ID = recipeID
+	return db.UpdateRecipe(ctx, recipe)
+}
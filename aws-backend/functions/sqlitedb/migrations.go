@@ -0,0 +1,179 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"recipe-archive/models"
+)
+
+// migration is one forward-only schema step. Versions are applied in order and
+// recorded in schema_migrations; append new steps, never edit applied ones.
+// backfill, if set, runs after the statements to populate new columns from existing rows.
+type migration struct {
+	version    int
+	name       string
+	statements []string
+	backfill   func(ctx context.Context, tx *sql.Tx) error
+}
+
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create recipes table",
+		statements: []string{
+			`CREATE TABLE recipes (
+				user_id    TEXT    NOT NULL,
+				id         TEXT    NOT NULL,
+				data       TEXT    NOT NULL,
+				title      TEXT    NOT NULL DEFAULT '',
+				source_url TEXT    NOT NULL DEFAULT '',
+				created_at TEXT    NOT NULL,
+				updated_at TEXT    NOT NULL,
+				is_deleted INTEGER NOT NULL DEFAULT 0,
+				version    INTEGER NOT NULL DEFAULT 0,
+				PRIMARY KEY (user_id, id)
+			)`,
+			`CREATE INDEX recipes_user_source_url ON recipes (user_id, source_url)`,
+			`CREATE INDEX recipes_user_updated_at ON recipes (user_id, updated_at)`,
+		},
+	},
+	{
+		version: 2,
+		name:    "create recipes full-text index",
+		statements: []string{
+			`CREATE VIRTUAL TABLE recipes_fts USING fts5 (
+				user_id UNINDEXED,
+				recipe_id UNINDEXED,
+				title,
+				ingredients,
+				instructions,
+				tokenize = 'unicode61 remove_diacritics 2'
+			)`,
+		},
+	},
+	{
+		version: 3,
+		name:    "create recipe versions table",
+		statements: []string{
+			`CREATE TABLE recipe_versions (
+				user_id   TEXT    NOT NULL,
+				recipe_id TEXT    NOT NULL,
+				version   INTEGER NOT NULL,
+				data      TEXT    NOT NULL,
+				saved_at  TEXT    NOT NULL,
+				PRIMARY KEY (user_id, recipe_id, version)
+			)`,
+		},
+	},
+	{
+		version: 4,
+		name:    "add canonical source URL column",
+		statements: []string{
+			`ALTER TABLE recipes ADD COLUMN canonical_source_url TEXT NOT NULL DEFAULT ''`,
+			`CREATE INDEX recipes_user_canonical_source_url ON recipes (user_id, canonical_source_url)`,
+		},
+		backfill: backfillCanonicalSourceURLs,
+	},
+	{
+		version: 5,
+		name:    "create recipe change log",
+		statements: []string{
+			`CREATE TABLE recipe_changes (
+				seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id    TEXT    NOT NULL,
+				recipe_id  TEXT    NOT NULL,
+				type       TEXT    NOT NULL,
+				version    INTEGER NOT NULL DEFAULT 0,
+				changed_at TEXT    NOT NULL
+			)`,
+			`CREATE INDEX recipe_changes_user_seq ON recipe_changes (user_id, seq)`,
+		},
+	},
+}
+
+// backfillCanonicalSourceURLs fills canonical_source_url for rows written before version 4
+func backfillCanonicalSourceURLs(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, `SELECT user_id, id, source_url FROM recipes WHERE source_url != ''`)
+	if err != nil {
+		return err
+	}
+	type row struct{ userID, id, sourceURL string }
+	var pending []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.userID, &r.id, &r.sourceURL); err != nil {
+			rows.Close()
+			return err
+		}
+		pending = append(pending, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, r := range pending {
+		_, err := tx.ExecContext(ctx, `UPDATE recipes SET canonical_source_url = ? WHERE user_id = ? AND id = ?`,
+			models.CanonicalSourceURL(r.sourceURL), r.userID, r.id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrate applies every migration newer than the recorded schema version, each in its own transaction
+func (db *SQLiteDB) migrate(ctx context.Context) error {
+	_, err := db.sqlDB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	current, err := db.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		err := db.withTx(ctx, func(tx *sql.Tx) error {
+			for _, statement := range m.statements {
+				if _, err := tx.ExecContext(ctx, statement); err != nil {
+					return err
+				}
+			}
+			if m.backfill != nil {
+				if err := m.backfill(ctx, tx); err != nil {
+					return err
+				}
+			}
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+				m.version, m.name, formatTime(time.Now()))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %d (%s): %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+// SchemaVersion returns the highest applied migration version (0 for an empty database)
+func (db *SQLiteDB) SchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	err := db.sqlDB.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
@@ -0,0 +1,392 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" database/sql driver (pure Go, no cgo)
+
+	recipedb "recipe-archive/db"
+	"recipe-archive/models"
+)
+
+// sqliteTimeFormat is a fixed-width RFC3339 layout so updated_at sorts lexically
+const sqliteTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// SQLiteDB implements recipedb.RecipeDB on a single embedded SQLite file for
+// self-hosted installs that run without AWS. The full recipe is kept as JSON in
+// the data column; the remaining columns are projections used for lookups, and
+// recipes_fts is an FTS5 index over the text fields kept in step on every write.
+type SQLiteDB struct {
+	sqlDB *sql.DB
+}
+
+var (
+	_ recipedb.RecipeDB        = (*SQLiteDB)(nil)
+	_ recipedb.SourceURLFinder = (*SQLiteDB)(nil)
+	_ recipedb.TextSearcher    = (*SQLiteDB)(nil)
+	_ recipedb.RecipeHistory   = (*SQLiteDB)(nil)
+	_ recipedb.ChangeLog       = (*SQLiteDB)(nil)
+	_ recipedb.TrashPurger     = (*SQLiteDB)(nil)
+)
+
+// Open opens (creating if needed) the database at path and applies any pending migrations
+func Open(ctx context.Context, path string) (*SQLiteDB, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)", path)
+	sqlDB, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	// SQLite allows a single writer; one connection keeps writes serialized without SQLITE_BUSY
+	sqlDB.SetMaxOpenConns(1)
+
+	db := &SQLiteDB{sqlDB: sqlDB}
+	if err := db.migrate(ctx); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Close releases the underlying database handle
+func (db *SQLiteDB) Close() error {
+	return db.sqlDB.Close()
+}
+
+// GetRecipe retrieves a recipe by user ID and recipe ID
+func (db *SQLiteDB) GetRecipe(ctx context.Context, userID, recipeID string) (*models.Recipe, error) {
+	return getRecipe(ctx, db.sqlDB, userID, recipeID)
+}
+
+// ListRecipes returns every recipe for a user. Rows that fail to decode are
+// reported in a *recipedb.PartialListError alongside the recipes that did.
+func (db *SQLiteDB) ListRecipes(ctx context.Context, userID string) ([]models.Recipe, error) {
+	rows, err := db.sqlDB.QueryContext(ctx,
+		`SELECT id, data FROM recipes WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recipes: %w", err)
+	}
+	defer rows.Close()
+
+	var recipes []models.Recipe
+	var failures []recipedb.KeyFailure
+	for rows.Next() {
+		var recipeID, data string
+		if err := rows.Scan(&recipeID, &data); err != nil {
+			return nil, fmt.Errorf("failed to scan recipe row: %w", err)
+		}
+		recipe, err := decodeRecipe(data)
+		if err != nil {
+			failures = append(failures, recipedb.KeyFailure{RecipeID: recipeID, Err: err})
+			continue
+		}
+		recipes = append(recipes, *recipe)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list recipes: %w", err)
+	}
+
+	if len(failures) > 0 {
+		return recipes, &recipedb.PartialListError{Failures: failures}
+	}
+	return recipes, nil
+}
+
+// ListRecipeSummaries returns the index projection of every recipe for a user
+func (db *SQLiteDB) ListRecipeSummaries(ctx context.Context, userID string) ([]models.RecipeSummary, error) {
+	recipes, err := db.ListRecipes(ctx, userID)
+	var partial *recipedb.PartialListError
+	if err != nil && !errors.As(err, &partial) {
+		return nil, err
+	}
+
+	summaries := make([]models.RecipeSummary, 0, len(recipes))
+	for i := range recipes {
+		summaries = append(summaries, recipes[i].Summary())
+	}
+	return summaries, err
+}
+
+// FindBySourceURL returns the user's recipe whose source URL has the same canonical form as
+// sourceURL (see models.CanonicalSourceURL), preferring active and recently updated recipes
+func (db *SQLiteDB) FindBySourceURL(ctx context.Context, userID, sourceURL string) (*models.Recipe, error) {
+	var data string
+	err := db.sqlDB.QueryRowContext(ctx,
+		`SELECT data FROM recipes WHERE user_id = ? AND canonical_source_url = ?
+		ORDER BY is_deleted, updated_at DESC LIMIT 1`, userID, models.CanonicalSourceURL(sourceURL)).Scan(&data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find recipe by source URL: %w", mapSQLError(err))
+	}
+	return decodeRecipe(data)
+}
+
+// SearchRecipeIDs returns the IDs of the user's recipes whose title, ingredients or
+// instructions contain every word of query (prefix match), best match first
+func (db *SQLiteDB) SearchRecipeIDs(ctx context.Context, userID, query string) ([]string, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+
+	rows, err := db.sqlDB.QueryContext(ctx,
+		`SELECT recipe_id FROM recipes_fts WHERE recipes_fts MATCH ? AND user_id = ? ORDER BY rank`, match, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search recipes: %w", err)
+	}
+	defer rows.Close()
+
+	var recipeIDs []string
+	for rows.Next() {
+		var recipeID string
+		if err := rows.Scan(&recipeID); err != nil {
+			return nil, fmt.Errorf("failed to scan search row: %w", err)
+		}
+		recipeIDs = append(recipeIDs, recipeID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search recipes: %w", err)
+	}
+	return recipeIDs, nil
+}
+
+// CreateRecipe creates a new recipe
+func (db *SQLiteDB) CreateRecipe(ctx context.Context, recipe *models.Recipe) error {
+	return db.withTx(ctx, func(tx *sql.Tx) error {
+		return putRecipe(ctx, tx, recipe, models.ChangeCreate)
+	})
+}
+
+// UpdateRecipe updates an existing recipe (overwrites, like S3)
+func (db *SQLiteDB) UpdateRecipe(ctx context.Context, recipe *models.Recipe) error {
+	return db.withTx(ctx, func(tx *sql.Tx) error {
+		return putRecipe(ctx, tx, recipe, models.ChangeUpdate)
+	})
+}
+
+// UpdateRecipeIfVersion stores recipe only if the stored copy is still at expectedVersion.
+// The check, the archive of the replaced copy and the write run in one transaction.
+func (db *SQLiteDB) UpdateRecipeIfVersion(ctx context.Context, recipe *models.Recipe, expectedVersion int) error {
+	return db.withTx(ctx, func(tx *sql.Tx) error {
+		current, err := getRecipe(ctx, tx, recipe.UserID, recipe.ID)
+		if err != nil {
+			return err
+		}
+		if current.Version != expectedVersion {
+			return &recipedb.VersionConflictError{Current: current}
+		}
+		if err := archiveVersion(ctx, tx, current); err != nil {
+			return err
+		}
+		return putRecipe(ctx, tx, recipe, models.ChangeUpdate)
+	})
+}
+
+// DeleteRecipe deletes a recipe and its archived versions
+func (db *SQLiteDB) DeleteRecipe(ctx context.Context, userID, recipeID string) error {
+	return db.withTx(ctx, func(tx *sql.Tx) error {
+		return deleteRecipe(ctx, tx, userID, recipeID)
+	})
+}
+
+// DeleteTrashedRecipe deletes a recipe only if it is still in the trash at version; the
+// check and the delete run in one transaction
+func (db *SQLiteDB) DeleteTrashedRecipe(ctx context.Context, userID, recipeID string, version int) error {
+	return db.withTx(ctx, func(tx *sql.Tx) error {
+		current, err := getRecipe(ctx, tx, userID, recipeID)
+		if err != nil {
+			return err
+		}
+		if !current.IsDeleted || current.Version != version {
+			return fmt.Errorf("recipe %s is no longer in the trash at version %d: %w", recipeID, version, recipedb.ErrConflict)
+		}
+		return deleteRecipe(ctx, tx, userID, recipeID)
+	})
+}
+
+// ListRecipeVersions returns the archived versions of a recipe, oldest first
+func (db *SQLiteDB) ListRecipeVersions(ctx context.Context, userID, recipeID string) ([]models.RecipeVersionInfo, error) {
+	rows, err := db.sqlDB.QueryContext(ctx,
+		`SELECT version, saved_at FROM recipe_versions WHERE user_id = ? AND recipe_id = ? ORDER BY version`, userID, recipeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recipe versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []models.RecipeVersionInfo{}
+	for rows.Next() {
+		var info models.RecipeVersionInfo
+		var savedAt string
+		if err := rows.Scan(&info.Version, &savedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan recipe version row: %w", err)
+		}
+		info.SavedAt, _ = time.Parse(sqliteTimeFormat, savedAt)
+		versions = append(versions, info)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list recipe versions: %w", err)
+	}
+	return versions, nil
+}
+
+// GetRecipeVersion retrieves one archived version of a recipe
+func (db *SQLiteDB) GetRecipeVersion(ctx context.Context, userID, recipeID string, version int) (*models.Recipe, error) {
+	var data string
+	err := db.sqlDB.QueryRowContext(ctx,
+		`SELECT data FROM recipe_versions WHERE user_id = ? AND recipe_id = ? AND version = ?`,
+		userID, recipeID, version).Scan(&data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recipe version %d: %w", version, mapSQLError(err))
+	}
+	return decodeRecipe(data)
+}
+
+// querier is the subset of *sql.DB and *sql.Tx used by the read helpers
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func getRecipe(ctx context.Context, q querier, userID, recipeID string) (*models.Recipe, error) {
+	var data string
+	err := q.QueryRowContext(ctx,
+		`SELECT data FROM recipes WHERE user_id = ? AND id = ?`, userID, recipeID).Scan(&data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recipe: %w", mapSQLError(err))
+	}
+	return decodeRecipe(data)
+}
+
+// putRecipe upserts the recipe row, replaces its full-text entry and records the write in
+// the change log (as a delete if it moves the recipe to the trash)
+func putRecipe(ctx context.Context, tx *sql.Tx, recipe *models.Recipe, changeType string) error {
+	data, err := json.Marshal(recipe)
+	if err != nil {
+		return fmt.Errorf("failed to marshal recipe: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO recipes (user_id, id, data, title, source_url, canonical_source_url, created_at, updated_at, is_deleted, version)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, id) DO UPDATE SET
+			data = excluded.data,
+			title = excluded.title,
+			source_url = excluded.source_url,
+			canonical_source_url = excluded.canonical_source_url,
+			created_at = excluded.created_at,
+			updated_at = excluded.updated_at,
+			is_deleted = excluded.is_deleted,
+			version = excluded.version`,
+		recipe.UserID, recipe.ID, string(data), recipe.Title, recipe.SourceURL, models.CanonicalSourceURL(recipe.SourceURL),
+		formatTime(recipe.CreatedAt), formatTime(recipe.UpdatedAt), recipe.IsDeleted, recipe.Version)
+	if err != nil {
+		return fmt.Errorf("failed to store recipe: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recipes_fts WHERE user_id = ? AND recipe_id = ?`, recipe.UserID, recipe.ID); err != nil {
+		return fmt.Errorf("failed to clear recipe search entry: %w", err)
+	}
+	ingredients, instructions := searchText(recipe)
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO recipes_fts (user_id, recipe_id, title, ingredients, instructions) VALUES (?, ?, ?, ?, ?)`,
+		recipe.UserID, recipe.ID, recipe.Title, ingredients, instructions)
+	if err != nil {
+		return fmt.Errorf("failed to index recipe for search: %w", err)
+	}
+
+	if recipe.IsDeleted {
+		changeType = models.ChangeDelete
+	}
+	return recordChange(ctx, tx, recipe.UserID, changeType, recipe.ID, recipe.Version)
+}
+
+// deleteRecipe removes a recipe, its search entry and its archived versions, and logs the delete
+func deleteRecipe(ctx context.Context, tx *sql.Tx, userID, recipeID string) error {
+	result, err := tx.ExecContext(ctx, `DELETE FROM recipes WHERE user_id = ? AND id = ?`, userID, recipeID)
+	if err != nil {
+		return fmt.Errorf("failed to delete recipe: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("failed to delete recipe: %w", recipedb.ErrNotFound)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recipes_fts WHERE user_id = ? AND recipe_id = ?`, userID, recipeID); err != nil {
+		return fmt.Errorf("failed to delete recipe search entry: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recipe_versions WHERE user_id = ? AND recipe_id = ?`, userID, recipeID); err != nil {
+		return fmt.Errorf("failed to delete recipe versions: %w", err)
+	}
+	return recordChange(ctx, tx, userID, models.ChangeDelete, recipeID, 0)
+}
+
+// archiveVersion keeps a copy of recipe under its version number, replacing any earlier archive of it
+func archiveVersion(ctx context.Context, tx *sql.Tx, recipe *models.Recipe) error {
+	data, err := json.Marshal(recipe)
+	if err != nil {
+		return fmt.Errorf("failed to marshal recipe version: %w", err)
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT OR REPLACE INTO recipe_versions (user_id, recipe_id, version, data, saved_at) VALUES (?, ?, ?, ?, ?)`,
+		recipe.UserID, recipe.ID, recipe.Version, string(data), formatTime(time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to archive recipe version %d: %w", recipe.Version, err)
+	}
+	return nil
+}
+
+func (db *SQLiteDB) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := db.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func decodeRecipe(data string) (*models.Recipe, error) {
+	return recipedb.DecodeRecipe([]byte(data))
+}
+
+// searchText flattens ingredient and instruction text into the FTS columns
+func searchText(recipe *models.Recipe) (string, string) {
+	ingredients := make([]string, 0, len(recipe.Ingredients))
+	for _, ingredient := range recipe.Ingredients {
+		ingredients = append(ingredients, ingredient.Text)
+	}
+	instructions := make([]string, 0, len(recipe.Instructions))
+	for _, instruction := range recipe.Instructions {
+		instructions = append(instructions, instruction.Text)
+	}
+	return strings.Join(ingredients, "\n"), strings.Join(instructions, "\n")
+}
+
+// ftsQuery turns free text into an FTS5 query that ANDs a prefix match for each word.
+// Words are quoted so user input can never be parsed as FTS5 syntax.
+func ftsQuery(query string) string {
+	var terms []string
+	for _, word := range strings.Fields(query) {
+		word = strings.ReplaceAll(word, `"`, `""`)
+		terms = append(terms, `"`+word+`"*`)
+	}
+	return strings.Join(terms, " AND ")
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+// mapSQLError tags database/sql errors with the matching db sentinel
+func mapSQLError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %w", recipedb.ErrNotFound, err)
+	}
+	return err
+}
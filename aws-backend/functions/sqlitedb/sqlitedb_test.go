@@ -0,0 +1,169 @@
+package sqlitedb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	recipedb "recipe-archive/db"
+	"recipe-archive/models"
+)
+
+func openTestDB(t *testing.T) *SQLiteDB {
+	t.Helper()
+	store, err := Open(context.Background(), filepath.Join(t.TempDir(), "recipes.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSQLiteDBCRUDAndVersionCheck(t *testing.T) {
+	ctx := context.Background()
+	store := openTestDB(t)
+
+	recipe := &models.Recipe{ID: "r1", UserID: "u1", Title: "Soup", SourceURL: "https://example.com/soup", Version: 1}
+	if err := store.CreateRecipe(ctx, recipe); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if got, err := store.FindBySourceURL(ctx, "u1", "https://example.com/soup"); err != nil || got.ID != "r1" {
+		t.Fatalf("find by source URL: got %+v err %v", got, err)
+	}
+	if got, err := store.FindBySourceURL(ctx, "u1", "http://www.example.com/soup/?utm_source=feed"); err != nil || got.ID != "r1" {
+		t.Fatalf("find by canonical source URL: got %+v err %v", got, err)
+	}
+
+	err := store.UpdateRecipeIfVersion(ctx, &models.Recipe{ID: "r1", UserID: "u1", Title: "Stale", Version: 2}, 0)
+	var conflict *recipedb.VersionConflictError
+	if !errors.As(err, &conflict) || conflict.Current.Title != "Soup" {
+		t.Fatalf("stale update: got %v want VersionConflictError with current recipe", err)
+	}
+	if err := store.UpdateRecipeIfVersion(ctx, &models.Recipe{ID: "r1", UserID: "u1", Title: "Stew", Version: 2}, 1); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	if old, err := store.GetRecipeVersion(ctx, "u1", "r1", 1); err != nil || old.Title != "Soup" {
+		t.Fatalf("archived version 1: got %+v err %v", old, err)
+	}
+	if versions, err := store.ListRecipeVersions(ctx, "u1", "r1"); err != nil || len(versions) != 1 {
+		t.Fatalf("versions: got %+v err %v", versions, err)
+	}
+
+	summaries, err := store.ListRecipeSummaries(ctx, "u1")
+	if err != nil || len(summaries) != 1 || summaries[0].Title != "Stew" || summaries[0].Version != 2 {
+		t.Fatalf("summaries: got %+v err %v", summaries, err)
+	}
+
+	if err := store.DeleteRecipe(ctx, "u1", "r1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.GetRecipe(ctx, "u1", "r1"); !errors.Is(err, recipedb.ErrNotFound) {
+		t.Errorf("get after delete: got %v want ErrNotFound", err)
+	}
+	if err := store.DeleteRecipe(ctx, "u1", "r1"); !errors.Is(err, recipedb.ErrNotFound) {
+		t.Errorf("second delete: got %v want ErrNotFound", err)
+	}
+	if _, err := store.GetRecipeVersion(ctx, "u1", "r1", 1); !errors.Is(err, recipedb.ErrNotFound) {
+		t.Errorf("version after delete: got %v want ErrNotFound", err)
+	}
+
+	first, cursor, hasMore, err := store.ListChanges(ctx, "u1", "", 2)
+	if err != nil || len(first) != 2 || !hasMore || first[0].Type != models.ChangeCreate || first[1].Type != models.ChangeUpdate {
+		t.Fatalf("changes page 1: got %+v more %v err %v", first, hasMore, err)
+	}
+	rest, _, hasMore, err := store.ListChanges(ctx, "u1", cursor, 2)
+	if err != nil || len(rest) != 1 || hasMore || rest[0].Type != models.ChangeDelete || rest[0].RecipeID != "r1" {
+		t.Errorf("changes page 2: got %+v more %v err %v", rest, hasMore, err)
+	}
+}
+
+func TestSQLiteDBSearchRecipeIDs(t *testing.T) {
+	ctx := context.Background()
+	store := openTestDB(t)
+
+	recipes := []*models.Recipe{
+		{ID: "r1", UserID: "u1", Title: "Chickpea Curry", Ingredients: []models.Ingredient{{Text: "1 can coconut milk"}}},
+		{ID: "r2", UserID: "u1", Title: "Pancakes", Instructions: []models.Instruction{{StepNumber: 1, Text: "Whisk the flour and milk"}}},
+		{ID: "r3", UserID: "u2", Title: "Milkshake"},
+	}
+	for _, recipe := range recipes {
+		if err := store.CreateRecipe(ctx, recipe); err != nil {
+			t.Fatalf("create %s: %v", recipe.ID, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"milk", []string{"r1", "r2"}}, // ingredients and instructions, scoped to the user
+		{"chick", []string{"r1"}},      // prefix match
+		{"coconut whisk", nil},         // every word must match
+		{`"curry`, []string{"r1"}},     // quotes in user input are not FTS syntax
+	}
+	for _, tt := range tests {
+		got, err := store.SearchRecipeIDs(ctx, "u1", tt.query)
+		if err != nil {
+			t.Fatalf("search %q: %v", tt.query, err)
+		}
+		if len(got) == 2 && got[0] > got[1] {
+			got[0], got[1] = got[1], got[0]
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("search %q: got %v want %v", tt.query, got, tt.want)
+		}
+	}
+
+	// The full-text entry follows updates
+	if err := store.UpdateRecipe(ctx, &models.Recipe{ID: "r1", UserID: "u1", Title: "Lentil Dal"}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got, _ := store.SearchRecipeIDs(ctx, "u1", "chickpea"); len(got) != 0 {
+		t.Errorf("search after update: got %v want none", got)
+	}
+}
+
+func TestImportFromDirectory(t *testing.T) {
+	ctx := context.Background()
+	store := openTestDB(t)
+
+	root := t.TempDir()
+	files := map[string]string{
+		"u1/r1.json":        `{"id":"r1","userId":"u1","title":"Soup","version":1}`,
+		"u1/r2.json":        `{"id":"other","userId":"someone-else","title":"Bread"}`,
+		"u1/_index.json":    `{"entries":{}}`,
+		"u1/broken.json":    `{not json`,
+		"u2/nested/r3.json": `{"id":"r3","title":"Ignored"}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := store.ImportFromDirectory(ctx, root)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if result.Imported != 2 || len(result.Failures) != 1 || !errors.Is(result.Failures[0].Err, recipedb.ErrCorrupt) {
+		t.Fatalf("import result: %+v", result)
+	}
+
+	got, err := store.GetRecipe(ctx, "u1", "r2")
+	if err != nil || got.UserID != "u1" || got.ID != "r2" {
+		t.Errorf("imported recipe keyed by path: got %+v err %v", got, err)
+	}
+
+	version, err := store.SchemaVersion(ctx)
+	if err != nil || version != len(migrations) {
+		t.Errorf("schema version: got %d err %v want %d", version, err, len(migrations))
+	}
+}
@@ -54,7 +54,7 @@ func testBackupFunctionality(ctx context.Context, userID string) error {
 
 	// Create test recipes
 	for i, recipe := range testRecipes {
-		err := recipeDB.CreateRecipe(&recipe)
+		err := recipeDB.CreateRecipe(ctx, &recipe)
 		if err != nil {
 			return fmt.Errorf("failed to create test recipe %d: %w", i+1, err)
 		}
@@ -65,7 +65,7 @@ func testBackupFunctionality(ctx context.Context, userID string) error {
 	fmt.Println("\n2️⃣ Testing backup creation...")
 
 	// Get all recipes (simulating what backup function does)
-	allRecipes, err := recipeDB.ListRecipes(userID)
+	allRecipes, err := recipeDB.ListRecipes(ctx, userID)
 	if err != nil {
 		return fmt.Errorf("failed to list recipes for backup test: %w", err)
 	}
@@ -160,7 +160,7 @@ func testBackupFunctionality(ctx context.Context, userID string) error {
 		recipe.IsDeleted = true
 		recipe.UpdatedAt = time.Now().UTC()
 
-		err := recipeDB.UpdateRecipe(&recipe)
+		err := recipeDB.UpdateRecipe(ctx, &recipe)
 		if err != nil {
 			fmt.Printf("  ⚠️ Failed to clean up test recipe %s: %v\n", recipe.ID, err)
 		} else {
@@ -15,6 +15,7 @@ import (
 	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 
 	"recipe-archive/db"
+	"recipe-archive/envelope"
 	"recipe-archive/models"
 )
 
@@ -39,7 +40,13 @@ func init() {
 	}
 
 	// Initialize S3-based recipe storage
-	recipeDB = db.NewS3RecipeDB(s3Client, bucketName)
+	s3DB := db.NewS3RecipeDB(s3Client, bucketName)
+	encryptor, err := envelope.FromEnv(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure recipe encryption: %v", err)
+	}
+	s3DB.SetEncryptor(encryptor)
+	recipeDB = s3DB
 }
 
 func main() {
@@ -138,7 +145,7 @@ func loadTestData(ctx context.Context, filePath string) error {
 		}
 
 		// Create recipe in S3
-		err := recipeDB.CreateRecipe(&recipe)
+		err := recipeDB.CreateRecipe(ctx, &recipe)
 		if err != nil {
 			return fmt.Errorf("failed to insert recipe %d (%s): %w", i+1, recipe.Title, err)
 		}
@@ -226,7 +233,7 @@ func validateCRUD(ctx context.Context, userID string) error {
 	}
 
 	// Create the recipe using S3 storage
-	err := recipeDB.CreateRecipe(&testRecipe)
+	err := recipeDB.CreateRecipe(ctx, &testRecipe)
 	if err != nil {
 		return fmt.Errorf("CREATE test failed: %w", err)
 	}
@@ -234,7 +241,7 @@ func validateCRUD(ctx context.Context, userID string) error {
 
 	// Test READ
 	fmt.Println("\\n2️⃣ Testing READ operation...")
-	retrievedRecipe, err := recipeDB.GetRecipe(userID, testRecipe.ID)
+	retrievedRecipe, err := recipeDB.GetRecipe(ctx, userID, testRecipe.ID)
 	if err != nil {
 		return fmt.Errorf("READ test failed: %w", err)
 	}
@@ -251,13 +258,13 @@ func validateCRUD(ctx context.Context, userID string) error {
 	retrievedRecipe.UpdatedAt = time.Now().UTC()
 	retrievedRecipe.Version = retrievedRecipe.Version + 1
 
-	err = recipeDB.UpdateRecipe(retrievedRecipe)
+	err = recipeDB.UpdateRecipe(ctx, retrievedRecipe)
 	if err != nil {
 		return fmt.Errorf("UPDATE test failed: %w", err)
 	}
 
 	// Verify update
-	verifyRecipe, err := recipeDB.GetRecipe(userID, testRecipe.ID)
+	verifyRecipe, err := recipeDB.GetRecipe(ctx, userID, testRecipe.ID)
 	if err != nil {
 		return fmt.Errorf("UPDATE verification failed: %w", err)
 	}
@@ -272,7 +279,7 @@ func validateCRUD(ctx context.Context, userID string) error {
 	verifyRecipe.IsDeleted = true
 	verifyRecipe.UpdatedAt = time.Now().UTC()
 
-	err = recipeDB.UpdateRecipe(verifyRecipe)
+	err = recipeDB.UpdateRecipe(ctx, verifyRecipe)
 	if err != nil {
 		return fmt.Errorf("DELETE test failed: %w", err)
 	}
@@ -280,7 +287,7 @@ func validateCRUD(ctx context.Context, userID string) error {
 
 	// Verify soft delete by trying to read again
 	fmt.Println("\\n5️⃣ Verifying soft delete...")
-	deletedRecipe, err := recipeDB.GetRecipe(userID, testRecipe.ID)
+	deletedRecipe, err := recipeDB.GetRecipe(ctx, userID, testRecipe.ID)
 	if err != nil {
 		return fmt.Errorf("failed to verify soft delete: %w", err)
 	}
@@ -297,7 +304,7 @@ func validateCRUD(ctx context.Context, userID string) error {
 func listRecipes(ctx context.Context, userID string) error {
 	fmt.Printf("📋 Listing recipes for user: %s...\\n", userID)
 
-	recipes, err := recipeDB.ListRecipes(userID)
+	recipes, err := recipeDB.ListRecipes(ctx, userID)
 	if err != nil {
 		return fmt.Errorf("failed to list recipes: %w", err)
 	}
@@ -335,7 +342,7 @@ func cleanupDuplicateRecipes(ctx context.Context, userID string) error {
 	fmt.Printf("🧹 Cleaning up duplicate recipes for user: %s...\n", userID)
 
 	// Get all recipes for the user
-	recipes, err := recipeDB.ListRecipes(userID)
+	recipes, err := recipeDB.ListRecipes(ctx, userID)
 	if err != nil {
 		return fmt.Errorf("failed to list recipes: %w", err)
 	}
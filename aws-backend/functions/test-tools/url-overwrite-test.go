@@ -43,7 +43,7 @@ func URLOverwriteTest(ctx context.Context, userID string) error {
 	}
 
 	// Create original recipe in S3
-	err := recipeDB.CreateRecipe(&originalRecipe)
+	err := recipeDB.CreateRecipe(ctx, &originalRecipe)
 	if err != nil {
 		return fmt.Errorf("failed to create original recipe: %w", err)
 	}
@@ -85,7 +85,7 @@ func URLOverwriteTest(ctx context.Context, userID string) error {
 	}
 
 	// Perform overwrite using S3 storage (S3 overwrites by default)
-	err = recipeDB.UpdateRecipe(&updatedRecipe)
+	err = recipeDB.UpdateRecipe(ctx, &updatedRecipe)
 	if err != nil {
 		return fmt.Errorf("failed to overwrite recipe: %w", err)
 	}
@@ -100,7 +100,7 @@ func URLOverwriteTest(ctx context.Context, userID string) error {
 	// Step 3: Verify the overwrite worked correctly
 	fmt.Println("\n3️⃣ Verifying overwrite results...")
 
-	retrievedRecipe, err := recipeDB.GetRecipe(userID, originalRecipe.ID)
+	retrievedRecipe, err := recipeDB.GetRecipe(ctx, userID, originalRecipe.ID)
 	if err != nil {
 		return fmt.Errorf("failed to retrieve overwritten recipe: %w", err)
 	}
@@ -163,7 +163,7 @@ func URLOverwriteTest(ctx context.Context, userID string) error {
 		Version:   1,
 	}
 
-	err = recipeDB.CreateRecipe(&differentUserRecipe)
+	err = recipeDB.CreateRecipe(ctx, &differentUserRecipe)
 	if err != nil {
 		return fmt.Errorf("failed to create recipe for different user: %w", err)
 	}
@@ -185,7 +185,7 @@ func URLOverwriteTest(ctx context.Context, userID string) error {
 
 	for _, test := range testRecipes {
 		// Get recipe and mark as deleted
-		recipe, err := recipeDB.GetRecipe(test.userID, test.recipeID)
+		recipe, err := recipeDB.GetRecipe(ctx, test.userID, test.recipeID)
 		if err != nil {
 			fmt.Printf("  ⚠️ Failed to get recipe for cleanup %s: %v\\n", test.recipeID, err)
 			continue
@@ -194,7 +194,7 @@ func URLOverwriteTest(ctx context.Context, userID string) error {
 		recipe.IsDeleted = true
 		recipe.UpdatedAt = time.Now().UTC()
 
-		err = recipeDB.UpdateRecipe(recipe)
+		err = recipeDB.UpdateRecipe(ctx, recipe)
 		if err != nil {
 			fmt.Printf("  ⚠️ Failed to clean up recipe %s: %v\\n", test.recipeID, err)
 		} else {
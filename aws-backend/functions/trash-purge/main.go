@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"recipe-archive/db"
+	"recipe-archive/envelope"
+	"recipe-archive/utils"
+)
+
+var recipeDB *db.S3RecipeDB
+
+func init() {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load AWS config: %v", err))
+	}
+
+	bucketName := os.Getenv("S3_STORAGE_BUCKET")
+	if bucketName == "" {
+		bucketName = "recipe-archive-dev" // fallback for testing
+	}
+
+	recipeDB = db.NewS3RecipeDB(s3.NewFromConfig(cfg), bucketName)
+
+	// Purging reads each recipe's deletedAt, so sealed recipes must be readable
+	encryptor, err := envelope.FromEnv(cfg)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to configure recipe encryption: %v", err))
+	}
+	recipeDB.SetEncryptor(encryptor)
+}
+
+func main() {
+	lambda.Start(handler)
+}
+
+// PurgeResult summarizes a scheduled purge run
+type PurgeResult struct {
+	Users  int `json:"users"`
+	Purged int `json:"purged"`
+	Errors int `json:"errors"`
+}
+
+// handler runs on a schedule and permanently deletes recipes that have been in the
+// trash longer than TRASH_RETENTION_DAYS. Failures for one user do not stop the run.
+func handler(ctx context.Context) (PurgeResult, error) {
+	ctx, cancel := utils.WithDeadlineMargin(ctx, utils.LambdaDeadlineMargin)
+	defer cancel()
+
+	cutoff := time.Now().UTC().Add(-utils.TrashRetention())
+	fmt.Printf("🗑️ Purging recipes trashed before %s\n", cutoff.Format(time.RFC3339))
+
+	userIDs, err := recipeDB.ListUserIDs(ctx)
+	if err != nil {
+		return PurgeResult{}, err
+	}
+
+	result := PurgeResult{Users: len(userIDs)}
+	for _, userID := range userIDs {
+		purged, err := db.PurgeTrash(ctx, recipeDB, userID, cutoff)
+		result.Purged += purged
+		if err != nil {
+			fmt.Printf("⚠️ Failed to purge trash for user %s: %v\n", userID, err)
+			result.Errors++
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+		}
+	}
+
+	fmt.Printf("✅ Purged %d recipes across %d users (%d errors)\n", result.Purged, result.Users, result.Errors)
+	return result, nil
+}
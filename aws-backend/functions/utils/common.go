@@ -10,8 +10,6 @@ import (
 	"strings"
 	"time"
 
-	"recipe-archive/models"
-
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -36,6 +34,8 @@ type JWTClaims struct {
 	EmailVerified bool   `json:"email_verified"`
 	GivenName     string `json:"given_name"`
 	FamilyName    string `json:"family_name"`
+	TokenUse      string `json:"token_use"` // "id" or "access"
+	ClientID      string `json:"client_id"` // access tokens only
 	jwt.RegisteredClaims
 }
 
@@ -58,8 +58,9 @@ func NewAPIResponse(statusCode int, body interface{}) (events.APIGatewayProxyRes
 		Headers: map[string]string{
 			"Content-Type":                     "application/json",
 			"Access-Control-Allow-Origin":      "https://d1jcaphz4458q7.cloudfront.net",
-			"Access-Control-Allow-Methods":     "GET, POST, PUT, DELETE, OPTIONS",
-			"Access-Control-Allow-Headers":     "Content-Type, Authorization",
+			"Access-Control-Allow-Methods":     "GET, POST, PUT, PATCH, DELETE, OPTIONS",
+			"Access-Control-Allow-Headers":     "Content-Type, Authorization, If-None-Match, If-Modified-Since",
+			"Access-Control-Expose-Headers":    "ETag, Last-Modified, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy, Retry-After",
 			"Access-Control-Allow-Credentials": "true",
 		},
 		Body: string(bodyJSON),
@@ -68,50 +69,39 @@ func NewAPIResponse(statusCode int, body interface{}) (events.APIGatewayProxyRes
 
 // NewErrorResponse creates a standardized error response
 func NewErrorResponse(code string, message string, statusCode int, details interface{}, requestID string) (events.APIGatewayProxyResponse, error) {
-	errorResp := models.ErrorResponse{
-		Error: models.APIError{
-			Code:      code,
-			Message:   message,
-			Details:   details,
-			Timestamp: time.Now().UTC(),
-			RequestID: requestID,
-		},
-	}
-	return NewAPIResponse(statusCode, errorResp)
+	return ErrorResponseFor(NewAppError(statusCode, code, message).WithDetails(details), requestID)
 }
 
-// ExtractUserFromJWT extracts and validates user information from JWT token
+// ExtractUserFromJWT verifies the bearer token of a request and returns its claims
 func ExtractUserFromJWT(request events.APIGatewayProxyRequest) (*JWTClaims, error) {
-	authHeader := request.Headers["Authorization"]
-	if authHeader == "" {
-		authHeader = request.Headers["authorization"]
+	token, err := BearerToken(request)
+	if err != nil {
+		return nil, err
 	}
+	return VerifyJWT(context.Background(), token)
+}
 
+// BearerToken returns the token from the request's "Authorization: Bearer <token>" header
+func BearerToken(request events.APIGatewayProxyRequest) (string, error) {
+	authHeader := GetHeader(request, "Authorization")
 	if authHeader == "" {
-		return nil, fmt.Errorf("authorization header missing")
+		return "", fmt.Errorf("authorization header missing")
 	}
 
-	// Extract token from "Bearer <token>"
 	tokenParts := strings.Split(authHeader, " ")
-	if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-		return nil, fmt.Errorf("invalid authorization header format")
+	if len(tokenParts) != 2 || !strings.EqualFold(tokenParts[0], "Bearer") {
+		return "", fmt.Errorf("invalid authorization header format")
 	}
+	return tokenParts[1], nil
+}
 
-	tokenString := tokenParts[1]
-
-	// For now, we'll parse without verification since we need to set up JWKS
-	// In production, this should verify against Cognito's public keys
-	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, &JWTClaims{})
+// VerifyJWT checks a Cognito token against the user pool configured in the environment
+func VerifyJWT(ctx context.Context, token string) (*JWTClaims, error) {
+	verifier, err := defaultJWTVerifier()
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse JWT token: %w", err)
-	}
-
-	claims, ok := token.Claims.(*JWTClaims)
-	if !ok {
-		return nil, fmt.Errorf("failed to extract claims from token")
+		return nil, err
 	}
-
-	return claims, nil
+	return verifier.Verify(ctx, token)
 }
 
 // GetRequestID extracts or generates a request ID for tracing
@@ -153,12 +143,6 @@ func GetQueryParameterInt(request events.APIGatewayProxyRequest, key string, def
 	return intValue
 }
 
-// ValidateRequired checks if required fields are present in the request
-func ValidateRequired(data interface{}, field string) error {
-	// Basic validation - in production, use a validation library like github.com/go-playground/validator
-	return nil
-}
-
 // ParseRequestBody parses JSON request body into a struct
 func ParseRequestBody(request events.APIGatewayProxyRequest, target interface{}) error {
 	if request.Body == "" {
@@ -199,7 +183,7 @@ func HandleCORS(request events.APIGatewayProxyRequest) *APIResponse {
 			StatusCode: http.StatusOK,
 			Headers: map[string]string{
 				"Access-Control-Allow-Origin":      "https://d1jcaphz4458q7.cloudfront.net",
-				"Access-Control-Allow-Methods":     "GET, POST, PUT, DELETE, OPTIONS",
+				"Access-Control-Allow-Methods":     "GET, POST, PUT, PATCH, DELETE, OPTIONS",
 				"Access-Control-Allow-Headers":     "Content-Type, Authorization",
 				"Access-Control-Allow-Credentials": "true",
 				"Access-Control-Max-Age":           "86400",
@@ -211,6 +195,34 @@ func HandleCORS(request events.APIGatewayProxyRequest) *APIResponse {
 	return nil
 }
 
+// LambdaDeadlineMargin is how long before the Lambda deadline storage calls are cancelled
+const LambdaDeadlineMargin = 500 * time.Millisecond
+
+// WithDeadlineMargin returns a context that expires margin before ctx's deadline (the Lambda
+// timeout), so slow S3 calls abort while there is still time to send an error response
+// instead of the function being killed mid-write. Contexts without a deadline are unchanged.
+func WithDeadlineMargin(ctx context.Context, margin time.Duration) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline.Add(-margin))
+}
+
+// DefaultTrashRetentionDays is how long deleted recipes stay restorable when TRASH_RETENTION_DAYS is unset
+const DefaultTrashRetentionDays = 30
+
+// TrashRetention returns how long deleted recipes stay in the trash before they are purged
+func TrashRetention() time.Duration {
+	days := DefaultTrashRetentionDays
+	if val := os.Getenv("TRASH_RETENTION_DAYS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed > 0 {
+			days = parsed
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
 // LogInfo logs an info message with structured data
 func LogInfo(ctx context.Context, message string, data map[string]interface{}) {
 	logData := map[string]interface{}{
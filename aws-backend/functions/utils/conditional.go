@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"recipe-archive/models"
+)
+
+// Conditional GET support. Responses carry a strong ETag and Last-Modified; a request whose
+// If-None-Match (or, without it, If-Modified-Since) shows the client already has the current
+// representation gets an empty 304 instead of the body.
+
+// RecipeETag is the strong ETag of a recipe. Every write bumps Version or UpdatedAt.
+func RecipeETag(recipe *models.Recipe) string {
+	return fmt.Sprintf(`"%s-v%d-%d"`, recipe.ID, recipe.Version, recipe.UpdatedAt.UnixNano())
+}
+
+// ListETag is the strong ETag of a list response: a hash of the variant (query parameters that
+// shape the body) and of every summary it was built from
+func ListETag(variant string, summaries []models.RecipeSummary) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", variant)
+	for _, summary := range summaries {
+		fmt.Fprintf(h, "%s %d %d %t\n", summary.ID, summary.Version, summary.UpdatedAt.UnixNano(), summary.IsDeleted)
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil))[:32] + `"`
+}
+
+// LastModified returns the latest UpdatedAt among summaries
+func LastModified(summaries []models.RecipeSummary) time.Time {
+	var latest time.Time
+	for _, summary := range summaries {
+		if summary.UpdatedAt.After(latest) {
+			latest = summary.UpdatedAt
+		}
+	}
+	return latest
+}
+
+// NotModified reports whether the request's validators match the current representation.
+// If-None-Match takes precedence over If-Modified-Since, as in RFC 9110.
+func NotModified(request events.APIGatewayProxyRequest, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := GetHeader(request, "If-None-Match"); ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ifModifiedSince := GetHeader(request, "If-Modified-Since"); ifModifiedSince != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ifModifiedSince)
+		return err == nil && !lastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// WithValidators adds the ETag and Last-Modified headers to a response. Cache-Control makes
+// clients revalidate every time instead of trusting a stale copy.
+func WithValidators(response events.APIGatewayProxyResponse, etag string, lastModified time.Time) events.APIGatewayProxyResponse {
+	if response.Headers == nil {
+		response.Headers = map[string]string{}
+	}
+	response.Headers["ETag"] = etag
+	if !lastModified.IsZero() {
+		response.Headers["Last-Modified"] = lastModified.UTC().Format(http.TimeFormat)
+	}
+	response.Headers["Cache-Control"] = "private, no-cache"
+	return response
+}
+
+// NewNotModifiedResponse creates the empty 304 response for a matching conditional GET
+func NewNotModifiedResponse(etag string, lastModified time.Time) (events.APIGatewayProxyResponse, error) {
+	response, err := NewAPIResponse(http.StatusNotModified, nil)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	response.Body = ""
+	delete(response.Headers, "Content-Type")
+	return WithValidators(response, etag, lastModified), nil
+}
+
+// GetHeader returns a request header by case-insensitive name
+func GetHeader(request events.APIGatewayProxyRequest, name string) string {
+	if value, ok := request.Headers[name]; ok {
+		return value
+	}
+	for key, value := range request.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"recipe-archive/models"
+)
+
+func TestNotModified(t *testing.T) {
+	updated := time.Date(2025, 3, 4, 5, 6, 7, 890, time.UTC)
+	recipe := &models.Recipe{ID: "r1", Version: 3, UpdatedAt: updated}
+	etag := RecipeETag(recipe)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    bool
+	}{
+		{"no validators", nil, false},
+		{"matching etag", map[string]string{"if-none-match": etag}, true},
+		{"etag in list", map[string]string{"If-None-Match": `"other", W/` + etag}, true},
+		{"stale etag", map[string]string{"If-None-Match": `"r1-v2-1"`}, false},
+		{"etag wins over date", map[string]string{"If-None-Match": `"r1-v2-1"`, "If-Modified-Since": updated.Add(time.Hour).Format(http.TimeFormat)}, false},
+		{"not modified since", map[string]string{"If-Modified-Since": updated.Format(http.TimeFormat)}, true},
+		{"modified since", map[string]string{"If-Modified-Since": updated.Add(-time.Second).Format(http.TimeFormat)}, false},
+	}
+	for _, tt := range tests {
+		request := events.APIGatewayProxyRequest{Headers: tt.headers}
+		if got := NotModified(request, etag, updated); got != tt.want {
+			t.Errorf("%s: got %v want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Any write changes the ETag
+	recipe.UpdatedAt = updated.Add(time.Millisecond)
+	if RecipeETag(recipe) == etag {
+		t.Error("ETag unchanged after UpdatedAt changed")
+	}
+}
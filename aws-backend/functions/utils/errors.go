@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"recipe-archive/models"
+)
+
+// Handlers report failures by returning an *AppError; WithErrorResponses turns it into the
+// models.ErrorResponse envelope, so every endpoint sends errors in the same shape and with
+// the request ID. Any other error becomes a generic 500 without leaking internals.
+
+// AppError is an error with the code, HTTP status and details reported to the client
+type AppError struct {
+	Status  int
+	Code    string
+	Message string
+	Details interface{}
+	// Headers are added to the error response, e.g. Allow or Retry-After
+	Headers map[string]string
+	// Cause is logged but never sent to the client
+	Cause error
+}
+
+// NewAppError creates an AppError
+func NewAppError(status int, code, message string) *AppError {
+	return &AppError{Status: status, Code: code, Message: message}
+}
+
+// NewValidationError reports request validation failures, listing every violation
+func NewValidationError(violations []models.FieldError) *AppError {
+	return NewAppError(http.StatusBadRequest, "VALIDATION_ERROR", ValidationMessage(violations)).
+		WithDetails(models.ValidationDetails{Fields: violations})
+}
+
+// NewInternalError reports an unexpected failure; cause is logged, message is shown
+func NewInternalError(message string, cause error) *AppError {
+	return NewAppError(http.StatusInternalServerError, "INTERNAL_ERROR", message).WithCause(cause)
+}
+
+func (e *AppError) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Cause)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Cause
+}
+
+// WithDetails returns a copy of the error carrying details
+func (e *AppError) WithDetails(details interface{}) *AppError {
+	copied := *e
+	copied.Details = details
+	return &copied
+}
+
+// WithHeader returns a copy of the error that adds a header to its response
+func (e *AppError) WithHeader(name, value string) *AppError {
+	copied := *e
+	copied.Headers = make(map[string]string, len(e.Headers)+1)
+	for k, v := range e.Headers {
+		copied.Headers[k] = v
+	}
+	copied.Headers[name] = value
+	return &copied
+}
+
+// WithCause returns a copy of the error wrapping cause
+func (e *AppError) WithCause(cause error) *AppError {
+	copied := *e
+	copied.Cause = cause
+	return &copied
+}
+
+// LogCause logs the cause of a server error; client errors are expected and not logged
+func (e *AppError) LogCause(requestID string) {
+	if e.Status >= http.StatusInternalServerError && e.Cause != nil {
+		fmt.Printf("❌ %s (request %s): %v\n", e.Code, requestID, e.Cause)
+	}
+}
+
+// APIError converts the error into the body clients receive
+func (e *AppError) APIError(requestID string) models.APIError {
+	return models.APIError{
+		Code:      e.Code,
+		Message:   e.Message,
+		Details:   e.Details,
+		Timestamp: time.Now().UTC(),
+		RequestID: requestID,
+	}
+}
+
+// AsAppError returns err as an *AppError, or a generic internal error wrapping it
+func AsAppError(err error) *AppError {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return NewInternalError("Internal server error", err)
+}
+
+// ErrorResponseFor translates err into the error envelope
+func ErrorResponseFor(err error, requestID string) (events.APIGatewayProxyResponse, error) {
+	appErr := AsAppError(err)
+	appErr.LogCause(requestID)
+	response, err := NewAPIResponse(appErr.Status, models.ErrorResponse{Error: appErr.APIError(requestID)})
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	for name, value := range appErr.Headers {
+		response.Headers[name] = value
+	}
+	return response, nil
+}
+
+// Handler is the signature of an API Gateway Lambda handler
+type Handler func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// WithErrorResponses wraps a handler so returned errors reach the client as error responses
+// instead of failing the invocation
+func WithErrorResponses(next Handler) Handler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		response, err := next(ctx, request)
+		if err != nil {
+			return ErrorResponseFor(err, GetRequestID(request))
+		}
+		return response, nil
+	}
+}
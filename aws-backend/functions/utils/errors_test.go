@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"recipe-archive/models"
+)
+
+func TestWithErrorResponses(t *testing.T) {
+	request := events.APIGatewayProxyRequest{}
+	request.RequestContext.RequestID = "req-123"
+
+	notFound := WithErrorResponses(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{}, NewAppError(http.StatusNotFound, "RECIPE_NOT_FOUND", "Recipe not found").
+			WithDetails(map[string]string{"id": "r1"})
+	})
+	response, err := notFound(context.Background(), request)
+	if err != nil {
+		t.Fatalf("handler error: %v", err)
+	}
+	var body models.ErrorResponse
+	if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if response.StatusCode != http.StatusNotFound || body.Error.Code != "RECIPE_NOT_FOUND" ||
+		body.Error.RequestID != "req-123" || body.Error.Details == nil {
+		t.Errorf("response: %d %+v", response.StatusCode, body)
+	}
+
+	failing := WithErrorResponses(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{}, errors.New("dial tcp: secret-host refused")
+	})
+	response, err = failing(context.Background(), request)
+	if err != nil {
+		t.Fatalf("handler error: %v", err)
+	}
+	if response.StatusCode != http.StatusInternalServerError || !strings.Contains(response.Body, "INTERNAL_ERROR") ||
+		strings.Contains(response.Body, "secret-host") {
+		t.Errorf("unexpected internal error response: %d %s", response.StatusCode, response.Body)
+	}
+}
@@ -0,0 +1,240 @@
+package utils
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Cognito token verification. Tokens must be RS256-signed by a key in the user pool's JWKS,
+// issued by the pool, unexpired, and minted for one of our app clients. Keys are cached and
+// refetched when a token names a key we have not seen, which is how Cognito rotates them.
+
+const (
+	// jwtLeeway tolerates clock skew between Cognito and Lambda for exp, nbf and iat
+	jwtLeeway = time.Minute
+	// jwksTTL is how long fetched keys are trusted before the set is refreshed
+	jwksTTL = 6 * time.Hour
+	// jwksMinRefresh limits refetches triggered by unknown key IDs, so forged kids cannot
+	// make every request hit the JWKS endpoint
+	jwksMinRefresh = time.Minute
+	// jwksFetchTimeout bounds a JWKS download
+	jwksFetchTimeout = 5 * time.Second
+)
+
+// ErrJWTNotConfigured means no user pool is configured to verify tokens against
+var ErrJWTNotConfigured = errors.New("JWT verification is not configured")
+
+// JWKSCache fetches and caches the RSA public keys of a JSON Web Key Set
+type JWKSCache struct {
+	url    string
+	client *http.Client
+	now    func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+// NewJWKSCache creates a cache for the key set at url
+func NewJWKSCache(url string) *JWKSCache {
+	return &JWKSCache{
+		url:    url,
+		client: &http.Client{Timeout: jwksFetchTimeout},
+		now:    time.Now,
+	}
+}
+
+// Key returns the public key with the given key ID, refreshing the set when it is stale or
+// does not contain kid
+func (c *JWKSCache) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	age := c.now().Sub(c.fetchedAt)
+	key, known := c.keys[kid]
+	if known && age < jwksTTL {
+		return key, nil
+	}
+	if c.keys == nil || age >= jwksMinRefresh {
+		if err := c.refresh(ctx); err != nil {
+			// Keep serving known keys if the endpoint is briefly unavailable
+			if known {
+				fmt.Printf("⚠️ JWKS refresh failed, using cached key: %v\n", err)
+				return key, nil
+			}
+			return nil, err
+		}
+		if key, known = c.keys[kid]; known {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// refresh downloads the key set, keeping only RSA signing keys. Callers hold c.mu.
+func (c *JWKSCache) refresh(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, jwksFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build JWKS request: %w", err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil || len(e) > 4 {
+			fmt.Printf("⚠️ Skipping malformed JWKS key %q\n", jwk.Kid)
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	c.keys = keys
+	c.fetchedAt = c.now()
+	return nil
+}
+
+// JWTVerifier checks Cognito-issued ID and access tokens
+type JWTVerifier struct {
+	Issuer string
+	// ClientIDs are the app clients whose tokens are accepted; with none, every token is rejected
+	ClientIDs []string
+	Keys      *JWKSCache
+}
+
+// NewJWTVerifierFromEnv builds the verifier for COGNITO_USER_POOL_ID in AWS_REGION, accepting
+// tokens of the app clients in COGNITO_APP_CLIENT_ID (comma-separated). Both are required.
+// COGNITO_JWKS_URL replaces the pool's key set URL, e.g. for a local test server.
+func NewJWTVerifierFromEnv() (*JWTVerifier, error) {
+	poolID := os.Getenv("COGNITO_USER_POOL_ID")
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		// Pool IDs are prefixed with their region, e.g. us-west-2_AbC123
+		region, _, _ = strings.Cut(poolID, "_")
+	}
+	if poolID == "" || region == "" {
+		return nil, ErrJWTNotConfigured
+	}
+
+	issuer := fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", region, poolID)
+	jwksURL := os.Getenv("COGNITO_JWKS_URL")
+	if jwksURL == "" {
+		jwksURL = issuer + "/.well-known/jwks.json"
+	}
+	var clientIDs []string
+	for _, id := range strings.Split(os.Getenv("COGNITO_APP_CLIENT_ID"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			clientIDs = append(clientIDs, id)
+		}
+	}
+	if len(clientIDs) == 0 {
+		// Any app client in the pool, or another pool's client, could otherwise mint accepted tokens
+		return nil, fmt.Errorf("%w: COGNITO_APP_CLIENT_ID is not set", ErrJWTNotConfigured)
+	}
+	return &JWTVerifier{Issuer: issuer, ClientIDs: clientIDs, Keys: NewJWKSCache(jwksURL)}, nil
+}
+
+// Verify parses tokenString and checks its signature and claims
+func (v *JWTVerifier) Verify(ctx context.Context, tokenString string) (*JWTClaims, error) {
+	claims := &JWTClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New("token has no key ID")
+		}
+		return v.Keys.Key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(v.Issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(jwtLeeway),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+
+	// ID tokens name the app client in aud, access tokens in client_id
+	var client string
+	switch claims.TokenUse {
+	case "id":
+		if len(claims.Audience) == 1 {
+			client = claims.Audience[0]
+		}
+	case "access":
+		client = claims.ClientID
+	default:
+		return nil, fmt.Errorf("invalid token: unexpected token_use %q", claims.TokenUse)
+	}
+	if !containsString(v.ClientIDs, client) {
+		return nil, fmt.Errorf("invalid token: issued to unknown client %q", client)
+	}
+	if claims.Sub == "" {
+		return nil, errors.New("invalid token: missing subject")
+	}
+	return claims, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	defaultVerifier     *JWTVerifier
+	defaultVerifierErr  error
+	defaultVerifierOnce sync.Once
+)
+
+// defaultJWTVerifier returns the verifier configured from the environment, built once per
+// container so its key cache is shared by every invocation
+func defaultJWTVerifier() (*JWTVerifier, error) {
+	defaultVerifierOnce.Do(func() {
+		defaultVerifier, defaultVerifierErr = NewJWTVerifierFromEnv()
+	})
+	return defaultVerifier, defaultVerifierErr
+}
@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testJWKS serves the public halves of its keys and counts fetches
+type testJWKS struct {
+	mu      sync.Mutex
+	keys    map[string]*rsa.PrivateKey
+	fetches int
+}
+
+func (s *testJWKS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetches++
+	var set struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	for kid, key := range s.keys {
+		set.Keys = append(set.Keys, map[string]string{
+			"kid": kid, "kty": "RSA", "alg": "RS256", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	json.NewEncoder(w).Encode(set)
+}
+
+func (s *testJWKS) addKey(t *testing.T, kid string) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	s.keys[kid] = key
+	s.mu.Unlock()
+	return key
+}
+
+const testIssuer = "https://cognito-idp.us-west-2.amazonaws.com/us-west-2_test"
+
+func mint(t *testing.T, key interface{}, method jwt.SigningMethod, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"sub": "user-1", "iss": testIssuer, "aud": "client-1", "token_use": "id",
+		"iat": now.Unix(), "exp": now.Add(time.Hour).Unix(),
+	}
+}
+
+func TestJWTVerifier(t *testing.T) {
+	jwks := &testJWKS{keys: map[string]*rsa.PrivateKey{}}
+	server := httptest.NewServer(jwks)
+	defer server.Close()
+	key := jwks.addKey(t, "key-1")
+
+	t.Setenv("AWS_REGION", "us-west-2")
+	t.Setenv("COGNITO_USER_POOL_ID", "us-west-2_test")
+	t.Setenv("COGNITO_APP_CLIENT_ID", "client-1, client-2")
+	t.Setenv("COGNITO_JWKS_URL", server.URL)
+	verifier, err := NewJWTVerifierFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	claims, err := verifier.Verify(ctx, mint(t, key, jwt.SigningMethodRS256, "key-1", validClaims()))
+	if err != nil || claims.Sub != "user-1" {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+
+	access := validClaims()
+	delete(access, "aud")
+	access["token_use"], access["client_id"] = "access", "client-2"
+	if _, err := verifier.Verify(ctx, mint(t, key, jwt.SigningMethodRS256, "key-1", access)); err != nil {
+		t.Errorf("access token rejected: %v", err)
+	}
+
+	skewed := validClaims()
+	skewed["exp"] = time.Now().Add(-30 * time.Second).Unix()
+	if _, err := verifier.Verify(ctx, mint(t, key, jwt.SigningMethodRS256, "key-1", skewed)); err != nil {
+		t.Errorf("token within clock skew rejected: %v", err)
+	}
+
+	forger, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rejected := map[string]string{
+		"forged signature": mint(t, forger, jwt.SigningMethodRS256, "key-1", validClaims()),
+		"hmac":             mint(t, []byte("secret"), jwt.SigningMethodHS256, "key-1", validClaims()),
+	}
+	for name, mutate := range map[string]func(jwt.MapClaims){
+		"expired":         func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-5 * time.Minute).Unix() },
+		"no expiry":       func(c jwt.MapClaims) { delete(c, "exp") },
+		"wrong issuer":    func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+		"wrong client":    func(c jwt.MapClaims) { c["aud"] = "client-9" },
+		"wrong token_use": func(c jwt.MapClaims) { c["token_use"] = "refresh" },
+	} {
+		claims := validClaims()
+		mutate(claims)
+		rejected[name] = mint(t, key, jwt.SigningMethodRS256, "key-1", claims)
+	}
+	for name, token := range rejected {
+		if _, err := verifier.Verify(ctx, token); err == nil {
+			t.Errorf("%s: token accepted", name)
+		}
+	}
+
+	// Without configured app clients nothing is accepted
+	unrestricted := &JWTVerifier{Issuer: verifier.Issuer, Keys: verifier.Keys}
+	if _, err := unrestricted.Verify(ctx, mint(t, key, jwt.SigningMethodRS256, "key-1", validClaims())); err == nil {
+		t.Error("token accepted with no client IDs configured")
+	}
+	t.Setenv("COGNITO_APP_CLIENT_ID", " ")
+	if _, err := NewJWTVerifierFromEnv(); !errors.Is(err, ErrJWTNotConfigured) {
+		t.Errorf("verifier without client IDs: got %v want ErrJWTNotConfigured", err)
+	}
+}
+
+func TestJWKSCacheRotation(t *testing.T) {
+	jwks := &testJWKS{keys: map[string]*rsa.PrivateKey{}}
+	server := httptest.NewServer(jwks)
+	defer server.Close()
+	jwks.addKey(t, "key-1")
+
+	now := time.Now()
+	cache := NewJWKSCache(server.URL)
+	cache.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if _, err := cache.Key(ctx, "key-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Key(ctx, "key-1"); err != nil || jwks.fetches != 1 {
+		t.Fatalf("cached key refetched: %d fetches, %v", jwks.fetches, err)
+	}
+
+	// A rotated key is fetched once the refresh limit allows it
+	jwks.addKey(t, "key-2")
+	if _, err := cache.Key(ctx, "key-2"); err == nil || jwks.fetches != 1 {
+		t.Errorf("unknown key refetched within the refresh limit: %d fetches, %v", jwks.fetches, err)
+	}
+	now = now.Add(jwksMinRefresh)
+	if _, err := cache.Key(ctx, "key-2"); err != nil || jwks.fetches != 2 {
+		t.Errorf("rotated key not fetched: %d fetches, %v", jwks.fetches, err)
+	}
+}
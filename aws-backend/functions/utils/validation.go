@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+
+	"recipe-archive/models"
+)
+
+// Request validation. The validate tags on the request models are enforced by
+// go-playground/validator; the recipe rules the tags cannot express are checked alongside.
+// Every violation is collected so clients can highlight all bad fields at once.
+
+// timeToleranceMinutes is how far prep + cook may exceed the total time before it is
+// reported; sites often round each figure separately
+const timeToleranceMinutes = 5
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	// Report fields by their JSON names, which is what clients send
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	return v
+}
+
+// ValidateStruct checks a request against its validate tags
+func ValidateStruct(data interface{}) []models.FieldError {
+	err := validate.Struct(data)
+	if err == nil {
+		return nil
+	}
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return []models.FieldError{{Field: "", Rule: "invalid", Message: err.Error()}}
+	}
+
+	violations := make([]models.FieldError, 0, len(fieldErrors))
+	for _, fe := range fieldErrors {
+		// The namespace starts with the Go type name, which means nothing to clients
+		field := fe.Namespace()
+		if i := strings.Index(field, "."); i >= 0 {
+			field = field[i+1:]
+		}
+		violations = append(violations, models.FieldError{
+			Field:   field,
+			Rule:    fe.Tag(),
+			Message: fieldMessage(field, fe),
+		})
+	}
+	return violations
+}
+
+// ValidateCreateRecipe checks a full recipe, as sent to create, replace or batch
+func ValidateCreateRecipe(data *models.CreateRecipeRequest) []models.FieldError {
+	violations := ValidateStruct(data)
+	if strings.TrimSpace(data.Title) == "" && data.Title != "" {
+		violations = append(violations, models.FieldError{Field: "title", Rule: "required", Message: "title is required"})
+	}
+	violations = append(violations, validateIngredients(data.Ingredients)...)
+	violations = append(violations, validateInstructions(data.Instructions)...)
+	violations = append(violations, validateTimes(data.PrepTimeMinutes, data.CookTimeMinutes, data.TotalTimeMinutes)...)
+	return violations
+}
+
+// ValidateRecipe checks a complete recipe, such as the result of a patch, by the same rules
+// as ValidateCreateRecipe
+func ValidateRecipe(recipe *models.Recipe) []models.FieldError {
+	return ValidateCreateRecipe(&models.CreateRecipeRequest{
+		Title:            recipe.Title,
+		Ingredients:      recipe.Ingredients,
+		Instructions:     recipe.Instructions,
+		SourceURL:        recipe.SourceURL,
+		MainPhotoURL:     recipe.MainPhotoURL,
+		PrepTimeMinutes:  recipe.PrepTimeMinutes,
+		CookTimeMinutes:  recipe.CookTimeMinutes,
+		TotalTimeMinutes: recipe.TotalTimeMinutes,
+		Servings:         recipe.Servings,
+		Yield:            recipe.Yield,
+		Categories:       recipe.Categories,
+		Description:      recipe.Description,
+		Reviews:          recipe.Reviews,
+		Nutrition:        recipe.Nutrition,
+	})
+}
+
+// ValidateUpdateRecipe checks the fields present in a partial update
+func ValidateUpdateRecipe(data *models.UpdateRecipeRequest) []models.FieldError {
+	violations := ValidateStruct(data)
+	if data.Title != nil && strings.TrimSpace(*data.Title) == "" {
+		violations = append(violations, models.FieldError{Field: "title", Rule: "required", Message: "title must not be empty"})
+	}
+	if data.Ingredients != nil {
+		violations = append(violations, validateIngredients(*data.Ingredients)...)
+	}
+	if data.Instructions != nil {
+		violations = append(violations, validateInstructions(*data.Instructions)...)
+	}
+	violations = append(violations, validateTimes(data.PrepTimeMinutes, data.CookTimeMinutes, data.TotalTimeMinutes)...)
+	return violations
+}
+
+// ValidateRequired checks that the named field (Go or JSON name) of a struct is set
+func ValidateRequired(data interface{}, field string) error {
+	value := reflect.Indirect(reflect.ValueOf(data))
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot validate field %s of %T", field, data)
+	}
+	for i := 0; i < value.NumField(); i++ {
+		structField := value.Type().Field(i)
+		jsonName := strings.SplitN(structField.Tag.Get("json"), ",", 2)[0]
+		if structField.Name != field && jsonName != field {
+			continue
+		}
+		if err := validate.Var(value.Field(i).Interface(), "required"); err != nil {
+			return fmt.Errorf("%s is required", field)
+		}
+		return nil
+	}
+	return fmt.Errorf("%T has no field %s", data, field)
+}
+
+// ValidationMessage summarizes violations for the error message
+func ValidationMessage(violations []models.FieldError) string {
+	if len(violations) == 1 {
+		return violations[0].Message
+	}
+	return fmt.Sprintf("%d fields are invalid", len(violations))
+}
+
+func validateIngredients(ingredients []models.Ingredient) []models.FieldError {
+	var violations []models.FieldError
+	for i, ingredient := range ingredients {
+		if strings.TrimSpace(ingredient.Text) == "" {
+			field := fmt.Sprintf("ingredients[%d].text", i)
+			violations = append(violations, models.FieldError{Field: field, Rule: "required", Message: field + " is required"})
+		}
+	}
+	return violations
+}
+
+// validateInstructions requires step numbers to be positive and increasing. Gaps are allowed:
+// the browser extension keeps the page's numbering when it drops section headers.
+func validateInstructions(instructions []models.Instruction) []models.FieldError {
+	var violations []models.FieldError
+	previous := 0
+	for i, instruction := range instructions {
+		if strings.TrimSpace(instruction.Text) == "" {
+			field := fmt.Sprintf("instructions[%d].text", i)
+			violations = append(violations, models.FieldError{Field: field, Rule: "required", Message: field + " is required"})
+		}
+		if instruction.StepNumber <= previous {
+			field := fmt.Sprintf("instructions[%d].stepNumber", i)
+			violations = append(violations, models.FieldError{
+				Field:   field,
+				Rule:    "increasing",
+				Message: fmt.Sprintf("%s must be greater than %d; steps are numbered from 1 in order", field, previous),
+			})
+			continue
+		}
+		previous = instruction.StepNumber
+	}
+	return violations
+}
+
+func validateTimes(prep, cook, total *int) []models.FieldError {
+	if prep == nil || cook == nil || total == nil {
+		return nil
+	}
+	if *prep+*cook > *total+timeToleranceMinutes {
+		return []models.FieldError{{
+			Field: "totalTimeMinutes",
+			Rule:  "time_sum",
+			Message: fmt.Sprintf("totalTimeMinutes (%d) is less than prepTimeMinutes + cookTimeMinutes (%d)",
+				*total, *prep+*cook),
+		}}
+	}
+	return nil
+}
+
+// fieldMessage turns a tag failure into a sentence about the field
+func fieldMessage(field string, fe validator.FieldError) string {
+	kind := fe.Kind()
+	switch fe.Tag() {
+	case "required":
+		return field + " is required"
+	case "url":
+		return field + " must be a valid URL"
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", field, strings.ReplaceAll(fe.Param(), " ", ", "))
+	case "min":
+		switch kind {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return fmt.Sprintf("%s must contain at least %s item(s)", field, fe.Param())
+		case reflect.String:
+			return fmt.Sprintf("%s must be at least %s characters", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at least %s", field, fe.Param())
+	case "max":
+		switch kind {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return fmt.Sprintf("%s must contain at most %s item(s)", field, fe.Param())
+		case reflect.String:
+			return fmt.Sprintf("%s must be at most %s characters", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at most %s", field, fe.Param())
+	}
+	return fmt.Sprintf("%s failed the %s check", field, fe.Tag())
+}
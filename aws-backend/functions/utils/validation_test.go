@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"testing"
+
+	"recipe-archive/models"
+)
+
+func TestValidateCreateRecipeReportsEveryViolation(t *testing.T) {
+	prep, cook, total, servings := 20, 40, 30, 0
+	request := &models.CreateRecipeRequest{
+		Title:            "",
+		Ingredients:      []models.Ingredient{{Text: "1 onion"}, {Text: "  "}},
+		Instructions:     []models.Instruction{{StepNumber: 1, Text: "Chop"}, {StepNumber: 1, Text: "Cook"}},
+		SourceURL:        "not a url",
+		PrepTimeMinutes:  &prep,
+		CookTimeMinutes:  &cook,
+		TotalTimeMinutes: &total,
+		Servings:         &servings,
+	}
+
+	violations := ValidateCreateRecipe(request)
+	got := map[string]string{}
+	for _, v := range violations {
+		got[v.Field] = v.Rule
+	}
+	want := map[string]string{
+		"title":                      "required",
+		"sourceUrl":                  "url",
+		"servings":                   "min",
+		"ingredients[1].text":        "required",
+		"instructions[1].stepNumber": "increasing",
+		"totalTimeMinutes":           "time_sum",
+	}
+	for field, rule := range want {
+		if got[field] != rule {
+			t.Errorf("%s: got rule %q, want %q (all: %+v)", field, got[field], rule, violations)
+		}
+	}
+	if len(violations) != len(want) {
+		t.Errorf("got %d violations, want %d: %+v", len(violations), len(want), violations)
+	}
+}
+
+func TestValidateCreateRecipeAcceptsGappedSteps(t *testing.T) {
+	// The extension drops "For the sauce:" style headers and keeps the page's numbering
+	request := &models.CreateRecipeRequest{
+		Title:       "Pasta",
+		Ingredients: []models.Ingredient{{Text: "200g spaghetti"}},
+		Instructions: []models.Instruction{
+			{StepNumber: 1, Text: "Boil the pasta"},
+			{StepNumber: 3, Text: "Fry the garlic"},
+			{StepNumber: 4, Text: "Toss together"},
+		},
+		SourceURL: "https://example.com/pasta",
+	}
+	if violations := ValidateCreateRecipe(request); len(violations) != 0 {
+		t.Errorf("gapped steps rejected: %+v", violations)
+	}
+
+	request.Instructions[2].StepNumber = 2
+	violations := ValidateCreateRecipe(request)
+	if len(violations) != 1 || violations[0].Field != "instructions[2].stepNumber" || violations[0].Rule != "increasing" {
+		t.Errorf("out of order steps: %+v", violations)
+	}
+}
+
+func TestValidateUpdateRecipeChecksOnlyProvidedFields(t *testing.T) {
+	if violations := ValidateUpdateRecipe(&models.UpdateRecipeRequest{}); len(violations) != 0 {
+		t.Errorf("empty update: %+v", violations)
+	}
+
+	title := " "
+	ingredients := []models.Ingredient{}
+	violations := ValidateUpdateRecipe(&models.UpdateRecipeRequest{Title: &title, Ingredients: &ingredients})
+	if len(violations) != 2 || violations[0].Field != "ingredients" || violations[1].Field != "title" {
+		t.Errorf("update violations: %+v", violations)
+	}
+}
+
+func TestValidateRecipeReportsMergedFields(t *testing.T) {
+	// A merge patch can remove what the update checks cannot see, e.g. the only ingredient list
+	recipe := &models.Recipe{
+		Title:        "Soup",
+		Instructions: []models.Instruction{{StepNumber: 1, Text: "Simmer"}},
+		SourceURL:    "https://example.com/soup",
+	}
+	violations := ValidateRecipe(recipe)
+	if len(violations) != 1 || violations[0].Field != "ingredients" || violations[0].Rule != "required" {
+		t.Errorf("merged recipe violations: %+v", violations)
+	}
+}
+
+func TestValidateRequired(t *testing.T) {
+	request := models.CreateRecipeRequest{Title: "Soup"}
+	if err := ValidateRequired(request, "title"); err != nil {
+		t.Errorf("title: %v", err)
+	}
+	if err := ValidateRequired(&request, "SourceURL"); err == nil {
+		t.Error("missing sourceUrl accepted")
+	}
+}
@@ -10,7 +10,10 @@ import * as sns from 'aws-cdk-lib/aws-sns';
 import * as snsSubscriptions from 'aws-cdk-lib/aws-sns-subscriptions';
 import * as budgets from 'aws-cdk-lib/aws-budgets';
 import * as sqs from 'aws-cdk-lib/aws-sqs';
+import * as dynamodb from 'aws-cdk-lib/aws-dynamodb';
 import * as lambdaEventSources from 'aws-cdk-lib/aws-lambda-event-sources';
+import * as events from 'aws-cdk-lib/aws-events';
+import * as eventsTargets from 'aws-cdk-lib/aws-events-targets';
 
 export interface RecipeArchiveStackProps extends cdk.StackProps {
   environment: string;
@@ -112,6 +115,12 @@ export class RecipeArchiveStack extends cdk.Stack {
           id: 'delete-incomplete-uploads',
           abortIncompleteMultipartUploadAfter: cdk.Duration.days(1),
         },
+        {
+          // Decrypted copies of encrypted backups, served through short-lived download links
+          id: 'delete-backup-downloads',
+          prefix: 'backup-downloads/',
+          expiration: cdk.Duration.days(1),
+        },
         // Environment-specific retention policies
         ...(props.environment === 'prod'
           ? [
@@ -229,12 +238,14 @@ export class RecipeArchiveStack extends cdk.Stack {
       description: 'RecipeArchive Backend API',
       defaultCorsPreflightOptions: {
         allowOrigins: ['https://localhost:3000', 'https://recipearchive.com', 'https://d1jcaphz4458q7.cloudfront.net'], // Restrict origins
-        allowMethods: ['GET', 'POST', 'PUT', 'DELETE', 'OPTIONS'],
+        allowMethods: ['GET', 'POST', 'PUT', 'PATCH', 'DELETE', 'OPTIONS'],
         allowHeaders: [
           'Content-Type',
           'X-Amz-Date',
           'Authorization',
           'X-Api-Key',
+          'If-None-Match',
+          'If-Modified-Since',
         ],
         allowCredentials: true, // Important for authenticated requests
       },
@@ -294,6 +305,16 @@ export class RecipeArchiveStack extends cdk.Stack {
       },
     });
 
+    // Token buckets for per-user rate limits, shared by every Lambda container. Buckets are
+    // rebuilt from nothing, so the table can be destroyed freely; expiresAt drops idle ones.
+    const rateLimitTable = new dynamodb.Table(this, 'RateLimitTable', {
+      tableName: `recipe-archive-rate-limits-${props.environment}`,
+      partitionKey: { name: 'key', type: dynamodb.AttributeType.STRING },
+      billingMode: dynamodb.BillingMode.PAY_PER_REQUEST,
+      timeToLiveAttribute: 'expiresAt',
+      removalPolicy: cdk.RemovalPolicy.DESTROY,
+    });
+
     // Lambda Functions
     const healthFunction = new lambda.Function(this, 'HealthFunction', {
       runtime: lambda.Runtime.PROVIDED_AL2,
@@ -308,6 +329,7 @@ export class RecipeArchiveStack extends cdk.Stack {
         S3_TEMP_BUCKET: this.tempBucket.bucketName,
         S3_FAILED_PARSING_BUCKET: this.failedParsingBucket.bucketName,
         COGNITO_USER_POOL_ID: this.userPool.userPoolId,
+        COGNITO_APP_CLIENT_ID: this.userPoolClient.userPoolClientId,
       },
       role: lambdaRole,
     });
@@ -325,8 +347,10 @@ export class RecipeArchiveStack extends cdk.Stack {
         S3_TEMP_BUCKET: this.tempBucket.bucketName,
         S3_FAILED_PARSING_BUCKET: this.failedParsingBucket.bucketName,
         COGNITO_USER_POOL_ID: this.userPool.userPoolId,
+        COGNITO_APP_CLIENT_ID: this.userPoolClient.userPoolClientId,
         API_GATEWAY_URL: `https://4sgexl03l7.execute-api.us-west-2.amazonaws.com/prod`,
         NORMALIZATION_QUEUE_URL: recipeNormalizationQueue.queueUrl,
+        RATE_LIMIT_TABLE_NAME: rateLimitTable.tableName,
       },
       role: lambdaRole,
     });
@@ -342,9 +366,12 @@ export class RecipeArchiveStack extends cdk.Stack {
         REGION: this.region,
         S3_STORAGE_BUCKET: this.storageBucket.bucketName,
         COGNITO_USER_POOL_ID: this.userPool.userPoolId,
+        COGNITO_APP_CLIENT_ID: this.userPoolClient.userPoolClientId,
+        RATE_LIMIT_TABLE_NAME: rateLimitTable.tableName,
       },
       role: lambdaRole,
     });
+    rateLimitTable.grantReadWriteData(lambdaRole);
 
     const diagnosticsFunction = new lambda.Function(this, 'DiagnosticsFunction', {
       runtime: lambda.Runtime.PROVIDED_AL2,
@@ -357,6 +384,7 @@ export class RecipeArchiveStack extends cdk.Stack {
         REGION: this.region,
         S3_FAILED_PARSING_BUCKET: this.failedParsingBucket.bucketName,
         COGNITO_USER_POOL_ID: this.userPool.userPoolId,
+        COGNITO_APP_CLIENT_ID: this.userPoolClient.userPoolClientId,
       },
       role: lambdaRole,
     });
@@ -372,6 +400,7 @@ export class RecipeArchiveStack extends cdk.Stack {
         ENVIRONMENT: props.environment,
         REGION: this.region,
         COGNITO_USER_POOL_ID: this.userPool.userPoolId,
+        COGNITO_APP_CLIENT_ID: this.userPoolClient.userPoolClientId,
         OPENAI_API_KEY: process.env.OPENAI_API_KEY || '', // Read from environment
       },
       role: lambdaRole,
@@ -401,6 +430,30 @@ export class RecipeArchiveStack extends cdk.Stack {
       })
     );
 
+    // Trash Purge Function: permanently deletes recipes trashed longer than the retention period
+    const trashRetentionDays = '30';
+    recipesFunction.addEnvironment('TRASH_RETENTION_DAYS', trashRetentionDays);
+    const trashPurgeFunction = new lambda.Function(this, 'TrashPurgeFunction', {
+      runtime: lambda.Runtime.PROVIDED_AL2,
+      handler: 'bootstrap',
+      code: lambda.Code.fromAsset('../functions/dist/trash-purge-package'),
+      timeout: cdk.Duration.minutes(5), // Walks every user's recipes
+      memorySize: 256,
+      environment: {
+        ENVIRONMENT: props.environment,
+        REGION: this.region,
+        S3_STORAGE_BUCKET: this.storageBucket.bucketName,
+        TRASH_RETENTION_DAYS: trashRetentionDays,
+      },
+      role: lambdaRole,
+    });
+
+    // Run the purge once a day
+    new events.Rule(this, 'TrashPurgeSchedule', {
+      schedule: events.Schedule.cron({ minute: '0', hour: '9' }),
+      targets: [new eventsTargets.LambdaFunction(trashPurgeFunction)],
+    });
+
     // Diagnostic Processor Function (Failed parse workflow)
     const diagnosticProcessorFunction = new lambda.Function(this, 'DiagnosticProcessorFunction', {
       runtime: lambda.Runtime.PROVIDED_AL2,
@@ -413,6 +466,7 @@ export class RecipeArchiveStack extends cdk.Stack {
         REGION: this.region,
         S3_FAILED_PARSING_BUCKET: this.failedParsingBucket.bucketName,
         COGNITO_USER_POOL_ID: this.userPool.userPoolId,
+        COGNITO_APP_CLIENT_ID: this.userPoolClient.userPoolClientId,
       },
       role: lambdaRole,
     });
@@ -458,30 +512,95 @@ export class RecipeArchiveStack extends cdk.Stack {
 
     // Recipe CRUD operations with Authentication
     const recipesIntegration = new apigateway.LambdaIntegration(recipesFunction);
+
+    // The recipes function verifies every request itself, accepting Cognito JWTs and personal
+    // access tokens; the Cognito authorizer would reject access tokens before they reach it
+    const recipesAuth = { authorizationType: apigateway.AuthorizationType.NONE };
     
     // List recipes: GET /v1/recipes (requires authentication)
     recipesResource.addMethod('GET', recipesIntegration, {
-      authorizer: cognitoAuthorizer,
+      ...recipesAuth,
       requestValidator: requestValidator,
     });
     
     // Create recipe: POST /v1/recipes (requires authentication)
     recipesResource.addMethod('POST', recipesIntegration, {
-      authorizer: cognitoAuthorizer,
+      ...recipesAuth,
       requestValidator: requestValidator,
     });
     
-    // Single recipe operations: GET/PUT/DELETE /v1/recipes/{id} (requires authentication)
+    // Single recipe operations: GET/PUT/PATCH/DELETE /v1/recipes/{id} (requires authentication)
     const recipeResource = recipesResource.addResource('{id}');
     recipeResource.addMethod('GET', recipesIntegration, {
-      authorizer: cognitoAuthorizer,
+      ...recipesAuth,
     });
     recipeResource.addMethod('PUT', recipesIntegration, {
-      authorizer: cognitoAuthorizer,
+      ...recipesAuth,
       requestValidator: requestValidator,
     });
+    recipeResource.addMethod('PATCH', recipesIntegration, {
+      ...recipesAuth,
+    });
     recipeResource.addMethod('DELETE', recipesIntegration, {
-      authorizer: cognitoAuthorizer,
+      ...recipesAuth,
+    });
+
+    // Trash: GET /v1/recipes/trash, POST /v1/recipes/{id}/restore,
+    // DELETE /v1/recipes/{id}/permanent (requires authentication)
+    recipesResource.addResource('trash').addMethod('GET', recipesIntegration, {
+      ...recipesAuth,
+    });
+    recipeResource.addResource('restore').addMethod('POST', recipesIntegration, {
+      ...recipesAuth,
+    });
+    recipeResource.addResource('permanent').addMethod('DELETE', recipesIntegration, {
+      ...recipesAuth,
+    });
+
+    // Duplicate lookup: GET /v1/recipes/lookup?url=... (requires authentication)
+    recipesResource.addResource('lookup').addMethod('GET', recipesIntegration, {
+      ...recipesAuth,
+    });
+
+    // Batch create/update/delete: POST /v1/recipes/batch (requires authentication)
+    recipesResource.addResource('batch').addMethod('POST', recipesIntegration, {
+      ...recipesAuth,
+    });
+
+    // Change feed for incremental sync: GET /v1/recipes/changes?since=... (requires authentication)
+    recipesResource.addResource('changes').addMethod('GET', recipesIntegration, {
+      ...recipesAuth,
+    });
+
+    // Version history: GET /v1/recipes/{id}/versions[/{version}], GET /v1/recipes/{id}/diff,
+    // POST /v1/recipes/{id}/versions/{version}/restore (requires authentication)
+    const versionsResource = recipeResource.addResource('versions');
+    versionsResource.addMethod('GET', recipesIntegration, {
+      ...recipesAuth,
+    });
+    const versionResource = versionsResource.addResource('{version}');
+    versionResource.addMethod('GET', recipesIntegration, {
+      ...recipesAuth,
+    });
+    versionResource.addResource('restore').addMethod('POST', recipesIntegration, {
+      ...recipesAuth,
+    });
+    recipeResource.addResource('diff').addMethod('GET', recipesIntegration, {
+      ...recipesAuth,
+    });
+
+    // Personal access tokens: GET/POST /v1/tokens, DELETE /v1/tokens/{tokenId}
+    // (requires a signed-in session, not an access token)
+    const tokensResource = v1.addResource('tokens');
+    tokensResource.addMethod('GET', recipesIntegration, {
+      ...recipesAuth,
+    });
+    tokensResource.addMethod('POST', recipesIntegration, {
+      ...recipesAuth,
+      requestValidator: requestValidator,
+    });
+    tokensResource.addResource('{tokenId}').addMethod('DELETE', recipesIntegration, {
+      ...recipesAuth,
     });
 
     // Image upload endpoint: POST /v1/images/upload (requires authentication)
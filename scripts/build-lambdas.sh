@@ -18,15 +18,23 @@ build_lambda() {
     # Create package directory
     mkdir -p "$FUNCTIONS_DIR/dist/${func_name}-package"
     
-    # Download dependencies and build the Go binary for Linux
+    # Download dependencies and build the Go binary for Linux. Functions with their own
+    # go.mod manage their dependencies; the rest share the functions module tidied above.
     cd "$FUNCTIONS_DIR/$func_name"
-    go mod tidy
-    go mod download
-    GOOS=linux GOARCH=amd64 go build -o "$FUNCTIONS_DIR/dist/${func_name}-package/bootstrap" main.go
+    if [ -f go.mod ]; then
+        go mod tidy
+        go mod download
+    fi
+    GOOS=linux GOARCH=amd64 go build -o "$FUNCTIONS_DIR/dist/${func_name}-package/bootstrap" .
     
     echo "  ✅ $func_name built successfully"
 }
 
+# Dependencies of the shared functions module (health, recipes, image-upload, diagnostics, trash-purge)
+cd "$FUNCTIONS_DIR"
+go mod tidy
+go mod download
+
 # Build all functions
 build_lambda "health"
 build_lambda "recipes" 
@@ -35,6 +43,7 @@ build_lambda "diagnostics"
 build_lambda "diagnostic-processor"
 build_lambda "content-normalizer"
 build_lambda "background-normalizer"
+build_lambda "trash-purge"
 
 echo "🎉 All Lambda functions built successfully!"
 echo ""
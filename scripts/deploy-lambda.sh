@@ -105,7 +105,7 @@ build_function() {
     
     # Build for AWS Lambda (Linux)
     cd "$func_dir"
-    GOOS=linux GOARCH=amd64 go build -o bootstrap main.go
+    GOOS=linux GOARCH=amd64 go build -o bootstrap .
     
     if [ ! -f "bootstrap" ]; then
         log_error "Build failed for $func_name"
@@ -11,6 +11,7 @@ import (
 	"log"
 	"net/url"
 	"os"
+	"path"
 	"sort"
 	"strings"
 	"text/tabwriter"
@@ -272,6 +273,11 @@ func (r *RecipeReporter) GenerateReport(userEmail string) ([]ReportEntry, error)
 	}
 
 	for _, obj := range recipeObjects {
+		// Skip reserved objects such as the per-user summary index (_index.json)
+		if strings.HasPrefix(path.Base(*obj.Key), "_") {
+			continue
+		}
+
 		var recipe Recipe
 		if err := r.getS3Object(*obj.Key, &recipe); err != nil {
 			fmt.Printf("⚠️  Could not read recipe %s: %v\n", *obj.Key, err)